@@ -0,0 +1,642 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define key names for options
+const paymentChaincodeKey = "paymentChaincode"
+const deliveryChaincodeKey = "deliveryChaincode"
+
+// Define objectType names for prefix
+const certificatePrefix = "certificate"
+const offerPrefix = "offer"
+const cancellationPrefix = "cancellation"
+
+// Define the statuses of a sale offer
+const (
+	OfferSettled   = "Settled"
+	OfferCancelled = "Cancelled"
+)
+
+// SmartContract provides functions for settling token-erc-721 sales against token-erc-20 payments
+type SmartContract struct {
+	contractapi.Contract
+}
+
+// SaleOffer is the canonical message the seller and the buyer sign to agree on a sale
+// Fields are marshalled in declaration order, and the channel and chaincode names keep an offer from being replayed against another deployment
+// Seller and Buyer are client IDs as returned by ClientAccountID() of token-erc-20, Price is a base-10 integer string
+type SaleOffer struct {
+	Channel           string `json:"channel"`
+	PaymentChaincode  string `json:"paymentChaincode"`
+	DeliveryChaincode string `json:"deliveryChaincode"`
+	OfferID           string `json:"offerId"`
+	Seller            string `json:"seller"`
+	Buyer             string `json:"buyer"`
+	TokenID           string `json:"tokenId"`
+	Price             string `json:"price"`
+	Deadline          int64  `json:"deadline"`
+}
+
+// Settlement records the outcome of a sale offer
+// A settled offer is stored under offer~seller~offerID, a cancelled offer under cancellation~digest
+// Digest is the hex encoded SHA-256 hash of the JSON encoded SaleOffer the parties sign, CancelledBy the party that cancelled it
+type Settlement struct {
+	Digest      string `json:"digest"`
+	OfferID     string `json:"offerId"`
+	Seller      string `json:"seller"`
+	Buyer       string `json:"buyer"`
+	TokenID     string `json:"tokenId"`
+	Price       string `json:"price"`
+	Status      string `json:"status"`
+	CancelledBy string `json:"cancelledBy,omitempty"`
+	TxID        string `json:"txId"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+// RegisterCertificate records the X.509 certificate of the calling client, which Settle uses to verify the client's signatures
+// The certificate is taken from the submitting identity, so it has been validated against the channel MSP by the peer
+func (s *SmartContract) RegisterCertificate(ctx contractapi.TransactionContextInterface) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert == nil {
+		return fmt.Errorf("client identity has no X.509 certificate")
+	}
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); !ok {
+		return fmt.Errorf("client certificate does not hold an ECDSA public key")
+	}
+
+	certificateKey, err := ctx.GetStub().CreateCompositeKey(certificatePrefix, []string{clientID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", certificatePrefix, err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	err = ctx.GetStub().PutState(certificateKey, certPEM)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", certificateKey, err)
+	}
+
+	log.Printf("client %s registered its certificate", clientID)
+
+	return nil
+}
+
+// Settle atomically delivers the seller's NFT to the buyer against payment of the price to the seller
+// Both legs are TransferFrom calls made with the submitting client's identity, so the submitter must hold an allowance of at least
+// the price from the buyer in the payment chaincode, and be approved for the token or an operator of the seller in the delivery chaincode
+// The seller and the buyer sign the SHA-256 hash of the JSON encoded SaleOffer with the private key of the certificate registered with RegisterCertificate()
+// A party submitting the settlement itself does not need to sign it and can pass an empty signature
+// If either leg fails the whole transaction fails, so tokens never move without the NFT and the other way around
+// param {Number} deadline The unix time in seconds after which the offer can no longer be settled
+// param {String} sellerSignature The base64 encoded ASN.1 ECDSA signature of the seller
+// param {String} buyerSignature The base64 encoded ASN.1 ECDSA signature of the buyer
+// This function triggers a Settlement event
+func (s *SmartContract) Settle(ctx contractapi.TransactionContextInterface, offerID string, seller string, buyer string, tokenID string, price string, deadline int64, sellerSignature string, buyerSignature string) (*Settlement, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	offer, err := newSaleOffer(ctx, offerID, seller, buyer, tokenID, price, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the deadline against the transaction timestamp, which is the same on every endorsing peer
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	if txTimestamp.Seconds > deadline {
+		return nil, fmt.Errorf("offer %s expired at %d", offerID, deadline)
+	}
+
+	// An offer can be settled only once, and not after either party cancelled it
+	settlement, err := readSettlement(ctx, seller, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement != nil {
+		return nil, fmt.Errorf("offer %s of seller %s is already %s", offerID, seller, settlement.Status)
+	}
+
+	digest, err := offerDigest(offer)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation, err := readCancellation(ctx, hex.EncodeToString(digest[:]))
+	if err != nil {
+		return nil, err
+	}
+	if cancellation != nil {
+		return nil, fmt.Errorf("offer %s of seller %s is already %s", offerID, seller, cancellation.Status)
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// Both parties have to agree on the offer, either by signing it or by submitting it
+	if clientID != seller {
+		err = verifySignature(ctx, seller, digest, sellerSignature)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if clientID != buyer {
+		err = verifySignature(ctx, buyer, digest, buyerSignature)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// token-erc-721 identifies accounts by the decoded client ID
+	sellerAccount, err := base64.StdEncoding.DecodeString(seller)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode seller %s: %v", seller, err)
+	}
+	buyerAccount, err := base64.StdEncoding.DecodeString(buyer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode buyer %s: %v", buyer, err)
+	}
+
+	// Payment leg, the buyer pays the price to the seller
+	err = invokeTransferFrom(ctx, offer.PaymentChaincode, buyer, seller, offer.Price)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer payment: %v", err)
+	}
+
+	// Delivery leg, the seller delivers the NFT to the buyer
+	err = invokeTransferFrom(ctx, offer.DeliveryChaincode, string(sellerAccount), string(buyerAccount), tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer token: %v", err)
+	}
+
+	settlement = &Settlement{
+		Digest:    hex.EncodeToString(digest[:]),
+		OfferID:   offerID,
+		Seller:    seller,
+		Buyer:     buyer,
+		TokenID:   tokenID,
+		Price:     offer.Price,
+		Status:    OfferSettled,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: txTimestamp.Seconds,
+	}
+	err = putSettlement(ctx, settlement)
+	if err != nil {
+		return nil, err
+	}
+
+	// Events of the called chaincodes are not part of this transaction, the Settlement event stands for both transfers
+	settlementJSON, err := json.Marshal(settlement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("Settlement", settlementJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("offer %s settled: token %s delivered from %s to %s for %s", offerID, tokenID, seller, buyer, offer.Price)
+
+	return settlement, nil
+}
+
+// CancelOffer withdraws an offer, so it can no longer be settled even with valid signatures
+// Either the seller or the buyer of the offer can cancel it. The cancellation is keyed on the digest of the offer, so a party
+// can only cancel the offer it agreed to, and not other offers of the seller that share the offer ID
+// param {Number} deadline The deadline of the offer, as signed by the parties
+// This function triggers a Settlement event
+func (s *SmartContract) CancelOffer(ctx contractapi.TransactionContextInterface, offerID string, seller string, buyer string, tokenID string, price string, deadline int64) (*Settlement, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	offer, err := newSaleOffer(ctx, offerID, seller, buyer, tokenID, price, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	if clientID != seller && clientID != buyer {
+		return nil, fmt.Errorf("client is not authorized to cancel offer %s, only its seller or buyer can", offerID)
+	}
+
+	settlement, err := readSettlement(ctx, seller, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement != nil {
+		return nil, fmt.Errorf("offer %s of seller %s is already %s", offerID, seller, settlement.Status)
+	}
+
+	digest, err := offerDigest(offer)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellation, err := readCancellation(ctx, hex.EncodeToString(digest[:]))
+	if err != nil {
+		return nil, err
+	}
+	if cancellation != nil {
+		return nil, fmt.Errorf("offer %s of seller %s is already %s", offerID, seller, cancellation.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	cancellation = &Settlement{
+		Digest:      hex.EncodeToString(digest[:]),
+		OfferID:     offerID,
+		Seller:      seller,
+		Buyer:       buyer,
+		TokenID:     tokenID,
+		Price:       offer.Price,
+		Status:      OfferCancelled,
+		CancelledBy: clientID,
+		TxID:        ctx.GetStub().GetTxID(),
+		Timestamp:   txTimestamp.Seconds,
+	}
+	err = putCancellation(ctx, cancellation)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellationJSON, err := json.Marshal(cancellation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("Settlement", cancellationJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("offer %s of seller %s cancelled by %s", offerID, seller, clientID)
+
+	return cancellation, nil
+}
+
+// GetSettlement returns the settlement of the seller's offer, an error if the offer has not been settled
+func (s *SmartContract) GetSettlement(ctx contractapi.TransactionContextInterface, seller string, offerID string) (*Settlement, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	settlement, err := readSettlement(ctx, seller, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil {
+		return nil, fmt.Errorf("offer %s of seller %s is not settled", offerID, seller)
+	}
+
+	return settlement, nil
+}
+
+// GetCancellation returns the cancellation of the offer with the given digest, an error if the offer has not been cancelled
+// param {String} digest The hex encoded SHA-256 hash of the JSON encoded SaleOffer
+func (s *SmartContract) GetCancellation(ctx contractapi.TransactionContextInterface, digest string) (*Settlement, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	cancellation, err := readCancellation(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	if cancellation == nil {
+		return nil, fmt.Errorf("offer with digest %s is not cancelled", digest)
+	}
+
+	return cancellation, nil
+}
+
+// ClientAccountID returns the id of the requesting client's account, to be used as seller or buyer of an offer
+func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientAccountID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	return clientAccountID, nil
+}
+
+// Set the chaincodes to settle against and intialize contract.
+// param {String} paymentChaincode The name of the token-erc-20 chaincode on this channel
+// param {String} deliveryChaincode The name of the token-erc-721 chaincode on this channel
+func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface, paymentChaincode string, deliveryChaincode string) (bool, error) {
+
+	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to intitialize contract
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to initialize contract")
+	}
+
+	// Check contract options are not already set, client is not authorized to change them once intitialized
+	bytes, err := ctx.GetStub().GetState(paymentChaincodeKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to get payment chaincode: %v", err)
+	}
+	if bytes != nil {
+		return false, fmt.Errorf("contract options are already set, client is not authorized to change them")
+	}
+
+	if paymentChaincode == "" || deliveryChaincode == "" {
+		return false, fmt.Errorf("payment and delivery chaincode names must not be empty")
+	}
+
+	err = ctx.GetStub().PutState(paymentChaincodeKey, []byte(paymentChaincode))
+	if err != nil {
+		return false, fmt.Errorf("failed to set payment chaincode: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(deliveryChaincodeKey, []byte(deliveryChaincode))
+	if err != nil {
+		return false, fmt.Errorf("failed to set delivery chaincode: %v", err)
+	}
+
+	log.Printf("payment chaincode: %v, delivery chaincode: %v", paymentChaincode, deliveryChaincode)
+
+	return true, nil
+}
+
+// Helper Functions
+
+// invokeTransferFrom calls TransferFrom(from, to, value) of a chaincode on the same channel
+// The called chaincode sees the client identity that submitted this transaction, and its writes commit or fail together with this transaction
+func invokeTransferFrom(ctx contractapi.TransactionContextInterface, chaincodeName string, from string, to string, value string) error {
+	args := [][]byte{[]byte("TransferFrom"), []byte(from), []byte(to), []byte(value)}
+
+	response := ctx.GetStub().InvokeChaincode(chaincodeName, args, "")
+	if response.Status != shim.OK {
+		return fmt.Errorf("chaincode %s returned status %d: %s", chaincodeName, response.Status, response.Message)
+	}
+
+	return nil
+}
+
+// newSaleOffer validates the terms of a sale and returns the offer the parties sign for them on this deployment
+func newSaleOffer(ctx contractapi.TransactionContextInterface, offerID string, seller string, buyer string, tokenID string, price string, deadline int64) (SaleOffer, error) {
+	if offerID == "" || tokenID == "" {
+		return SaleOffer{}, fmt.Errorf("offer ID and token ID must not be empty")
+	}
+	if seller == buyer {
+		return SaleOffer{}, fmt.Errorf("seller and buyer cannot be the same")
+	}
+
+	value, ok := new(big.Int).SetString(price, 10)
+	if !ok || value.Sign() <= 0 {
+		return SaleOffer{}, fmt.Errorf("invalid price %q, it should be a positive base-10 integer", price)
+	}
+
+	paymentChaincode, err := ctx.GetStub().GetState(paymentChaincodeKey)
+	if err != nil {
+		return SaleOffer{}, fmt.Errorf("failed to get payment chaincode: %v", err)
+	}
+	deliveryChaincode, err := ctx.GetStub().GetState(deliveryChaincodeKey)
+	if err != nil {
+		return SaleOffer{}, fmt.Errorf("failed to get delivery chaincode: %v", err)
+	}
+
+	return SaleOffer{
+		Channel:           ctx.GetStub().GetChannelID(),
+		PaymentChaincode:  string(paymentChaincode),
+		DeliveryChaincode: string(deliveryChaincode),
+		OfferID:           offerID,
+		Seller:            seller,
+		Buyer:             buyer,
+		TokenID:           tokenID,
+		Price:             value.String(),
+		Deadline:          deadline,
+	}, nil
+}
+
+// offerDigest returns the SHA-256 hash of the JSON encoded offer, which the parties sign
+func offerDigest(offer SaleOffer) ([sha256.Size]byte, error) {
+	offerJSON, err := json.Marshal(offer)
+	if err != nil {
+		return [sha256.Size]byte{}, fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	return sha256.Sum256(offerJSON), nil
+}
+
+// readSettlement reads the settlement of the seller's offer, nil if the offer is still open
+func readSettlement(ctx contractapi.TransactionContextInterface, seller string, offerID string) (*Settlement, error) {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerPrefix, []string{seller, offerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", offerPrefix, err)
+	}
+
+	settlementBytes, err := ctx.GetStub().GetState(offerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offer %s from world state: %v", offerKey, err)
+	}
+	if settlementBytes == nil {
+		return nil, nil
+	}
+
+	settlement := new(Settlement)
+	err = json.Unmarshal(settlementBytes, settlement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal settlement of offer %s: %v", offerID, err)
+	}
+
+	return settlement, nil
+}
+
+// putSettlement writes the settlement of an offer
+func putSettlement(ctx contractapi.TransactionContextInterface, settlement *Settlement) error {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerPrefix, []string{settlement.Seller, settlement.OfferID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", offerPrefix, err)
+	}
+
+	settlementJSON, err := json.Marshal(settlement)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(offerKey, settlementJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", offerKey, err)
+	}
+
+	return nil
+}
+
+// readCancellation reads the cancellation of the offer with the hex encoded digest, nil if the offer was not cancelled
+func readCancellation(ctx contractapi.TransactionContextInterface, digest string) (*Settlement, error) {
+	cancellationKey, err := ctx.GetStub().CreateCompositeKey(cancellationPrefix, []string{digest})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", cancellationPrefix, err)
+	}
+
+	cancellationBytes, err := ctx.GetStub().GetState(cancellationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cancellation %s from world state: %v", cancellationKey, err)
+	}
+	if cancellationBytes == nil {
+		return nil, nil
+	}
+
+	cancellation := new(Settlement)
+	err = json.Unmarshal(cancellationBytes, cancellation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cancellation of offer %s: %v", digest, err)
+	}
+
+	return cancellation, nil
+}
+
+// putCancellation writes the cancellation of an offer
+func putCancellation(ctx contractapi.TransactionContextInterface, cancellation *Settlement) error {
+	cancellationKey, err := ctx.GetStub().CreateCompositeKey(cancellationPrefix, []string{cancellation.Digest})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", cancellationPrefix, err)
+	}
+
+	cancellationJSON, err := json.Marshal(cancellation)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(cancellationKey, cancellationJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", cancellationKey, err)
+	}
+
+	return nil
+}
+
+// verifySignature checks the base64 encoded ECDSA signature of the offer digest against the party's registered certificate
+func verifySignature(ctx contractapi.TransactionContextInterface, party string, digest [sha256.Size]byte, signature string) error {
+	certificateKey, err := ctx.GetStub().CreateCompositeKey(certificatePrefix, []string{party})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", certificatePrefix, err)
+	}
+
+	certPEM, err := ctx.GetStub().GetState(certificateKey)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate of %s from world state: %v", party, err)
+	}
+	if certPEM == nil {
+		return fmt.Errorf("%s has not registered a certificate", party)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("failed to decode certificate of %s", party)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate of %s: %v", party, err)
+	}
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certificate of %s does not hold an ECDSA public key", party)
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signatureBytes) {
+		return fmt.Errorf("invalid signature for %s", party)
+	}
+
+	return nil
+}
+
+// Checks that contract options have been already initialized
+func checkInitialized(ctx contractapi.TransactionContextInterface) (bool, error) {
+	paymentChaincode, err := ctx.GetStub().GetState(paymentChaincodeKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to get payment chaincode: %v", err)
+	}
+	if paymentChaincode == nil {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,360 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const seller = "x509::CN=seller,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
+const buyer = "x509::CN=buyer,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org2.example.com,O=org2.example.com,L=Hursley,ST=Hampshire,C=UK"
+const agent = "x509::CN=agent,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org2.example.com,O=org2.example.com,L=Hursley,ST=Hampshire,C=UK"
+
+var seller64 = base64.StdEncoding.EncodeToString([]byte(seller))
+var buyer64 = base64.StdEncoding.EncodeToString([]byte(buyer))
+var agent64 = base64.StdEncoding.EncodeToString([]byte(agent))
+
+// The transaction timestamp of the tests, offers expire after it
+const txTimestamp = int64(1700000000)
+
+type MockStub struct {
+	shim.ChaincodeStubInterface
+	mock.Mock
+}
+
+func (ms *MockStub) GetState(key string) ([]byte, error) {
+	args := ms.Called(key)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (ms *MockStub) PutState(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) SetEvent(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	called := ms.Called(chaincodeName, args, channel)
+	return called.Get(0).(peer.Response)
+}
+
+func (ms *MockStub) GetChannelID() string {
+	args := ms.Called()
+	return args.String(0)
+}
+
+func (ms *MockStub) GetTxID() string {
+	args := ms.Called()
+	return args.String(0)
+}
+
+func (ms *MockStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	args := ms.Called()
+	return args.Get(0).(*timestamppb.Timestamp), args.Error(1)
+}
+
+// CreateCompositeKey uses the key format of the peer, so that the tests can build the keys the contract reads
+func (ms *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+type MockClientIdentity struct {
+	cid.ClientIdentity
+	mock.Mock
+}
+
+func (mci *MockClientIdentity) GetID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetMSPID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	args := mci.Called()
+	return args.Get(0).(*x509.Certificate), args.Error(1)
+}
+
+type MockContext struct {
+	contractapi.TransactionContextInterface
+	mock.Mock
+}
+
+func (mc *MockContext) GetStub() shim.ChaincodeStubInterface {
+	args := mc.Called()
+	return args.Get(0).(*MockStub)
+}
+
+func (mc *MockContext) GetClientIdentity() cid.ClientIdentity {
+	args := mc.Called()
+	return args.Get(0).(*MockClientIdentity)
+}
+
+// setupStub returns a context for the client with the given ID of a contract initialized with the erc20 and erc721 chaincodes
+// The state read by a test is mocked by the test with mockState, and the responses of the chaincodes with mockTransfers
+func setupStub(clientID string) (*MockContext, *MockStub) {
+	anyString := mock.AnythingOfType("string")
+	anyUint8Slice := mock.AnythingOfType("[]uint8")
+
+	ms := new(MockStub)
+
+	ms.On("GetState", paymentChaincodeKey).Return([]byte("erc20"), nil)
+	ms.On("GetState", deliveryChaincodeKey).Return([]byte("erc721"), nil)
+
+	ms.On("PutState", anyString, anyUint8Slice).Return(nil)
+	ms.On("SetEvent", anyString, anyUint8Slice).Return(nil)
+
+	ms.On("GetChannelID").Return("mychannel")
+	ms.On("GetTxID").Return("tx1")
+	ms.On("GetTxTimestamp").Return(&timestamppb.Timestamp{Seconds: txTimestamp}, nil)
+
+	mci := new(MockClientIdentity)
+	mci.On("GetID").Return(clientID, nil)
+	mci.On("GetMSPID").Return("Org2MSP", nil)
+
+	mc := new(MockContext)
+	mc.On("GetStub").Return(ms)
+	mc.On("GetClientIdentity").Return(mci)
+	return mc, ms
+}
+
+// mockState mocks the value of a key, an empty value mocks a key that is not in the world state
+func mockState(ms *MockStub, key string, value string) {
+	if value == "" {
+		ms.On("GetState", key).Return([]byte(nil), nil)
+		return
+	}
+	ms.On("GetState", key).Return([]byte(value), nil)
+}
+
+// mockTransfers mocks the responses of the erc20 and erc721 chaincodes to TransferFrom
+func mockTransfers(ms *MockStub, paymentResponse peer.Response, deliveryResponse peer.Response) {
+	ms.On("InvokeChaincode", "erc20", mock.Anything, "").Return(paymentResponse)
+	ms.On("InvokeChaincode", "erc721", mock.Anything, "").Return(deliveryResponse)
+}
+
+// mockParties mocks the certificates registered by the seller and the buyer, and an open offer
+func mockParties(ms *MockStub, offer SaleOffer, sellerCert *x509.Certificate, buyerCert *x509.Certificate) {
+	digest, _ := offerDigest(offer)
+	mockState(ms, compositeKey(certificatePrefix, seller64), encodeCertificate(sellerCert))
+	mockState(ms, compositeKey(certificatePrefix, buyer64), encodeCertificate(buyerCert))
+	mockState(ms, compositeKey(offerPrefix, seller64, offer.OfferID), "")
+	mockState(ms, compositeKey(cancellationPrefix, hex.EncodeToString(digest[:])), "")
+}
+
+func compositeKey(objectType string, attributes ...string) string {
+	key, _ := shim.CreateCompositeKey(objectType, attributes)
+	return key
+}
+
+// newCertificate returns a self-signed certificate and its private key
+func newCertificate(commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Unix(txTimestamp, 0).Add(-time.Hour),
+		NotAfter:     time.Unix(txTimestamp, 0).Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func encodeCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func signOffer(key *ecdsa.PrivateKey, offer SaleOffer) string {
+	digest, _ := offerDigest(offer)
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func newOffer(offerID string) SaleOffer {
+	return SaleOffer{
+		Channel:           "mychannel",
+		PaymentChaincode:  "erc20",
+		DeliveryChaincode: "erc721",
+		OfferID:           offerID,
+		Seller:            seller64,
+		Buyer:             buyer64,
+		TokenID:           "101",
+		Price:             "100",
+		Deadline:          txTimestamp + 100,
+	}
+}
+
+func settle(c *SmartContract, ctx *MockContext, offer SaleOffer, sellerSignature string, buyerSignature string) (*Settlement, error) {
+	return c.Settle(ctx, offer.OfferID, offer.Seller, offer.Buyer, offer.TokenID, offer.Price, offer.Deadline, sellerSignature, buyerSignature)
+}
+
+// putStateValue returns the last value the contract wrote to the key
+func putStateValue(ms *MockStub, key string) []byte {
+	var value []byte
+	for _, call := range ms.Calls {
+		if call.Method == "PutState" && call.Arguments.String(0) == key {
+			value = call.Arguments.Get(1).([]byte)
+		}
+	}
+	return value
+}
+
+func TestRegisterCertificate(t *testing.T) {
+	transactionContext, chaincodeStub := setupStub(seller64)
+	c := new(SmartContract)
+
+	sellerCert, _ := newCertificate("seller")
+	transactionContext.GetClientIdentity().(*MockClientIdentity).On("GetX509Certificate").Return(sellerCert, nil)
+
+	err := c.RegisterCertificate(transactionContext)
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(certificatePrefix, seller64), []byte(encodeCertificate(sellerCert)))
+}
+
+func TestSettle(t *testing.T) {
+	c := new(SmartContract)
+	sellerCert, sellerKey := newCertificate("seller")
+	buyerCert, buyerKey := newCertificate("buyer")
+	offer := newOffer("o1")
+
+	transactionContext, chaincodeStub := setupStub(agent64)
+	mockTransfers(chaincodeStub, shim.Success(nil), shim.Success(nil))
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	// The buyer signature must be made with the key of the certificate the buyer registered
+	_, err := settle(c, transactionContext, offer, signOffer(sellerKey, offer), signOffer(sellerKey, offer))
+	assert.EqualError(t, err, "invalid signature for "+buyer64)
+
+	// A signature of other terms does not settle the offer
+	tampered := offer
+	tampered.Price = "10"
+	mockParties(chaincodeStub, tampered, sellerCert, buyerCert)
+	_, err = settle(c, transactionContext, tampered, signOffer(sellerKey, offer), signOffer(buyerKey, offer))
+	assert.EqualError(t, err, "invalid signature for "+seller64)
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", mock.Anything, mock.Anything, mock.Anything)
+
+	settlement, err := settle(c, transactionContext, offer, signOffer(sellerKey, offer), signOffer(buyerKey, offer))
+	assert.NoError(t, err)
+	assert.Equal(t, OfferSettled, settlement.Status)
+	chaincodeStub.AssertCalled(t, "InvokeChaincode", "erc20", [][]byte{[]byte("TransferFrom"), []byte(buyer64), []byte(seller64), []byte("100")}, "")
+	chaincodeStub.AssertCalled(t, "InvokeChaincode", "erc721", [][]byte{[]byte("TransferFrom"), []byte(seller), []byte(buyer), []byte("101")}, "")
+
+	settlementJSON, _ := json.Marshal(settlement)
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(offerPrefix, seller64, "o1"), settlementJSON)
+	chaincodeStub.AssertCalled(t, "SetEvent", "Settlement", settlementJSON)
+
+	// Replaying the signed offer after the settlement is rejected before any transfer
+	settledValue := putStateValue(chaincodeStub, compositeKey(offerPrefix, seller64, "o1"))
+	transactionContext, chaincodeStub = setupStub(agent64)
+	mockState(chaincodeStub, compositeKey(offerPrefix, seller64, "o1"), string(settledValue))
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err = settle(c, transactionContext, offer, signOffer(sellerKey, offer), signOffer(buyerKey, offer))
+	assert.EqualError(t, err, "offer o1 of seller "+seller64+" is already Settled")
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", mock.Anything, mock.Anything, mock.Anything)
+
+	// A party submitting the settlement itself does not sign it
+	transactionContext, chaincodeStub = setupStub(buyer64)
+	mockTransfers(chaincodeStub, shim.Success(nil), shim.Success(nil))
+	offer = newOffer("o2")
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err = settle(c, transactionContext, offer, signOffer(sellerKey, offer), "")
+	assert.NoError(t, err)
+
+	// An offer cannot be settled after its deadline
+	offer = newOffer("o3")
+	offer.Deadline = txTimestamp - 1
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err = settle(c, transactionContext, offer, signOffer(sellerKey, offer), "")
+	assert.EqualError(t, err, "offer o3 expired at 1699999999")
+}
+
+func TestSettleLegFailure(t *testing.T) {
+	c := new(SmartContract)
+	sellerCert, sellerKey := newCertificate("seller")
+	buyerCert, buyerKey := newCertificate("buyer")
+	offer := newOffer("o1")
+
+	// The payment leg fails, the delivery leg is not attempted and the offer stays open
+	transactionContext, chaincodeStub := setupStub(agent64)
+	mockTransfers(chaincodeStub, shim.Error("spender does not have enough allowance for transfer"), shim.Success(nil))
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err := settle(c, transactionContext, offer, signOffer(sellerKey, offer), signOffer(buyerKey, offer))
+	assert.EqualError(t, err, "failed to transfer payment: chaincode erc20 returned status 500: spender does not have enough allowance for transfer")
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", "erc721", mock.Anything, mock.Anything)
+	chaincodeStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+	chaincodeStub.AssertNotCalled(t, "SetEvent", mock.Anything, mock.Anything)
+
+	// The delivery leg fails after the payment leg, the transaction fails and the payment is not committed either
+	transactionContext, chaincodeStub = setupStub(agent64)
+	mockTransfers(chaincodeStub, shim.Success(nil), shim.Error("the sender is not the current owner nor an authorized operator"))
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err = settle(c, transactionContext, offer, signOffer(sellerKey, offer), signOffer(buyerKey, offer))
+	assert.EqualError(t, err, "failed to transfer token: chaincode erc721 returned status 500: the sender is not the current owner nor an authorized operator")
+	chaincodeStub.AssertCalled(t, "InvokeChaincode", "erc20", mock.Anything, "")
+	chaincodeStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+	chaincodeStub.AssertNotCalled(t, "SetEvent", mock.Anything, mock.Anything)
+}
+
+func TestCancelOffer(t *testing.T) {
+	c := new(SmartContract)
+	sellerCert, sellerKey := newCertificate("seller")
+	buyerCert, buyerKey := newCertificate("buyer")
+	offer := newOffer("o1")
+	digest, _ := offerDigest(offer)
+	cancellationKey := compositeKey(cancellationPrefix, hex.EncodeToString(digest[:]))
+
+	transactionContext, chaincodeStub := setupStub(agent64)
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err := c.CancelOffer(transactionContext, offer.OfferID, offer.Seller, offer.Buyer, offer.TokenID, offer.Price, offer.Deadline)
+	assert.EqualError(t, err, "client is not authorized to cancel offer o1, only its seller or buyer can")
+
+	transactionContext, chaincodeStub = setupStub(buyer64)
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	cancellation, err := c.CancelOffer(transactionContext, offer.OfferID, offer.Seller, offer.Buyer, offer.TokenID, offer.Price, offer.Deadline)
+	assert.NoError(t, err)
+	assert.Equal(t, OfferCancelled, cancellation.Status)
+	assert.Equal(t, buyer64, cancellation.CancelledBy)
+
+	// The cancelled offer cannot be settled, even with valid signatures
+	cancelledValue := putStateValue(chaincodeStub, cancellationKey)
+	transactionContext, chaincodeStub = setupStub(agent64)
+	mockState(chaincodeStub, cancellationKey, string(cancelledValue))
+	mockParties(chaincodeStub, offer, sellerCert, buyerCert)
+
+	_, err = settle(c, transactionContext, offer, signOffer(sellerKey, offer), signOffer(buyerKey, offer))
+	assert.EqualError(t, err, "offer o1 of seller "+seller64+" is already Cancelled")
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", mock.Anything, mock.Anything, mock.Anything)
+}
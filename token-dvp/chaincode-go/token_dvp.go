@@ -0,0 +1,23 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/token-dvp/chaincode-go/chaincode"
+)
+
+func main() {
+	dvpChaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	if err != nil {
+		log.Panicf("Error creating token-dvp chaincode: %v", err)
+	}
+
+	if err := dvpChaincode.Start(); err != nil {
+		log.Panicf("Error starting token-dvp chaincode: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const adminPrefix = "admin~account"
+
+// adminRole is the role reported in the RoleGranted and RoleRevoked events of contract admins
+const adminRole = "ADMIN"
+
+// adminCountKey holds the number of contract admins. A contract initialized before admins were recorded on the ledger
+// has no count, its admin role falls back to the clients of minterMSPID until the first admin is granted
+const adminCountKey = "adminCount"
+
+// RoleGranted MUST emit when account is given the contract admin role, Sender is the client that granted the role
+type RoleGranted struct {
+	Role    string `json:"role"`
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// RoleRevoked MUST emit when the contract admin role is removed from account, Sender is the client that revoked the role
+type RoleRevoked struct {
+	Role    string `json:"role"`
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// GrantAdmin gives account the contract admin role. Admins mint and burn token types without a creator,
+// create token types, set the URI and register receiver contracts.
+// Only an admin can grant the admin role.
+// This function emits a RoleGranted event
+func (s *SmartContract) GrantAdmin(ctx contractapi.TransactionContextInterface, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = authorizationHelper(ctx)
+	if err != nil {
+		return err
+	}
+
+	if account == "" || account == "0x0" {
+		return fmt.Errorf("admin account must not be empty or the zero address")
+	}
+
+	isAdmin, err := isAdminHelper(ctx, account)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return fmt.Errorf("account %s is already an admin", account)
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = addAdminHelper(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	return emitRoleGrantedHelper(ctx, account, sender)
+}
+
+// RevokeAdmin removes the contract admin role from account. The last admin can not be revoked,
+// so that the contract always has an admin.
+// This function emits a RoleRevoked event
+func (s *SmartContract) RevokeAdmin(ctx contractapi.TransactionContextInterface, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = authorizationHelper(ctx)
+	if err != nil {
+		return err
+	}
+
+	isAdmin, err := isAdminHelper(ctx, account)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return fmt.Errorf("account %s is not an admin", account)
+	}
+
+	adminCount, err := adminCountHelper(ctx)
+	if err != nil {
+		return err
+	}
+	if adminCount <= 1 {
+		return fmt.Errorf("the last admin can not be revoked")
+	}
+
+	adminKey, err := ctx.GetStub().CreateCompositeKey(adminPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", adminPrefix, err)
+	}
+
+	err = ctx.GetStub().DelState(adminKey)
+	if err != nil {
+		return fmt.Errorf("failed to revoke admin role of account %s: %v", account, err)
+	}
+
+	err = setAdminCount(ctx, adminCount-1)
+	if err != nil {
+		return err
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// Emit RoleRevoked event
+	roleRevokedEvent := RoleRevoked{adminRole, account, sender}
+	roleRevokedEventJSON, err := json.Marshal(roleRevokedEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("RoleRevoked", roleRevokedEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// IsAdmin returns true if account has the contract admin role
+func (s *SmartContract) IsAdmin(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return isAdminHelper(ctx, account)
+}
+
+// Helper Functions
+
+// isAdminHelper returns true if account has the contract admin role
+func isAdminHelper(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+	adminKey, err := ctx.GetStub().CreateCompositeKey(adminPrefix, []string{account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", adminPrefix, err)
+	}
+
+	adminBytes, err := ctx.GetStub().GetState(adminKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read admin role of account %s from world state: %v", account, err)
+	}
+
+	return adminBytes != nil, nil
+}
+
+// addAdminHelper gives account the contract admin role, granting it again has no effect
+func addAdminHelper(ctx contractapi.TransactionContextInterface, account string) error {
+	isAdmin, err := isAdminHelper(ctx, account)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return nil
+	}
+
+	adminKey, err := ctx.GetStub().CreateCompositeKey(adminPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", adminPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(adminKey, []byte("true"))
+	if err != nil {
+		return fmt.Errorf("failed to grant admin role to account %s: %v", account, err)
+	}
+
+	adminCount, err := adminCountHelper(ctx)
+	if err != nil {
+		return err
+	}
+
+	return setAdminCount(ctx, adminCount+1)
+}
+
+// emitRoleGrantedHelper emits a RoleGranted event for the contract admin role of account
+func emitRoleGrantedHelper(ctx contractapi.TransactionContextInterface, account string, sender string) error {
+	roleGrantedEvent := RoleGranted{adminRole, account, sender}
+	roleGrantedEventJSON, err := json.Marshal(roleGrantedEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("RoleGranted", roleGrantedEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// adminCountHelper returns the number of contract admins, 0 if no admin was ever recorded
+func adminCountHelper(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	adminCountBytes, err := ctx.GetStub().GetState(adminCountKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read admin count from world state: %v", err)
+	}
+	if adminCountBytes == nil {
+		return 0, nil
+	}
+
+	adminCount, err := strconv.ParseUint(string(adminCountBytes), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read admin count: %v", err)
+	}
+
+	return adminCount, nil
+}
+
+// setAdminCount writes the number of contract admins
+func setAdminCount(ctx contractapi.TransactionContextInterface, adminCount uint64) error {
+	err := ctx.GetStub().PutState(adminCountKey, []byte(strconv.FormatUint(adminCount, 10)))
+	if err != nil {
+		return fmt.Errorf("failed to update admin count: %v", err)
+	}
+
+	return nil
+}
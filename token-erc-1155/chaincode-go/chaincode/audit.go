@@ -0,0 +1,175 @@
+package chaincode
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TokenSupplyAudit is the audit result of one token type
+type TokenSupplyAudit struct {
+	ID          uint64 `json:"id"`
+	TotalSupply uint64 `json:"totalSupply"`
+	Balances    uint64 `json:"balances"`
+}
+
+// SupplyAudit is the result of AuditSupply
+// Consistent is true when the balances of every token type add up to its total supply and no anomalies were found
+type SupplyAudit struct {
+	Tokens     []*TokenSupplyAudit `json:"tokens"`
+	Consistent bool                `json:"consistent"`
+	Anomalies  []string            `json:"anomalies"`
+}
+
+// AuditSupply checks the supply invariant by iterating every balance record in the world state
+// For every token type the sum of the account balances must equal its total supply
+// Token types minted before the total supply was tracked are reported as anomalies, also once burns exceeded their total supply
+// This is an evaluate transaction, it reads the whole token state and should not be submitted for ordering
+func (s *SmartContract) AuditSupply(ctx contractapi.TransactionContextInterface) (*SupplyAudit, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	anomalies := []string{}
+	balances := make(map[uint64]uint64)      // token id -> sum of balances
+	totalSupplies := make(map[uint64]uint64) // token id -> recorded total supply
+
+	balanceIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balancePrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state for prefix %v: %v", balancePrefix, err)
+	}
+	defer balanceIterator.Close()
+
+	for balanceIterator.HasNext() {
+		queryResponse, err := balanceIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the next state for prefix %v: %v", balancePrefix, err)
+		}
+
+		// composite key is expected to be account.tokenId.sender
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 3 {
+			anomalies = append(anomalies, fmt.Sprintf("balance %q does not have three parts (account:tokenId:sender)", queryResponse.Key))
+			continue
+		}
+
+		id, err := strconv.ParseUint(compositeKeyParts[1], 10, 64)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("balance of account %s has an invalid token id %q", compositeKeyParts[0], compositeKeyParts[1]))
+			continue
+		}
+
+		amount, err := strconv.ParseUint(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("balance of account %s for token %v has an invalid amount %q", compositeKeyParts[0], id, string(queryResponse.Value)))
+			continue
+		}
+
+		balances[id], err = add(balances[id], amount)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("balances of token %v: %v", id, err))
+		}
+	}
+
+	totalSupplyIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(totalSupplyPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state for prefix %v: %v", totalSupplyPrefix, err)
+	}
+	defer totalSupplyIterator.Close()
+
+	for totalSupplyIterator.HasNext() {
+		queryResponse, err := totalSupplyIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the next state for prefix %v: %v", totalSupplyPrefix, err)
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 1 {
+			anomalies = append(anomalies, fmt.Sprintf("total supply %q does not have one part (tokenId)", queryResponse.Key))
+			continue
+		}
+
+		id, err := strconv.ParseUint(compositeKeyParts[0], 10, 64)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("total supply has an invalid token id %q", compositeKeyParts[0]))
+			continue
+		}
+
+		totalSupply, err := strconv.ParseUint(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("total supply of token %v has an invalid amount %q", id, string(queryResponse.Value)))
+			continue
+		}
+
+		totalSupplies[id] = totalSupply
+	}
+
+	// Burns beyond the recorded total supply were clamped, the supply history of these token types is not consistent
+	shortfallIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(supplyShortfallPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state for prefix %v: %v", supplyShortfallPrefix, err)
+	}
+	defer shortfallIterator.Close()
+
+	for shortfallIterator.HasNext() {
+		queryResponse, err := shortfallIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the next state for prefix %v: %v", supplyShortfallPrefix, err)
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 1 {
+			anomalies = append(anomalies, fmt.Sprintf("supply shortfall %q does not have one part (tokenId)", queryResponse.Key))
+			continue
+		}
+
+		anomalies = append(anomalies, fmt.Sprintf("burns of token %s exceeded its recorded total supply by %s", compositeKeyParts[0], string(queryResponse.Value)))
+	}
+
+	// Every token type with either balances or a total supply is audited
+	for id := range totalSupplies {
+		if _, ok := balances[id]; !ok {
+			balances[id] = 0
+		}
+	}
+
+	// Copy the map keys and sort it. This is necessary because iterating maps in Go is not deterministic
+	tokens := []*TokenSupplyAudit{}
+	for _, id := range sortedKeys(balances) {
+		totalSupply, tracked := totalSupplies[id]
+		if !tracked {
+			anomalies = append(anomalies, fmt.Sprintf("token %v has balances adding up to %v but no recorded total supply", id, balances[id]))
+		} else if balances[id] != totalSupply {
+			anomalies = append(anomalies, fmt.Sprintf("balances of token %v add up to %v, total supply is %v", id, balances[id], totalSupply))
+		}
+
+		tokens = append(tokens, &TokenSupplyAudit{id, totalSupply, balances[id]})
+	}
+
+	audit := &SupplyAudit{
+		Tokens:     tokens,
+		Consistent: len(anomalies) == 0,
+		Anomalies:  anomalies,
+	}
+
+	log.Printf("supply audit: %+v", audit)
+
+	return audit, nil
+}
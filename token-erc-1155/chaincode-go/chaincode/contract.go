@@ -20,7 +20,10 @@ const uriKey = "uri"
 
 const balancePrefix = "account~tokenId~sender"
 const approvalPrefix = "account~operator"
+const totalSupplyPrefix = "totalSupply~tokenId"
+const supplyShortfallPrefix = "supplyShortfall~tokenId"
 
+// minterMSPID is the organization that initializes the contract, its client that calls Initialize becomes the first admin
 const minterMSPID = "Org1MSP"
 
 // Define key names for options
@@ -126,18 +129,18 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, accoun
 		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
-	err = authorizationHelper(ctx)
-	if err != nil {
-		return err
-	}
-
 	// Get ID of submitting client identity
 	operator, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
+	// Check minter authorization - the creator of the token type and its authorized minters, or the admins for an id without a token type
+	err = mintAuthorizationHelper(ctx, operator, id, amount)
+	if err != nil {
+		return err
+	}
+
 	// Mint tokens
 	err = mintHelper(ctx, operator, account, id, amount)
 	if err != nil {
@@ -166,12 +169,6 @@ func (s *SmartContract) MintBatch(ctx contractapi.TransactionContextInterface, a
 		return fmt.Errorf("ids and amounts must have the same length")
 	}
 
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
-	err = authorizationHelper(ctx)
-	if err != nil {
-		return err
-	}
-
 	// Get ID of submitting client identity
 	operator, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
@@ -191,6 +188,14 @@ func (s *SmartContract) MintBatch(ctx contractapi.TransactionContextInterface, a
 	// Copy the map keys and sort it. This is necessary because iterating maps in Go is not deterministic
 	amountToSendKeys := sortedKeys(amountToSend)
 
+	// Check minter authorization of every token id before minting any of them
+	for _, id := range amountToSendKeys {
+		err = mintAuthorizationHelper(ctx, operator, id, amountToSend[id])
+		if err != nil {
+			return err
+		}
+	}
+
 	// Mint tokens
 	for _, id := range amountToSendKeys {
 		amount := amountToSend[id]
@@ -240,6 +245,11 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, accoun
 		return err
 	}
 
+	err = decreaseTotalSupply(ctx, []uint64{id}, []uint64{amount})
+	if err != nil {
+		return err
+	}
+
 	transferSingleEvent := TransferSingle{operator, account, "0x0", id, amount}
 	return emitTransferSingle(ctx, transferSingleEvent)
 }
@@ -282,6 +292,11 @@ func (s *SmartContract) BurnBatch(ctx contractapi.TransactionContextInterface, a
 		return err
 	}
 
+	err = decreaseTotalSupply(ctx, ids, amounts)
+	if err != nil {
+		return err
+	}
+
 	transferBatchEvent := TransferBatch{operator, account, "0x0", ids, amounts}
 	return emitTransferBatch(ctx, transferBatchEvent)
 }
@@ -630,6 +645,22 @@ func (s *SmartContract) BalanceOfBatch(ctx contractapi.TransactionContextInterfa
 	return balances, nil
 }
 
+// TotalSupply returns the amount of tokens of token type id in existence
+// Token types minted before the total supply was tracked report only the supply minted and burned since
+func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface, id uint64) (uint64, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return totalSupplyHelper(ctx, id)
+}
+
 // ClientAccountBalance returns the balance of the requesting client's account
 func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface, id uint64) (uint64, error) {
 
@@ -705,7 +736,7 @@ func (s *SmartContract) SetURI(ctx contractapi.TransactionContextInterface, uri
 	return nil
 }
 
-// URI returns the URI
+// URI returns the URI of token type id, or the URI of the contract if the token type has none
 func (s *SmartContract) URI(ctx contractapi.TransactionContextInterface, id uint64) (string, error) {
 
 	// Check if contract has been intilized first
@@ -717,6 +748,15 @@ func (s *SmartContract) URI(ctx contractapi.TransactionContextInterface, id uint
 		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
+	// The URI of a token type takes precedence over the URI of the contract
+	tokenType, err := tokenTypeHelper(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if tokenType != nil && tokenType.URI != "" {
+		return tokenType.URI, nil
+	}
+
 	uriBytes, err := ctx.GetStub().GetState(uriKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to get uri: %v", err)
@@ -804,6 +844,7 @@ func (s *SmartContract) Symbol(ctx contractapi.TransactionContextInterface) (str
 // Set information for a token and intialize contract.
 // param {String} name The name of the token
 // param {String} symbol The symbol of the token
+// This function emits a RoleGranted event for the first admin
 func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string) (bool, error) {
 
 	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to intitialize contract
@@ -834,20 +875,59 @@ func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface,
 		return false, fmt.Errorf("failed to set symbol: %v", err)
 	}
 
+	// The initializing client becomes the first contract admin
+	admin, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = addAdminHelper(ctx, admin)
+	if err != nil {
+		return false, err
+	}
+
+	err = emitRoleGrantedHelper(ctx, admin, admin)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
 // Helper Functions
 
-// authorizationHelper checks minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
+// authorizationHelper checks that the client has the contract admin role, which is managed on the ledger with GrantAdmin and RevokeAdmin
+// A contract initialized before admins were recorded on the ledger falls back to the clients of minterMSPID until the first admin is granted
 func authorizationHelper(ctx contractapi.TransactionContextInterface) error {
 
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	adminCount, err := adminCountHelper(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get MSPID: %v", err)
+		return err
 	}
-	if clientMSPID != minterMSPID {
-		return fmt.Errorf("client is not authorized to mint new tokens")
+
+	if adminCount == 0 {
+		clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+		if err != nil {
+			return fmt.Errorf("failed to get MSPID: %v", err)
+		}
+		if clientMSPID != minterMSPID {
+			return fmt.Errorf("client is not authorized to mint new tokens")
+		}
+
+		return nil
+	}
+
+	client, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	isAdmin, err := isAdminHelper(ctx, client)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return fmt.Errorf("client is not an admin of the contract")
 	}
 
 	return nil
@@ -867,7 +947,7 @@ func mintHelper(ctx contractapi.TransactionContextInterface, operator string, ac
 		return err
 	}
 
-	return nil
+	return increaseTotalSupply(ctx, id, amount)
 }
 
 func addBalance(ctx contractapi.TransactionContextInterface, sender string, recipient string, id uint64, amount uint64) error {
@@ -1013,6 +1093,138 @@ func removeBalance(ctx contractapi.TransactionContextInterface, sender string, i
 	return nil
 }
 
+// totalSupplyHelper returns the total supply of token type id, 0 if it was never minted
+func totalSupplyHelper(ctx contractapi.TransactionContextInterface, id uint64) (uint64, error) {
+	totalSupplyKey, err := ctx.GetStub().CreateCompositeKey(totalSupplyPrefix, []string{strconv.FormatUint(id, 10)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the composite key for prefix %s: %v", totalSupplyPrefix, err)
+	}
+
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read total supply of token %v from world state: %v", id, err)
+	}
+	if totalSupplyBytes == nil {
+		return 0, nil
+	}
+
+	totalSupply, err := strconv.ParseUint(string(totalSupplyBytes), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read total supply of token %v: %v", id, err)
+	}
+
+	return totalSupply, nil
+}
+
+// setTotalSupply writes the total supply of token type id
+func setTotalSupply(ctx contractapi.TransactionContextInterface, id uint64, totalSupply uint64) error {
+	totalSupplyKey, err := ctx.GetStub().CreateCompositeKey(totalSupplyPrefix, []string{strconv.FormatUint(id, 10)})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", totalSupplyPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(totalSupplyKey, []byte(strconv.FormatUint(totalSupply, 10)))
+	if err != nil {
+		return fmt.Errorf("failed to update total supply of token %v: %v", id, err)
+	}
+
+	return nil
+}
+
+// increaseTotalSupply adds minted tokens to the total supply of token type id
+func increaseTotalSupply(ctx contractapi.TransactionContextInterface, id uint64, amount uint64) error {
+	totalSupply, err := totalSupplyHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	totalSupply, err = add(totalSupply, amount)
+	if err != nil {
+		return err
+	}
+
+	return setTotalSupply(ctx, id, totalSupply)
+}
+
+// decreaseTotalSupply removes burned tokens from the total supply of each token type
+// The total supply of a token type minted before it was tracked can not drop below 0, it is left at 0
+func decreaseTotalSupply(ctx contractapi.TransactionContextInterface, ids []uint64, amounts []uint64) error {
+	// Group amount by token id because the total supply of a token can only be written once in a transaction
+	burnedAmounts := make(map[uint64]uint64) // token id -> burned amount
+	var err error
+
+	for i := 0; i < len(amounts); i++ {
+		burnedAmounts[ids[i]], err = add(burnedAmounts[ids[i]], amounts[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Copy the map keys and sort it. This is necessary because iterating maps in Go is not deterministic
+	for _, id := range sortedKeys(burnedAmounts) {
+		totalSupply, err := totalSupplyHelper(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// Tokens minted before the total supply was tracked can burn more than their recorded total supply.
+		// The total supply is clamped at 0 so that they can still be burned, and the shortfall is recorded for AuditSupply to report
+		if burnedAmounts[id] > totalSupply {
+			err = increaseSupplyShortfall(ctx, id, burnedAmounts[id]-totalSupply)
+			if err != nil {
+				return err
+			}
+
+			burnedAmounts[id] = totalSupply
+		}
+
+		totalSupply, err = sub(totalSupply, burnedAmounts[id])
+		if err != nil {
+			return err
+		}
+
+		err = setTotalSupply(ctx, id, totalSupply)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// increaseSupplyShortfall adds to the amount of token type id that was burned beyond its recorded total supply
+func increaseSupplyShortfall(ctx contractapi.TransactionContextInterface, id uint64, amount uint64) error {
+	supplyShortfallKey, err := ctx.GetStub().CreateCompositeKey(supplyShortfallPrefix, []string{strconv.FormatUint(id, 10)})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", supplyShortfallPrefix, err)
+	}
+
+	supplyShortfallBytes, err := ctx.GetStub().GetState(supplyShortfallKey)
+	if err != nil {
+		return fmt.Errorf("failed to read supply shortfall of token %v from world state: %v", id, err)
+	}
+
+	var supplyShortfall uint64
+	if supplyShortfallBytes != nil {
+		supplyShortfall, err = strconv.ParseUint(string(supplyShortfallBytes), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to read supply shortfall of token %v: %v", id, err)
+		}
+	}
+
+	supplyShortfall, err = add(supplyShortfall, amount)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(supplyShortfallKey, []byte(strconv.FormatUint(supplyShortfall, 10)))
+	if err != nil {
+		return fmt.Errorf("failed to update supply shortfall of token %v: %v", id, err)
+	}
+
+	return nil
+}
+
 func emitTransferSingle(ctx contractapi.TransactionContextInterface, transferSingleEvent TransferSingle) error {
 	transferSingleEventJSON, err := json.Marshal(transferSingleEvent)
 	if err != nil {
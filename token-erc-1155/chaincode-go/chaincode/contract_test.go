@@ -0,0 +1,426 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const admin = "x509::CN=admin,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
+const holder = "x509::CN=holder,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org2.example.com,O=org2.example.com,L=Hursley,ST=Hampshire,C=UK"
+const operator = "x509::CN=operator,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org2.example.com,O=org2.example.com,L=Hursley,ST=Hampshire,C=UK"
+
+var admin64 = base64.StdEncoding.EncodeToString([]byte(admin))
+var holder64 = base64.StdEncoding.EncodeToString([]byte(holder))
+var operator64 = base64.StdEncoding.EncodeToString([]byte(operator))
+
+// receiver is an account owned by the chaincode receiverChaincode
+const receiver = "receiver-account"
+const receiverChaincode = "receiver"
+
+type MockStub struct {
+	shim.ChaincodeStubInterface
+	mock.Mock
+}
+
+func (ms *MockStub) GetState(key string) ([]byte, error) {
+	args := ms.Called(key)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (ms *MockStub) PutState(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) DelState(key string) error {
+	args := ms.Called(key)
+	return args.Error(0)
+}
+
+func (ms *MockStub) SetEvent(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	args := ms.Called(objectType, keys)
+	return &MockIterator{records: args.Get(0).([]*queryresult.KV)}, args.Error(1)
+}
+
+func (ms *MockStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	called := ms.Called(chaincodeName, args, channel)
+	return called.Get(0).(peer.Response)
+}
+
+// CreateCompositeKey and SplitCompositeKey use the key format of the peer, so that the tests can build the keys the contract reads
+func (ms *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (ms *MockStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	components := strings.Split(strings.TrimSuffix(strings.TrimPrefix(compositeKey, "\x00"), "\x00"), "\x00")
+	return components[0], components[1:], nil
+}
+
+type MockClientIdentity struct {
+	cid.ClientIdentity
+	mock.Mock
+}
+
+func (mci *MockClientIdentity) GetID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetMSPID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+type MockContext struct {
+	contractapi.TransactionContextInterface
+	mock.Mock
+}
+
+func (mc *MockContext) GetStub() shim.ChaincodeStubInterface {
+	args := mc.Called()
+	return args.Get(0).(*MockStub)
+}
+
+func (mc *MockContext) GetClientIdentity() cid.ClientIdentity {
+	args := mc.Called()
+	return args.Get(0).(*MockClientIdentity)
+}
+
+type MockIterator struct {
+	shim.StateQueryIteratorInterface
+	records []*queryresult.KV
+}
+
+func (it *MockIterator) HasNext() bool {
+	return len(it.records) > 0
+}
+
+func (it *MockIterator) Next() (*queryresult.KV, error) {
+	record := it.records[0]
+	it.records = it.records[1:]
+	return record, nil
+}
+
+func (it *MockIterator) Close() error {
+	return nil
+}
+
+// setupStub returns a context for the client with the given ID and MSP ID of an initialized contract
+// The state read by a test is mocked by the test with mockState
+func setupStub(clientID string, clientMSPID string) (*MockContext, *MockStub) {
+	anyString := mock.AnythingOfType("string")
+	anyUint8Slice := mock.AnythingOfType("[]uint8")
+
+	ms := new(MockStub)
+
+	ms.On("GetState", nameKey).Return([]byte("Token"), nil)
+
+	ms.On("PutState", anyString, anyUint8Slice).Return(nil)
+	ms.On("DelState", anyString).Return(nil)
+	ms.On("SetEvent", anyString, anyUint8Slice).Return(nil)
+
+	mci := new(MockClientIdentity)
+	mci.On("GetID").Return(clientID, nil)
+	mci.On("GetMSPID").Return(clientMSPID, nil)
+
+	mc := new(MockContext)
+	mc.On("GetStub").Return(ms)
+	mc.On("GetClientIdentity").Return(mci)
+	return mc, ms
+}
+
+// mockState mocks the value of a key, an empty value mocks a key that is not in the world state
+func mockState(ms *MockStub, key string, value string) {
+	if value == "" {
+		ms.On("GetState", key).Return([]byte(nil), nil)
+		return
+	}
+	ms.On("GetState", key).Return([]byte(value), nil)
+}
+
+// mockHolderBalance mocks the 10 tokens of token id 1 that admin minted to holder, and empty balances of holder and receiver
+func mockHolderBalance(ms *MockStub) {
+	ms.On("GetStateByPartialCompositeKey", balancePrefix, []string{holder64, "1"}).Return([]*queryresult.KV{
+		{Key: compositeKey(balancePrefix, holder64, "1", admin64), Value: []byte("10")},
+	}, nil)
+	mockState(ms, compositeKey(balancePrefix, holder64, "1", holder64), "")
+	mockState(ms, compositeKey(balancePrefix, receiver, "1", holder64), "")
+}
+
+func compositeKey(objectType string, attributes ...string) string {
+	key, _ := shim.CreateCompositeKey(objectType, attributes)
+	return key
+}
+
+func TestSafeTransferFrom(t *testing.T) {
+	c := new(SmartContract)
+	hookArgs := [][]byte{[]byte("OnERC1155Received"), []byte(holder64), []byte(holder64), []byte("1"), []byte("4"), []byte("data")}
+
+	// A recipient that is not a receiver contract receives the tokens like with TransferFrom
+	transactionContext, chaincodeStub := setupStub(holder64, "Org2MSP")
+	mockHolderBalance(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(receiverContractPrefix, receiver), "")
+
+	err := c.SafeTransferFrom(transactionContext, holder64, receiver, 1, 4, "data")
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "DelState", compositeKey(balancePrefix, holder64, "1", admin64))
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(balancePrefix, holder64, "1", holder64), []byte("6"))
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(balancePrefix, receiver, "1", holder64), []byte("4"))
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", mock.Anything, mock.Anything, mock.Anything)
+
+	// A receiver contract accepts the tokens with the magic value
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockHolderBalance(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(receiverContractPrefix, receiver), receiverChaincode)
+	chaincodeStub.On("InvokeChaincode", receiverChaincode, hookArgs, "").Return(shim.Success([]byte(erc1155ReceivedMagicValue)))
+
+	err = c.SafeTransferFrom(transactionContext, holder64, receiver, 1, 4, "data")
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "InvokeChaincode", receiverChaincode, hookArgs, "")
+
+	transferSingleJSON, _ := json.Marshal(TransferSingle{holder64, holder64, receiver, 1, 4})
+	chaincodeStub.AssertCalled(t, "SetEvent", "TransferSingle", transferSingleJSON)
+
+	// A receiver contract that fails rejects the transfer, the error fails the whole transaction
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockHolderBalance(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(receiverContractPrefix, receiver), receiverChaincode)
+	chaincodeStub.On("InvokeChaincode", receiverChaincode, hookArgs, "").Return(shim.Error("token 1 is not accepted"))
+
+	err = c.SafeTransferFrom(transactionContext, holder64, receiver, 1, 4, "data")
+	assert.EqualError(t, err, "receiver contract receiver rejected the transfer: token 1 is not accepted")
+
+	// A receiver contract that does not return the magic value rejects the transfer
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockHolderBalance(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(receiverContractPrefix, receiver), receiverChaincode)
+	chaincodeStub.On("InvokeChaincode", receiverChaincode, hookArgs, "").Return(shim.Success([]byte("0xbc197c81")))
+
+	err = c.SafeTransferFrom(transactionContext, holder64, receiver, 1, 4, "data")
+	assert.EqualError(t, err, "receiver contract receiver returned \"0xbc197c81\" from OnERC1155Received instead of 0xf23a6e61")
+
+	// An operator that is not approved by the holder can not transfer, the receiver contract is not invoked
+	transactionContext, chaincodeStub = setupStub(operator64, "Org2MSP")
+	mockHolderBalance(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(approvalPrefix, holder64, operator64), "")
+	mockState(chaincodeStub, compositeKey(receiverContractPrefix, receiver), receiverChaincode)
+
+	err = c.SafeTransferFrom(transactionContext, holder64, receiver, 1, 4, "data")
+	assert.EqualError(t, err, "caller is not owner nor is approved")
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", mock.Anything, mock.Anything, mock.Anything)
+
+	// The balance of the holder is checked before the receiver contract is invoked
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockHolderBalance(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(receiverContractPrefix, receiver), receiverChaincode)
+
+	err = c.SafeTransferFrom(transactionContext, holder64, receiver, 1, 11, "data")
+	assert.EqualError(t, err, "sender has insufficient funds for token 1, needed funds: 11, available fund: 10")
+	chaincodeStub.AssertNotCalled(t, "InvokeChaincode", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// mockAdmins mocks the contract admins, accounts that are not listed are not admins when they are mocked with mockState
+func mockAdmins(ms *MockStub, admins ...string) {
+	mockState(ms, adminCountKey, strconv.Itoa(len(admins)))
+	for _, account := range admins {
+		mockState(ms, compositeKey(adminPrefix, account), "true")
+	}
+}
+
+// mockTokenType mocks token type 1 created for holder with a maximum supply of 100 tokens
+func mockTokenType(ms *MockStub) {
+	tokenTypeJSON, _ := json.Marshal(TokenType{1, holder64, 100, ""})
+	mockState(ms, compositeKey(tokenTypePrefix, "1"), string(tokenTypeJSON))
+}
+
+func TestGrantAdmin(t *testing.T) {
+	c := new(SmartContract)
+
+	transactionContext, chaincodeStub := setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockState(chaincodeStub, compositeKey(adminPrefix, holder64), "")
+
+	err := c.GrantAdmin(transactionContext, holder64)
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(adminPrefix, holder64), []byte("true"))
+	chaincodeStub.AssertCalled(t, "PutState", adminCountKey, []byte("2"))
+
+	roleGrantedJSON, _ := json.Marshal(RoleGranted{adminRole, holder64, admin64})
+	chaincodeStub.AssertCalled(t, "SetEvent", "RoleGranted", roleGrantedJSON)
+
+	err = c.GrantAdmin(transactionContext, admin64)
+	assert.EqualError(t, err, "account "+admin64+" is already an admin")
+
+	err = c.GrantAdmin(transactionContext, "0x0")
+	assert.EqualError(t, err, "admin account must not be empty or the zero address")
+
+	// Once admins are recorded on the ledger, the clients of the minter organization are not admins by their MSP ID
+	transactionContext, chaincodeStub = setupStub(operator64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockState(chaincodeStub, compositeKey(adminPrefix, operator64), "")
+
+	err = c.GrantAdmin(transactionContext, operator64)
+	assert.EqualError(t, err, "client is not an admin of the contract")
+
+	// A contract initialized before admins were recorded falls back to the minter organization
+	transactionContext, chaincodeStub = setupStub(operator64, "Org1MSP")
+	mockState(chaincodeStub, adminCountKey, "")
+	mockState(chaincodeStub, compositeKey(adminPrefix, operator64), "")
+
+	err = c.GrantAdmin(transactionContext, operator64)
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "PutState", adminCountKey, []byte("1"))
+
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockState(chaincodeStub, adminCountKey, "")
+
+	err = c.GrantAdmin(transactionContext, holder64)
+	assert.EqualError(t, err, "client is not authorized to mint new tokens")
+}
+
+func TestRevokeAdmin(t *testing.T) {
+	c := new(SmartContract)
+
+	transactionContext, chaincodeStub := setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockState(chaincodeStub, compositeKey(adminPrefix, holder64), "")
+
+	err := c.RevokeAdmin(transactionContext, admin64)
+	assert.EqualError(t, err, "the last admin can not be revoked")
+
+	err = c.RevokeAdmin(transactionContext, holder64)
+	assert.EqualError(t, err, "account "+holder64+" is not an admin")
+
+	transactionContext, chaincodeStub = setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64, holder64)
+
+	err = c.RevokeAdmin(transactionContext, holder64)
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "DelState", compositeKey(adminPrefix, holder64))
+	chaincodeStub.AssertCalled(t, "PutState", adminCountKey, []byte("1"))
+
+	roleRevokedJSON, _ := json.Marshal(RoleRevoked{adminRole, holder64, admin64})
+	chaincodeStub.AssertCalled(t, "SetEvent", "RoleRevoked", roleRevokedJSON)
+}
+
+func TestCreateTokenType(t *testing.T) {
+	c := new(SmartContract)
+
+	transactionContext, chaincodeStub := setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockState(chaincodeStub, compositeKey(tokenTypePrefix, "1"), "")
+	mockState(chaincodeStub, compositeKey(totalSupplyPrefix, "1"), "")
+
+	err := c.CreateTokenType(transactionContext, 1, holder64, 100, "https://example.com/1.json")
+	assert.NoError(t, err)
+
+	tokenTypeJSON, _ := json.Marshal(TokenType{1, holder64, 100, "https://example.com/1.json"})
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(tokenTypePrefix, "1"), tokenTypeJSON)
+
+	uriJSON, _ := json.Marshal(URI{"https://example.com/1.json", 1})
+	chaincodeStub.AssertCalled(t, "SetEvent", "URI", uriJSON)
+
+	err = c.CreateTokenType(transactionContext, 1, "0x0", 100, "")
+	assert.EqualError(t, err, "creator must not be empty or the zero address")
+
+	// Tokens an admin minted before the token type was created count against its maximum supply
+	transactionContext, chaincodeStub = setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockState(chaincodeStub, compositeKey(tokenTypePrefix, "1"), "")
+	mockState(chaincodeStub, compositeKey(totalSupplyPrefix, "1"), "150")
+
+	err = c.CreateTokenType(transactionContext, 1, holder64, 100, "")
+	assert.EqualError(t, err, "the total supply 150 of token 1 exceeds the maximum supply 100")
+
+	// A token type can not be changed once it is created
+	transactionContext, chaincodeStub = setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockTokenType(chaincodeStub)
+
+	err = c.CreateTokenType(transactionContext, 1, operator64, 0, "")
+	assert.EqualError(t, err, "token type 1 already exists")
+
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockState(chaincodeStub, compositeKey(adminPrefix, holder64), "")
+
+	err = c.CreateTokenType(transactionContext, 2, holder64, 0, "")
+	assert.EqualError(t, err, "client is not an admin of the contract")
+	chaincodeStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestAuthorizeMinter(t *testing.T) {
+	c := new(SmartContract)
+
+	transactionContext, chaincodeStub := setupStub(holder64, "Org2MSP")
+	mockTokenType(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(tokenTypePrefix, "2"), "")
+
+	err := c.AuthorizeMinter(transactionContext, 1, operator64)
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(tokenMinterPrefix, "1", operator64), []byte("true"))
+
+	minterAuthorizedJSON, _ := json.Marshal(MinterAuthorized{1, operator64, holder64})
+	chaincodeStub.AssertCalled(t, "SetEvent", "MinterAuthorized", minterAuthorizedJSON)
+
+	err = c.AuthorizeMinter(transactionContext, 2, operator64)
+	assert.EqualError(t, err, "token type 2 does not exist")
+
+	// Only the creator authorizes minters, not the admins nor other minters
+	for _, clientID := range []string{admin64, operator64} {
+		transactionContext, chaincodeStub = setupStub(clientID, "Org1MSP")
+		mockAdmins(chaincodeStub, admin64)
+		mockTokenType(chaincodeStub)
+
+		err = c.AuthorizeMinter(transactionContext, 1, clientID)
+		assert.EqualError(t, err, "client is not the creator of token type 1")
+		chaincodeStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+	}
+}
+
+func TestMintTokenType(t *testing.T) {
+	c := new(SmartContract)
+
+	// An authorized minter mints up to the maximum supply of the token type
+	transactionContext, chaincodeStub := setupStub(operator64, "Org2MSP")
+	mockTokenType(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(tokenMinterPrefix, "1", operator64), "true")
+	mockState(chaincodeStub, compositeKey(totalSupplyPrefix, "1"), "90")
+	mockState(chaincodeStub, compositeKey(balancePrefix, holder64, "1", operator64), "")
+
+	err := c.Mint(transactionContext, holder64, 1, 10)
+	assert.NoError(t, err)
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(balancePrefix, holder64, "1", operator64), []byte("10"))
+	chaincodeStub.AssertCalled(t, "PutState", compositeKey(totalSupplyPrefix, "1"), []byte("100"))
+
+	err = c.Mint(transactionContext, holder64, 1, 11)
+	assert.EqualError(t, err, "minting 11 tokens of token type 1 exceeds its maximum supply 100")
+
+	// An admin that is neither the creator nor an authorized minter can not mint tokens of the token type
+	transactionContext, chaincodeStub = setupStub(admin64, "Org1MSP")
+	mockAdmins(chaincodeStub, admin64)
+	mockTokenType(chaincodeStub)
+	mockState(chaincodeStub, compositeKey(tokenMinterPrefix, "1", admin64), "")
+
+	err = c.Mint(transactionContext, holder64, 1, 10)
+	assert.EqualError(t, err, "client is not authorized to mint tokens of token type 1")
+	chaincodeStub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,212 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const receiverContractPrefix = "receiverContract~account"
+
+// Values that receiver contracts must return to accept tokens, these are the ERC-1155 selectors of
+// onERC1155Received(address,address,uint256,uint256,bytes) and onERC1155BatchReceived(address,address,uint256[],uint256[],bytes)
+const erc1155ReceivedMagicValue = "0xf23a6e61"
+const erc1155BatchReceivedMagicValue = "0xbc197c81"
+
+// SafeTransferFrom transfers tokens from sender account to recipient account like TransferFrom
+// When the recipient is registered as a receiver contract, OnERC1155Received(operator, sender, id, amount, data)
+// of that chaincode is invoked and the transfer is reverted unless it returns the ERC-1155 magic value
+// This function triggers a TransferSingle event
+func (s *SmartContract) SafeTransferFrom(ctx contractapi.TransactionContextInterface, sender string, recipient string, id uint64, amount uint64, data string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = s.TransferFrom(ctx, sender, recipient, id, amount)
+	if err != nil {
+		return err
+	}
+
+	// Get ID of submitting client identity
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	args := []string{operator, sender, strconv.FormatUint(id, 10), strconv.FormatUint(amount, 10), data}
+	return checkOnERC1155Received(ctx, recipient, "OnERC1155Received", args, erc1155ReceivedMagicValue)
+}
+
+// SafeBatchTransferFrom transfers multiple tokens from sender account to recipient account like BatchTransferFrom
+// When the recipient is registered as a receiver contract, OnERC1155BatchReceived(operator, sender, ids, amounts, data)
+// of that chaincode is invoked and the transfer is reverted unless it returns the ERC-1155 batch magic value
+// This function triggers a TransferBatch event
+func (s *SmartContract) SafeBatchTransferFrom(ctx contractapi.TransactionContextInterface, sender string, recipient string, ids []uint64, amounts []uint64, data string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = s.BatchTransferFrom(ctx, sender, recipient, ids, amounts)
+	if err != nil {
+		return err
+	}
+
+	// Get ID of submitting client identity
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	amountsJSON, err := json.Marshal(amounts)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	args := []string{operator, sender, string(idsJSON), string(amountsJSON), data}
+	return checkOnERC1155Received(ctx, recipient, "OnERC1155BatchReceived", args, erc1155BatchReceivedMagicValue)
+}
+
+// RegisterReceiverContract registers account as owned by a chaincode on the same channel
+// SafeTransferFrom and SafeBatchTransferFrom to the account invoke the receiver hooks of that chaincode
+func (s *SmartContract) RegisterReceiverContract(ctx contractapi.TransactionContextInterface, account string, chaincodeName string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization - this sample assumes the minter organization registers receiver contracts
+	err = authorizationHelper(ctx)
+	if err != nil {
+		return err
+	}
+
+	if account == "" || chaincodeName == "" {
+		return fmt.Errorf("account and chaincode name must not be empty")
+	}
+
+	receiverKey, err := ctx.GetStub().CreateCompositeKey(receiverContractPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", receiverContractPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(receiverKey, []byte(chaincodeName))
+	if err != nil {
+		return fmt.Errorf("failed to register receiver contract of account %s: %v", account, err)
+	}
+
+	return nil
+}
+
+// UnregisterReceiverContract removes the receiver contract registration of account
+func (s *SmartContract) UnregisterReceiverContract(ctx contractapi.TransactionContextInterface, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization - this sample assumes the minter organization registers receiver contracts
+	err = authorizationHelper(ctx)
+	if err != nil {
+		return err
+	}
+
+	receiverKey, err := ctx.GetStub().CreateCompositeKey(receiverContractPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", receiverContractPrefix, err)
+	}
+
+	err = ctx.GetStub().DelState(receiverKey)
+	if err != nil {
+		return fmt.Errorf("failed to unregister receiver contract of account %s: %v", account, err)
+	}
+
+	return nil
+}
+
+// GetReceiverContract returns the name of the chaincode registered for account, or an empty string if there is none
+func (s *SmartContract) GetReceiverContract(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return receiverContractHelper(ctx, account)
+}
+
+// Helper Functions
+
+// receiverContractHelper returns the name of the chaincode registered for account, or an empty string if there is none
+func receiverContractHelper(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+	receiverKey, err := ctx.GetStub().CreateCompositeKey(receiverContractPrefix, []string{account})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", receiverContractPrefix, err)
+	}
+
+	chaincodeNameBytes, err := ctx.GetStub().GetState(receiverKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read receiver contract of account %s from world state: %v", account, err)
+	}
+
+	return string(chaincodeNameBytes), nil
+}
+
+// checkOnERC1155Received invokes the receiver hook when recipient is a receiver contract
+// The invoked chaincode runs in this transaction, so an error here reverts the transfer
+func checkOnERC1155Received(ctx contractapi.TransactionContextInterface, recipient string, function string, args []string, magicValue string) error {
+	chaincodeName, err := receiverContractHelper(ctx, recipient)
+	if err != nil {
+		return err
+	}
+	if chaincodeName == "" {
+		return nil
+	}
+
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := ctx.GetStub().InvokeChaincode(chaincodeName, invokeArgs, "")
+	if response.Status != shim.OK {
+		return fmt.Errorf("receiver contract %s rejected the transfer: %s", chaincodeName, response.Message)
+	}
+	if string(response.Payload) != magicValue {
+		return fmt.Errorf("receiver contract %s returned %q from %s instead of %s", chaincodeName, string(response.Payload), function, magicValue)
+	}
+
+	return nil
+}
@@ -0,0 +1,364 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const tokenTypePrefix = "tokenType~tokenId"
+const tokenMinterPrefix = "tokenMinter~tokenId~account"
+
+// TokenType is a token id registered with a creator. Only the creator and the minters it authorizes
+// can mint tokens of the type, up to MaxSupply. A MaxSupply of 0 means the supply is not capped
+// URI is the metadata URI of the type, when it is empty the URI of the contract applies
+type TokenType struct {
+	ID        uint64 `json:"id"`
+	Creator   string `json:"creator"`
+	MaxSupply uint64 `json:"maxSupply"`
+	URI       string `json:"uri"`
+}
+
+// MinterAuthorized MUST emit when account is authorized to mint tokens of token type ID, Sender is the creator of the token type
+type MinterAuthorized struct {
+	ID      uint64 `json:"id"`
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// MinterRevoked MUST emit when the authorization of account to mint tokens of token type ID is removed
+type MinterRevoked struct {
+	ID      uint64 `json:"id"`
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// CreateTokenType registers token type id with its creator, maximum supply and URI.
+// Only an admin can create token types, and a token type can not be changed once it is created.
+// This function emits a URI event when uri is not empty
+func (s *SmartContract) CreateTokenType(ctx contractapi.TransactionContextInterface, id uint64, creator string, maxSupply uint64, uri string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = authorizationHelper(ctx)
+	if err != nil {
+		return err
+	}
+
+	if creator == "" || creator == "0x0" {
+		return fmt.Errorf("creator must not be empty or the zero address")
+	}
+
+	tokenType, err := tokenTypeHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tokenType != nil {
+		return fmt.Errorf("token type %v already exists", id)
+	}
+
+	// Tokens of the id may have been minted by an admin before the type was created
+	totalSupply, err := totalSupplyHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+	if maxSupply > 0 && totalSupply > maxSupply {
+		return fmt.Errorf("the total supply %v of token %v exceeds the maximum supply %v", totalSupply, id, maxSupply)
+	}
+
+	tokenType = &TokenType{id, creator, maxSupply, uri}
+
+	tokenTypeKey, err := ctx.GetStub().CreateCompositeKey(tokenTypePrefix, []string{strconv.FormatUint(id, 10)})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenTypePrefix, err)
+	}
+
+	tokenTypeJSON, err := json.Marshal(tokenType)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(tokenTypeKey, tokenTypeJSON)
+	if err != nil {
+		return fmt.Errorf("failed to create token type %v: %v", id, err)
+	}
+
+	if uri == "" {
+		return nil
+	}
+
+	// Emit URI event
+	uriEvent := URI{uri, id}
+	uriEventJSON, err := json.Marshal(uriEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("URI", uriEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// GetTokenType returns the token type id, or an error if it was not created
+func (s *SmartContract) GetTokenType(ctx contractapi.TransactionContextInterface, id uint64) (*TokenType, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	tokenType, err := tokenTypeHelper(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tokenType == nil {
+		return nil, fmt.Errorf("token type %v does not exist", id)
+	}
+
+	return tokenType, nil
+}
+
+// AuthorizeMinter lets account mint tokens of token type id. Only the creator of the token type can authorize minters.
+// This function emits a MinterAuthorized event
+func (s *SmartContract) AuthorizeMinter(ctx contractapi.TransactionContextInterface, id uint64, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = creatorAuthorizationHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if account == "" || account == "0x0" {
+		return fmt.Errorf("minter account must not be empty or the zero address")
+	}
+
+	tokenMinterKey, err := ctx.GetStub().CreateCompositeKey(tokenMinterPrefix, []string{strconv.FormatUint(id, 10), account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenMinterPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(tokenMinterKey, []byte("true"))
+	if err != nil {
+		return fmt.Errorf("failed to authorize minter %s for token %v: %v", account, id, err)
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// Emit MinterAuthorized event
+	minterAuthorizedEvent := MinterAuthorized{id, account, sender}
+	minterAuthorizedEventJSON, err := json.Marshal(minterAuthorizedEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("MinterAuthorized", minterAuthorizedEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// RevokeMinter removes the authorization of account to mint tokens of token type id. Only the creator of the token type can revoke minters.
+// This function emits a MinterRevoked event
+func (s *SmartContract) RevokeMinter(ctx contractapi.TransactionContextInterface, id uint64, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = creatorAuthorizationHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tokenMinterKey, err := ctx.GetStub().CreateCompositeKey(tokenMinterPrefix, []string{strconv.FormatUint(id, 10), account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenMinterPrefix, err)
+	}
+
+	err = ctx.GetStub().DelState(tokenMinterKey)
+	if err != nil {
+		return fmt.Errorf("failed to revoke minter %s for token %v: %v", account, id, err)
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// Emit MinterRevoked event
+	minterRevokedEvent := MinterRevoked{id, account, sender}
+	minterRevokedEventJSON, err := json.Marshal(minterRevokedEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("MinterRevoked", minterRevokedEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// IsMinter returns true if account can mint tokens of token type id, either as its creator or as an authorized minter
+func (s *SmartContract) IsMinter(ctx contractapi.TransactionContextInterface, id uint64, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	tokenType, err := tokenTypeHelper(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if tokenType == nil {
+		return false, fmt.Errorf("token type %v does not exist", id)
+	}
+
+	return isMinterHelper(ctx, tokenType, account)
+}
+
+// Helper Functions
+
+// tokenTypeHelper returns token type id, nil if it was not created
+func tokenTypeHelper(ctx contractapi.TransactionContextInterface, id uint64) (*TokenType, error) {
+	tokenTypeKey, err := ctx.GetStub().CreateCompositeKey(tokenTypePrefix, []string{strconv.FormatUint(id, 10)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenTypePrefix, err)
+	}
+
+	tokenTypeBytes, err := ctx.GetStub().GetState(tokenTypeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token type %v from world state: %v", id, err)
+	}
+	if tokenTypeBytes == nil {
+		return nil, nil
+	}
+
+	tokenType := new(TokenType)
+	err = json.Unmarshal(tokenTypeBytes, tokenType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token type %v: %v", id, err)
+	}
+
+	return tokenType, nil
+}
+
+// isMinterHelper returns true if account is the creator of the token type or a minter authorized by the creator
+func isMinterHelper(ctx contractapi.TransactionContextInterface, tokenType *TokenType, account string) (bool, error) {
+	if tokenType.Creator == account {
+		return true, nil
+	}
+
+	tokenMinterKey, err := ctx.GetStub().CreateCompositeKey(tokenMinterPrefix, []string{strconv.FormatUint(tokenType.ID, 10), account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenMinterPrefix, err)
+	}
+
+	tokenMinterBytes, err := ctx.GetStub().GetState(tokenMinterKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read minter %s of token %v from world state: %v", account, tokenType.ID, err)
+	}
+
+	return tokenMinterBytes != nil, nil
+}
+
+// creatorAuthorizationHelper checks that the client is the creator of token type id
+func creatorAuthorizationHelper(ctx contractapi.TransactionContextInterface, id uint64) error {
+	tokenType, err := tokenTypeHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tokenType == nil {
+		return fmt.Errorf("token type %v does not exist", id)
+	}
+
+	client, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	if tokenType.Creator != client {
+		return fmt.Errorf("client is not the creator of token type %v", id)
+	}
+
+	return nil
+}
+
+// mintAuthorizationHelper checks that operator can mint amount tokens of token type id.
+// Tokens of a created token type are minted by its creator and authorized minters up to its maximum supply,
+// tokens of an id without a token type are minted by the contract admins
+func mintAuthorizationHelper(ctx contractapi.TransactionContextInterface, operator string, id uint64, amount uint64) error {
+	tokenType, err := tokenTypeHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tokenType == nil {
+		return authorizationHelper(ctx)
+	}
+
+	isMinter, err := isMinterHelper(ctx, tokenType, operator)
+	if err != nil {
+		return err
+	}
+	if !isMinter {
+		return fmt.Errorf("client is not authorized to mint tokens of token type %v", id)
+	}
+
+	if tokenType.MaxSupply == 0 {
+		return nil
+	}
+
+	totalSupply, err := totalSupplyHelper(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	totalSupply, err = add(totalSupply, amount)
+	if err != nil {
+		return err
+	}
+	if totalSupply > tokenType.MaxSupply {
+		return fmt.Errorf("minting %v tokens of token type %v exceeds its maximum supply %v", amount, id, tokenType.MaxSupply)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const allowanceBySpenderPrefix = "allowanceBySpender"
+
+// AllowanceRecord is an allowance of the spender over the owner's tokens as returned by the enumeration queries
+type AllowanceRecord struct {
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	Value   string `json:"value"`
+}
+
+// PaginatedAllowances holds a page of allowances and the bookmark to fetch the next page with
+type PaginatedAllowances struct {
+	Records             []*AllowanceRecord `json:"records"`
+	FetchedRecordsCount int32              `json:"fetchedRecordsCount"`
+	Bookmark            string             `json:"bookmark"`
+}
+
+// IncreaseAllowance raises the allowance of the spender over the calling client's tokens by addedValue
+// Unlike Approve, it does not overwrite an allowance the spender may be about to use
+// This function triggers an Approval event
+func (s *SmartContract) IncreaseAllowance(ctx contractapi.TransactionContextInterface, spender string, addedValue string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	owner, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	delta, err := parseAmount(addedValue)
+	if err != nil {
+		return err
+	}
+	if delta.Sign() <= 0 {
+		return fmt.Errorf("added value must be a positive integer")
+	}
+
+	currentAllowance, err := readAllowance(ctx, owner, spender)
+	if err != nil {
+		return err
+	}
+
+	updatedAllowance, err := add(currentAllowance, delta)
+	if err != nil {
+		return err
+	}
+
+	err = putAllowance(ctx, owner, spender, updatedAllowance)
+	if err != nil {
+		return err
+	}
+
+	err = emitApproval(ctx, owner, spender, updatedAllowance)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s increased the withdrawal allowance of spender %s from %s to %s", owner, spender, currentAllowance, updatedAllowance)
+
+	return nil
+}
+
+// DecreaseAllowance lowers the allowance of the spender over the calling client's tokens by subtractedValue
+// The allowance cannot go below zero, an allowance decreased to zero is removed
+// This function triggers an Approval event
+func (s *SmartContract) DecreaseAllowance(ctx contractapi.TransactionContextInterface, spender string, subtractedValue string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	owner, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	delta, err := parseAmount(subtractedValue)
+	if err != nil {
+		return err
+	}
+	if delta.Sign() <= 0 {
+		return fmt.Errorf("subtracted value must be a positive integer")
+	}
+
+	currentAllowance, err := readAllowance(ctx, owner, spender)
+	if err != nil {
+		return err
+	}
+	if currentAllowance.Cmp(delta) < 0 {
+		return fmt.Errorf("allowance of spender %s cannot be decreased below zero", spender)
+	}
+
+	updatedAllowance, err := sub(currentAllowance, delta)
+	if err != nil {
+		return err
+	}
+
+	err = putAllowance(ctx, owner, spender, updatedAllowance)
+	if err != nil {
+		return err
+	}
+
+	err = emitApproval(ctx, owner, spender, updatedAllowance)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s decreased the withdrawal allowance of spender %s from %s to %s", owner, spender, currentAllowance, updatedAllowance)
+
+	return nil
+}
+
+// AllowancesByOwner returns a page of the allowances the owner has granted, ordered by spender
+// Pass an empty bookmark for the first page and the returned bookmark for the next one
+// Paginated queries are only valid for read only transactions.
+func (s *SmartContract) AllowancesByOwner(ctx contractapi.TransactionContextInterface, owner string, pageSize int, bookmark string) (*PaginatedAllowances, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(allowancePrefix, []string{owner}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allowances of owner %s: %v", owner, err)
+	}
+	defer resultsIterator.Close()
+
+	records := []*AllowanceRecord{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be owner.spender
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 2 {
+			return nil, fmt.Errorf("expected composite key with two parts (owner:spender)")
+		}
+
+		allowance, err := amountFromBytes(queryResponse.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read allowance for %s: %v", queryResponse.Key, err)
+		}
+
+		records = append(records, &AllowanceRecord{Owner: compositeKeyParts[0], Spender: compositeKeyParts[1], Value: allowance.String()})
+	}
+
+	return &PaginatedAllowances{
+		Records:             records,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// AllowancesBySpender returns a page of the allowances granted to the spender, ordered by owner
+// Allowances are indexed by spender when they are written, so allowances left unchanged since before the index existed are not listed
+// Pass an empty bookmark for the first page and the returned bookmark for the next one
+// Paginated queries are only valid for read only transactions.
+func (s *SmartContract) AllowancesBySpender(ctx contractapi.TransactionContextInterface, spender string, pageSize int, bookmark string) (*PaginatedAllowances, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(allowanceBySpenderPrefix, []string{spender}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allowances of spender %s: %v", spender, err)
+	}
+	defer resultsIterator.Close()
+
+	records := []*AllowanceRecord{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be spender.owner
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 2 {
+			return nil, fmt.Errorf("expected composite key with two parts (spender:owner)")
+		}
+
+		allowance, err := readAllowance(ctx, compositeKeyParts[1], spender)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, &AllowanceRecord{Owner: compositeKeyParts[1], Spender: spender, Value: allowance.String()})
+	}
+
+	return &PaginatedAllowances{
+		Records:             records,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// Helper Functions
+
+// readAllowance returns the allowance of the spender over the owner's tokens, 0 if there is none
+func readAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (*big.Int, error) {
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{owner, spender})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+	}
+
+	allowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowance for %s from world state: %v", allowanceKey, err)
+	}
+
+	// If no current allowance, set allowance to 0
+	allowance, err := amountFromBytes(allowanceBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowance for %s: %v", allowanceKey, err)
+	}
+
+	return allowance, nil
+}
+
+// putAllowance writes the allowance of the spender over the owner's tokens together with its spender index entry
+// A zero allowance deletes both entries instead of storing zero
+func putAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string, allowance *big.Int) error {
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{owner, spender})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+	}
+
+	spenderIndexKey, err := ctx.GetStub().CreateCompositeKey(allowanceBySpenderPrefix, []string{spender, owner})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowanceBySpenderPrefix, err)
+	}
+
+	if allowance.Sign() == 0 {
+		err = ctx.GetStub().DelState(allowanceKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete state of smart contract for key %s: %v", allowanceKey, err)
+		}
+
+		err = ctx.GetStub().DelState(spenderIndexKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete state of smart contract for key %s: %v", spenderIndexKey, err)
+		}
+
+		return nil
+	}
+
+	// Update the state of the smart contract by adding the allowanceKey and value
+	err = ctx.GetStub().PutState(allowanceKey, []byte(allowance.String()))
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", allowanceKey, err)
+	}
+
+	// The index only lists the owners, the value is read from the allowanceKey
+	err = ctx.GetStub().PutState(spenderIndexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", spenderIndexKey, err)
+	}
+
+	return nil
+}
+
+// emitApproval emits an Approval event with the new allowance of the spender
+func emitApproval(ctx contractapi.TransactionContextInterface, owner string, spender string, allowance *big.Int) error {
+	approvalEvent := event{owner, spender, allowance.String()}
+	approvalEventJSON, err := json.Marshal(approvalEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("Approval", approvalEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SupplyAudit is the result of AuditSupply
+// TotalSupply is the recorded total supply, Balances the sum of all account balances including pending hot account credits,
+// and Vesting the tokens minted into vesting schedules that have not been released yet
+// Consistent is true when Balances + Vesting equals TotalSupply and no anomalies were found
+type SupplyAudit struct {
+	TotalSupply string   `json:"totalSupply"`
+	Balances    string   `json:"balances"`
+	Vesting     string   `json:"vesting"`
+	Consistent  bool     `json:"consistent"`
+	Anomalies   []string `json:"anomalies"`
+}
+
+// AuditSupply checks the supply invariant by iterating every balance record in the world state
+// The sum of the account balances, the pending hot account credits and the unreleased vesting amounts must equal the total supply
+// This is an evaluate transaction, it reads the whole token state and should not be submitted for ordering
+func (s *SmartContract) AuditSupply(ctx contractapi.TransactionContextInterface) (*SupplyAudit, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	anomalies := []string{}
+
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+	totalSupply, err := amountFromBytes(totalSupplyBytes)
+	if err != nil {
+		anomalies = append(anomalies, fmt.Sprintf("total supply: %v", err))
+		totalSupply = big.NewInt(0)
+	}
+
+	// Balances are stored under the client ID as a simple key, next to the contract options
+	// A balance held under a key that is neither a client ID nor a multisig account is not counted, and shows up as a mismatch with the total supply
+	balances := big.NewInt(0)
+	balanceIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balances: %v", err)
+	}
+	defer balanceIterator.Close()
+
+	for balanceIterator.HasNext() {
+		queryResponse, err := balanceIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// Contract options share the simple key namespace, only keys in an account format hold balances
+		if !isAccountKey(queryResponse.Key) {
+			continue
+		}
+
+		balance, err := amountFromBytes(queryResponse.Value)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("account %s: %v", queryResponse.Key, err))
+			continue
+		}
+		if balance.Sign() < 0 {
+			anomalies = append(anomalies, fmt.Sprintf("account %s has a negative balance %s", queryResponse.Key, balance))
+		}
+		balances.Add(balances, balance)
+	}
+
+	// Credits to hot accounts are pending in delta rows until consolidated
+	deltaIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balanceDeltaPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance deltas: %v", err)
+	}
+	defer deltaIterator.Close()
+
+	for deltaIterator.HasNext() {
+		queryResponse, err := deltaIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be account.from.value.txID
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 4 {
+			anomalies = append(anomalies, fmt.Sprintf("balance delta %q does not have four parts (account:from:value:txID)", queryResponse.Key))
+			continue
+		}
+
+		delta, err := amountFromBytes([]byte(compositeKeyParts[2]))
+		if err != nil || delta.Sign() <= 0 {
+			anomalies = append(anomalies, fmt.Sprintf("balance delta of account %s in transaction %s has an invalid value %q", compositeKeyParts[0], compositeKeyParts[3], compositeKeyParts[2]))
+			continue
+		}
+		balances.Add(balances, delta)
+	}
+
+	// Tokens minted into a vesting schedule are part of the supply but not of any balance until released
+	vesting := big.NewInt(0)
+	scheduleIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(vestingSchedulePrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vesting schedules: %v", err)
+	}
+	defer scheduleIterator.Close()
+
+	for scheduleIterator.HasNext() {
+		queryResponse, err := scheduleIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		schedule := new(VestingSchedule)
+		err = json.Unmarshal(queryResponse.Value, schedule)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("vesting schedule %q could not be unmarshalled: %v", queryResponse.Key, err))
+			continue
+		}
+
+		amount, err := parseAmount(schedule.Amount)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("vesting schedule %s has an invalid amount: %v", schedule.ID, err))
+			continue
+		}
+		released, err := parseAmount(schedule.Released)
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("vesting schedule %s has an invalid released amount: %v", schedule.ID, err))
+			continue
+		}
+		if released.Cmp(amount) > 0 {
+			anomalies = append(anomalies, fmt.Sprintf("vesting schedule %s released %s of %s tokens", schedule.ID, released, amount))
+			continue
+		}
+		vesting.Add(vesting, new(big.Int).Sub(amount, released))
+	}
+
+	accounted := new(big.Int).Add(balances, vesting)
+	if accounted.Cmp(totalSupply) != 0 {
+		anomalies = append(anomalies, fmt.Sprintf("balances %s and vesting %s add up to %s, total supply is %s", balances, vesting, accounted, totalSupply))
+	}
+
+	audit := &SupplyAudit{
+		TotalSupply: totalSupply.String(),
+		Balances:    balances.String(),
+		Vesting:     vesting.String(),
+		Consistent:  len(anomalies) == 0,
+		Anomalies:   anomalies,
+	}
+
+	log.Printf("supply audit: %+v", audit)
+
+	return audit, nil
+}
+
+// Helper Functions
+
+// isAccountKey returns whether the key is a multisig account or a client ID as returned by ClientAccountID(),
+// the base64 encoding of an x509:: identity
+func isAccountKey(key string) bool {
+	if strings.HasPrefix(key, multisigAccountPrefix) {
+		return true
+	}
+
+	id, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(string(id), "x509::")
+}
@@ -0,0 +1,246 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchSize bounds the number of recipients of a batch transfer, so that a batch fits in a single transaction
+const maxBatchSize = 100
+
+// batchTransferEvent provides an organized struct for emitting BatchTransfer events
+// Fabric keeps a single event per transaction, so a batch emits no Transfer event and the per-recipient transfers are carried in Transfers
+type batchTransferEvent struct {
+	From      string  `json:"from"`
+	Value     string  `json:"value"`
+	Transfers []event `json:"transfers"`
+}
+
+// BatchTransfer transfers tokens from the client account to several recipient accounts atomically
+// recipients and amounts are matched by position, amounts are base-10 integer strings
+// This function triggers a BatchTransfer event in place of the Transfer event
+func (s *SmartContract) BatchTransfer(ctx contractapi.TransactionContextInterface, recipients []string, amounts []string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	values, total, err := parseBatch(clientID, recipients, amounts)
+	if err != nil {
+		return err
+	}
+
+	err = batchTransferHelper(ctx, clientID, recipients, values, total)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	err = emitBatchTransfer(ctx, clientID, recipients, values, total)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s transferred %s to %d recipients", clientID, total, len(recipients))
+
+	return nil
+}
+
+// BatchTransferFrom transfers tokens from the "from" account to several recipient accounts atomically
+// The allowance of the calling client over the "from" account is decreased by the sum of the amounts
+// This function triggers a BatchTransfer event in place of the Transfer event
+func (s *SmartContract) BatchTransferFrom(ctx contractapi.TransactionContextInterface, from string, recipients []string, amounts []string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	spender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// A frozen spender cannot use its allowances, the pause and the accounts are checked by batchTransferHelper
+	err = checkNotFrozen(ctx, spender)
+	if err != nil {
+		return err
+	}
+
+	values, total, err := parseBatch(from, recipients, amounts)
+	if err != nil {
+		return err
+	}
+
+	// Check the allowance covers the whole batch
+	currentAllowance, err := readAllowance(ctx, from, spender)
+	if err != nil {
+		return err
+	}
+	if currentAllowance.Cmp(total) < 0 {
+		return fmt.Errorf("spender does not have enough allowance for transfer")
+	}
+
+	// Initiate the transfer
+	err = batchTransferHelper(ctx, from, recipients, values, total)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	// Decrease the allowance
+	updatedAllowance, err := sub(currentAllowance, total)
+	if err != nil {
+		return err
+	}
+
+	err = putAllowance(ctx, from, spender, updatedAllowance)
+	if err != nil {
+		return err
+	}
+
+	err = emitBatchTransfer(ctx, from, recipients, values, total)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("spender %s transferred %s from %s to %d recipients, allowance updated from %s to %s", spender, total, from, len(recipients), currentAllowance, updatedAllowance)
+
+	return nil
+}
+
+// Helper Functions
+
+// parseBatch validates the recipients of a batch transfer and parses its amounts, returning them and their sum
+// The batch is rejected if it is empty or too large, if a recipient is repeated or is the sender, or if an amount is not positive
+func parseBatch(from string, recipients []string, amounts []string) ([]*big.Int, *big.Int, error) {
+	if len(recipients) == 0 {
+		return nil, nil, fmt.Errorf("batch has no recipients")
+	}
+	if len(recipients) > maxBatchSize {
+		return nil, nil, fmt.Errorf("batch of %d recipients exceeds the maximum batch size of %d", len(recipients), maxBatchSize)
+	}
+	if len(recipients) != len(amounts) {
+		return nil, nil, fmt.Errorf("batch has %d recipients but %d amounts", len(recipients), len(amounts))
+	}
+
+	seen := make(map[string]bool)
+	values := make([]*big.Int, len(amounts))
+	total := big.NewInt(0)
+	for i, recipient := range recipients {
+		if recipient == from {
+			return nil, nil, fmt.Errorf("cannot transfer to and from same client account")
+		}
+		if seen[recipient] {
+			return nil, nil, fmt.Errorf("duplicate recipient %s in batch", recipient)
+		}
+		seen[recipient] = true
+
+		value, err := parseAmount(amounts[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if value.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("transfer amount to %s must be a positive integer", recipient)
+		}
+		values[i] = value
+
+		total, err = add(total, value)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return values, total, nil
+}
+
+// batchTransferHelper debits the "from" account once with the total and credits each recipient with its value
+// It applies the same pause and freeze checks as transferHelper
+// Dependant functions include BatchTransfer and BatchTransferFrom
+func batchTransferHelper(ctx contractapi.TransactionContextInterface, from string, recipients []string, values []*big.Int, total *big.Int) error {
+
+	err := checkNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		err = checkNotFrozen(ctx, recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = debitBalance(ctx, from, total)
+	if err != nil {
+		return err
+	}
+
+	// The sender is journaled once per recipient, in the order of the batch
+	fromJournal := make([]journalEntry, len(recipients))
+	for i, recipient := range recipients {
+		fromJournal[i] = journalEntry{recipient, new(big.Int).Neg(values[i]).String()}
+	}
+
+	err = recordJournalEntries(ctx, from, fromJournal)
+	if err != nil {
+		return err
+	}
+
+	// Recipients are unique, so each balance is read and written once
+	for i, recipient := range recipients {
+		err = creditBalance(ctx, from, recipient, values[i])
+		if err != nil {
+			return err
+		}
+
+		err = recordJournal(ctx, recipient, from, values[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emitBatchTransfer emits a BatchTransfer event listing the transfer to each recipient
+func emitBatchTransfer(ctx contractapi.TransactionContextInterface, from string, recipients []string, values []*big.Int, total *big.Int) error {
+	transfers := make([]event, len(recipients))
+	for i, recipient := range recipients {
+		transfers[i] = event{from, recipient, values[i].String()}
+	}
+
+	batchEventJSON, err := json.Marshal(batchTransferEvent{from, total.String(), transfers})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("BatchTransfer", batchEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
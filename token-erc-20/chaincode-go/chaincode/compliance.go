@@ -0,0 +1,342 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define key names for options
+const pausedKey = "paused"
+
+// Define objectType names for prefix
+const frozenPrefix = "frozen"
+
+// pauseEvent provides an organized struct for emitting Paused and Unpaused events
+type pauseEvent struct {
+	Account string `json:"account"`
+}
+
+// freezeEvent provides an organized struct for emitting AccountFrozen and AccountUnfrozen events
+type freezeEvent struct {
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// forceTransferEvent provides an organized struct for emitting ForceTransfer events
+type forceTransferEvent struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Operator string `json:"operator"`
+}
+
+// Pause halts all transfers, mints and burns until Unpause is called
+// Only clients holding the PAUSER role can pause the contract
+// This function triggers a Paused event
+func (s *SmartContract) Pause(ctx contractapi.TransactionContextInterface) error {
+	return setPaused(ctx, true)
+}
+
+// Unpause resumes transfers, mints and burns
+// Only clients holding the PAUSER role can unpause the contract
+// This function triggers an Unpaused event
+func (s *SmartContract) Unpause(ctx contractapi.TransactionContextInterface) error {
+	return setPaused(ctx, false)
+}
+
+// Paused returns whether transfers are currently halted
+func (s *SmartContract) Paused(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return isPaused(ctx)
+}
+
+// FreezeAccount blocks the account from sending, receiving, minting, burning or spending allowances
+// Only clients holding the ADMIN role can freeze accounts
+// This function triggers an AccountFrozen event
+func (s *SmartContract) FreezeAccount(ctx contractapi.TransactionContextInterface, account string) error {
+	return setFrozen(ctx, account, true)
+}
+
+// UnfreezeAccount lifts the block set by FreezeAccount
+// Only clients holding the ADMIN role can unfreeze accounts
+// This function triggers an AccountUnfrozen event
+func (s *SmartContract) UnfreezeAccount(ctx contractapi.TransactionContextInterface, account string) error {
+	return setFrozen(ctx, account, false)
+}
+
+// IsFrozen returns whether the account is frozen
+func (s *SmartContract) IsFrozen(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return isFrozen(ctx, account)
+}
+
+// ForceTransfer moves tokens out of a frozen account, e.g. to execute a court order
+// Only clients holding the ADMIN role can force a transfer, the recipient must not be frozen and the contract must not be paused
+// This function triggers a ForceTransfer event
+func (s *SmartContract) ForceTransfer(ctx contractapi.TransactionContextInterface, from string, to string, value string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to force transfers")
+	}
+
+	operator, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	frozen, err := isFrozen(ctx, from)
+	if err != nil {
+		return err
+	}
+	if !frozen {
+		return fmt.Errorf("account %s is not frozen, only frozen accounts can be force transferred from", from)
+	}
+
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	transferValue, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+
+	err = moveBalance(ctx, from, to, transferValue)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	// Emit the ForceTransfer event
+	forceEvent := forceTransferEvent{from, to, transferValue.String(), operator}
+	forceEventJSON, err := json.Marshal(forceEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("ForceTransfer", forceEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("operator %s force transferred %s from frozen account %s to %s", operator, transferValue, from, to)
+
+	return nil
+}
+
+// Helper Functions
+
+// setPaused updates the paused flag on behalf of a PAUSER
+func setPaused(ctx contractapi.TransactionContextInterface, paused bool) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check pauser authorization
+	authorized, err := clientHasRole(ctx, pauserRole)
+	if err != nil {
+		return fmt.Errorf("failed to check pauser role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to pause or unpause the contract")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	currentlyPaused, err := isPaused(ctx)
+	if err != nil {
+		return err
+	}
+	if currentlyPaused == paused {
+		return fmt.Errorf("contract paused state is already %t", paused)
+	}
+
+	eventName := "Unpaused"
+	if paused {
+		eventName = "Paused"
+		err = ctx.GetStub().PutState(pausedKey, []byte("true"))
+	} else {
+		err = ctx.GetStub().DelState(pausedKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update paused state: %v", err)
+	}
+
+	// Emit the Paused or Unpaused event
+	pauseEventJSON, err := json.Marshal(pauseEvent{sender})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, pauseEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("client %s set paused state to %t", sender, paused)
+
+	return nil
+}
+
+// setFrozen updates the frozen flag of the account on behalf of an ADMIN
+func setFrozen(ctx contractapi.TransactionContextInterface, account string, frozen bool) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to freeze or unfreeze accounts")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	currentlyFrozen, err := isFrozen(ctx, account)
+	if err != nil {
+		return err
+	}
+	if currentlyFrozen == frozen {
+		return fmt.Errorf("account %s frozen state is already %t", account, frozen)
+	}
+
+	frozenKey, err := ctx.GetStub().CreateCompositeKey(frozenPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", frozenPrefix, err)
+	}
+
+	eventName := "AccountUnfrozen"
+	if frozen {
+		eventName = "AccountFrozen"
+		err = ctx.GetStub().PutState(frozenKey, []byte("true"))
+	} else {
+		err = ctx.GetStub().DelState(frozenKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update frozen state of account %s: %v", account, err)
+	}
+
+	// Emit the AccountFrozen or AccountUnfrozen event
+	freezeEventJSON, err := json.Marshal(freezeEvent{account, sender})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, freezeEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("client %s set frozen state of account %s to %t", sender, account, frozen)
+
+	return nil
+}
+
+// isPaused reads the paused flag from the world state
+func isPaused(ctx contractapi.TransactionContextInterface) (bool, error) {
+	pausedBytes, err := ctx.GetStub().GetState(pausedKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read paused state from world state: %v", err)
+	}
+
+	return pausedBytes != nil, nil
+}
+
+// isFrozen reads the frozen flag of the account from the world state
+func isFrozen(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+	frozenKey, err := ctx.GetStub().CreateCompositeKey(frozenPrefix, []string{account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", frozenPrefix, err)
+	}
+
+	frozenBytes, err := ctx.GetStub().GetState(frozenKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read frozen state of account %s from world state: %v", account, err)
+	}
+
+	return frozenBytes != nil, nil
+}
+
+// checkNotPaused returns an error if the contract is paused
+func checkNotPaused(ctx contractapi.TransactionContextInterface) error {
+	paused, err := isPaused(ctx)
+	if err != nil {
+		return err
+	}
+	if paused {
+		return fmt.Errorf("token operations are paused")
+	}
+
+	return nil
+}
+
+// checkNotFrozen returns an error if the account is frozen
+func checkNotFrozen(ctx contractapi.TransactionContextInterface, account string) error {
+	frozen, err := isFrozen(ctx, account)
+	if err != nil {
+		return err
+	}
+	if frozen {
+		return fmt.Errorf("account %s is frozen", account)
+	}
+
+	return nil
+}
@@ -0,0 +1,353 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const journalPrefix = "journal"
+const foldedCreditsPrefix = "foldedCredits"
+
+// journalEntry is the transfer journal record of one balance change with one counterparty
+// The entries of an account in a transaction are stored as a JSON array under journal~account~txID, a batch transfer
+// records one entry per recipient for the sender. Records written before batches were journaled hold a single entry
+// Amount is a signed base-10 integer string, negative for a debit
+type journalEntry struct {
+	Counterparty string `json:"counterparty"`
+	Amount       string `json:"amount"`
+}
+
+// hotAccountCredit is a credit to a hot account read from its delta row
+// The credits folded into the balance key by a transaction are stored as a JSON array under foldedCredits~account~txID
+type hotAccountCredit struct {
+	TxID      string `json:"txId"`
+	Timestamp int64  `json:"timestamp"`
+	From      string `json:"from"`
+	Amount    string `json:"amount"`
+	key       string
+}
+
+// AccountHistoryEntry is one change of an account balance as returned by GetAccountHistory
+// Counterparty is "0x0" for mints and burns, a batch transfer has one entry for each of its recipients
+// Amount is a signed base-10 integer string, negative for a debit, and Balance is the balance after the change
+type AccountHistoryEntry struct {
+	TxID         string `json:"txId"`
+	Timestamp    int64  `json:"timestamp"`
+	Counterparty string `json:"counterparty"`
+	Amount       string `json:"amount"`
+	Balance      string `json:"balance"`
+}
+
+// PaginatedAccountHistory holds a page of account history and the bookmark to fetch the next page with
+type PaginatedAccountHistory struct {
+	Records             []*AccountHistoryEntry `json:"records"`
+	FetchedRecordsCount int32                  `json:"fetchedRecordsCount"`
+	Bookmark            string                 `json:"bookmark"`
+}
+
+// GetAccountHistory returns a page of the balance changes of the account, newest first
+// The running balance and timestamp come from the history of the balance key, the counterparty and amount from the transfer journal
+// Changes written before the journal existed have no counterparty, their amount is derived from the running balance
+// Credits to a hot account do not write its balance key. Pending credits are listed first on the first page, and consolidated
+// credits are listed before the change that folded them into the balance key, each with the transaction that made it
+// Pass an empty bookmark for the first page and the returned bookmark for the next one, an empty bookmark is returned on the last page
+// The entries of a transaction are never split across pages, so a page holds more than pageSize entries when the last transaction
+// of the page has several entries or folded several credits. The first page also holds every pending credit
+func (s *SmartContract) GetAccountHistory(ctx contractapi.TransactionContextInterface, account string, pageSize int, bookmark string) (*PaginatedAccountHistory, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of account %s: %v", account, err)
+	}
+	defer resultsIterator.Close()
+
+	records := []*AccountHistoryEntry{}
+	skipping := bookmark != ""
+	hasMore := false
+	lastTxID := ""
+
+	// Pending credits of a hot account are newer than any change of its balance key
+	if !skipping {
+		balance, _, err := readBalance(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+
+		pending, err := readHotAccountCredits(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+
+		err = appendCredits(&records, pending, balance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// An entry without a journal record waits for the next, older, balance to derive its amount
+	var unjournaled *AccountHistoryEntry
+	var unjournaledBalance *big.Int
+
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		balance := big.NewInt(0)
+		if !modification.IsDelete {
+			balance, err = amountFromBytes(modification.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read balance of account %s in transaction %s: %v", account, modification.TxId, err)
+			}
+		}
+
+		if unjournaled != nil {
+			unjournaled.Amount = new(big.Int).Sub(unjournaledBalance, balance).String()
+			unjournaled = nil
+		}
+
+		// The bookmark is the last transaction of the previous page
+		if skipping {
+			if modification.TxId == bookmark {
+				skipping = false
+			}
+			continue
+		}
+
+		if lastTxID != "" && len(records) >= pageSize {
+			hasMore = true
+			break
+		}
+		lastTxID = modification.TxId
+
+		var timestamp int64
+		if modification.Timestamp != nil {
+			timestamp = modification.Timestamp.Seconds
+		}
+
+		journal, err := readJournal(ctx, account, modification.TxId)
+		if err != nil {
+			return nil, err
+		}
+
+		folded, err := readFoldedCredits(ctx, account, modification.TxId)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(journal) == 0 && len(folded) == 0 {
+			unjournaled = &AccountHistoryEntry{
+				TxID:      modification.TxId,
+				Timestamp: timestamp,
+				Balance:   balance.String(),
+			}
+			unjournaledBalance = balance
+			records = append(records, unjournaled)
+			continue
+		}
+
+		// The entries are listed newest first, each with the balance after it
+		runningBalance := new(big.Int).Set(balance)
+		for i := len(journal) - 1; i >= 0; i-- {
+			amount, err := parseAmount(journal[i].Amount)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read journal of account %s in transaction %s: %v", account, modification.TxId, err)
+			}
+
+			records = append(records, &AccountHistoryEntry{
+				TxID:         modification.TxId,
+				Timestamp:    timestamp,
+				Counterparty: journal[i].Counterparty,
+				Amount:       journal[i].Amount,
+				Balance:      runningBalance.String(),
+			})
+
+			runningBalance.Sub(runningBalance, amount)
+		}
+
+		// The folded credits were made before the transaction that consolidated them
+		err = appendCredits(&records, folded, runningBalance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credits folded by transaction %s: %v", modification.TxId, err)
+		}
+	}
+
+	if skipping {
+		return nil, fmt.Errorf("bookmark %s not found in the history of account %s", bookmark, account)
+	}
+
+	// The oldest change of the account started from a zero balance
+	if unjournaled != nil {
+		unjournaled.Amount = unjournaledBalance.String()
+	}
+
+	nextBookmark := ""
+	if hasMore {
+		nextBookmark = lastTxID
+	}
+
+	return &PaginatedAccountHistory{
+		Records:             records,
+		FetchedRecordsCount: int32(len(records)),
+		Bookmark:            nextBookmark,
+	}, nil
+}
+
+// Helper Functions
+
+// appendCredits appends the hot account credits, newest first, to the records counting back from the balance after them
+func appendCredits(records *[]*AccountHistoryEntry, credits []hotAccountCredit, balance *big.Int) error {
+	runningBalance := new(big.Int).Set(balance)
+	for _, credit := range credits {
+		amount, err := parseAmount(credit.Amount)
+		if err != nil {
+			return err
+		}
+
+		*records = append(*records, &AccountHistoryEntry{
+			TxID:         credit.TxID,
+			Timestamp:    credit.Timestamp,
+			Counterparty: credit.From,
+			Amount:       credit.Amount,
+			Balance:      runningBalance.String(),
+		})
+
+		runningBalance.Sub(runningBalance, amount)
+	}
+
+	return nil
+}
+
+// recordFoldedCredits records the hot account credits folded into the balance key of the account in this transaction
+func recordFoldedCredits(ctx contractapi.TransactionContextInterface, account string, credits []hotAccountCredit) error {
+	foldedKey, err := ctx.GetStub().CreateCompositeKey(foldedCreditsPrefix, []string{account, ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", foldedCreditsPrefix, err)
+	}
+
+	creditsJSON, err := json.Marshal(credits)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(foldedKey, creditsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", foldedKey, err)
+	}
+
+	return nil
+}
+
+// readFoldedCredits reads the hot account credits folded into the balance key of the account by the transaction, newest first
+func readFoldedCredits(ctx contractapi.TransactionContextInterface, account string, txID string) ([]hotAccountCredit, error) {
+	foldedKey, err := ctx.GetStub().CreateCompositeKey(foldedCreditsPrefix, []string{account, txID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", foldedCreditsPrefix, err)
+	}
+
+	creditsBytes, err := ctx.GetStub().GetState(foldedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folded credits %s from world state: %v", foldedKey, err)
+	}
+	if creditsBytes == nil {
+		return nil, nil
+	}
+
+	var credits []hotAccountCredit
+	err = json.Unmarshal(creditsBytes, &credits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal folded credits %s: %v", foldedKey, err)
+	}
+
+	return credits, nil
+}
+
+// journalTransfer records a transfer of the value in the journal of both accounts
+func journalTransfer(ctx contractapi.TransactionContextInterface, from string, to string, value *big.Int) error {
+	err := recordJournal(ctx, from, to, new(big.Int).Neg(value))
+	if err != nil {
+		return err
+	}
+
+	return recordJournal(ctx, to, from, value)
+}
+
+// recordJournal records the signed amount the account's balance changed by in this transaction
+// An account can be journaled only once per transaction
+func recordJournal(ctx contractapi.TransactionContextInterface, account string, counterparty string, amount *big.Int) error {
+	return recordJournalEntries(ctx, account, []journalEntry{{counterparty, amount.String()}})
+}
+
+// recordJournalEntries records the balance changes of the account with each counterparty in this transaction
+// An account can be journaled only once per transaction, so all its entries must be recorded together
+func recordJournalEntries(ctx contractapi.TransactionContextInterface, account string, entries []journalEntry) error {
+	journalKey, err := ctx.GetStub().CreateCompositeKey(journalPrefix, []string{account, ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", journalPrefix, err)
+	}
+
+	journalJSON, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(journalKey, journalJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", journalKey, err)
+	}
+
+	return nil
+}
+
+// readJournal reads the journal entries of the account for the transaction, none if it was not journaled
+func readJournal(ctx contractapi.TransactionContextInterface, account string, txID string) ([]journalEntry, error) {
+	journalKey, err := ctx.GetStub().CreateCompositeKey(journalPrefix, []string{account, txID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", journalPrefix, err)
+	}
+
+	journalBytes, err := ctx.GetStub().GetState(journalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal %s from world state: %v", journalKey, err)
+	}
+	if len(journalBytes) == 0 {
+		return nil, nil
+	}
+
+	// Records written before batches were journaled hold a single entry
+	if journalBytes[0] != '[' {
+		journal := journalEntry{}
+		err = json.Unmarshal(journalBytes, &journal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal journal %s: %v", journalKey, err)
+		}
+
+		return []journalEntry{journal}, nil
+	}
+
+	var journal []journalEntry
+	err = json.Unmarshal(journalBytes, &journal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal journal %s: %v", journalKey, err)
+	}
+
+	return journal, nil
+}
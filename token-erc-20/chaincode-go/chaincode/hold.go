@@ -0,0 +1,550 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const holdPrefix = "hold"
+const balanceOnHoldPrefix = "balanceOnHold"
+
+// Hold statuses, following ERC-1996
+const (
+	holdOrdered              = "Ordered"
+	holdExecuted             = "Executed"
+	holdReleasedByNotary     = "ReleasedByNotary"
+	holdReleasedByPayee      = "ReleasedByPayee"
+	holdReleasedOnExpiration = "ReleasedOnExpiration"
+)
+
+// HoldData reserves tokens of the payer for a transfer to the payee that only the notary can execute
+// Value is a base-10 integer string, Expiration is a unix time in seconds, 0 if the hold never expires
+type HoldData struct {
+	OperationID string `json:"operationId"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Notary      string `json:"notary"`
+	Value       string `json:"value"`
+	Expiration  int64  `json:"expiration"`
+	Status      string `json:"status"`
+}
+
+// holdEvent provides an organized struct for emitting HoldCreated, HoldExecuted and HoldReleased events
+type holdEvent struct {
+	OperationID string `json:"operationId"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Notary      string `json:"notary"`
+	Value       string `json:"value"`
+	Status      string `json:"status"`
+}
+
+// Hold reserves amount of the calling client's tokens for a transfer to the "to" account
+// Held tokens stay in the payer's balance but cannot be transferred or burned until the hold is executed or released
+// param {String} operationId A unique identifier chosen by the payer
+// param {String} to The client account the tokens are transferred to when the hold is executed, it must not be empty or "0x0"
+// param {String} notary The client that can execute the hold
+// param {String} amount The amount to hold as a base-10 integer string
+// param {Number} expiration The unix time in seconds the hold expires at, 0 if it never expires
+// This function triggers a HoldCreated event
+func (s *SmartContract) Hold(ctx contractapi.TransactionContextInterface, operationID string, to string, notary string, amount string, expiration int64) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	payer, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, payer)
+	if err != nil {
+		return err
+	}
+
+	if operationID == "" {
+		return fmt.Errorf("operation id must not be empty")
+	}
+	// Executing a hold to an empty or zero address would burn the tokens outside of Burn
+	if to == "" || to == "0x0" {
+		return fmt.Errorf("hold recipient must be a client account")
+	}
+	if to == payer {
+		return fmt.Errorf("cannot hold tokens for a transfer to the same client account")
+	}
+	if notary == "" {
+		return fmt.Errorf("notary must not be empty")
+	}
+
+	value, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if value.Sign() <= 0 {
+		return fmt.Errorf("hold amount must be a positive integer")
+	}
+
+	if expiration != 0 {
+		expired, err := isExpired(ctx, expiration)
+		if err != nil {
+			return err
+		}
+		if expired {
+			return fmt.Errorf("hold expiration %d is not in the future", expiration)
+		}
+	}
+
+	_, exists, err := readHold(ctx, operationID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("hold %s already exists", operationID)
+	}
+
+	// Only the spendable balance can be held
+	balance, _, err := readBalance(ctx, payer)
+	if err != nil {
+		return err
+	}
+
+	onHold, err := readBalanceOnHold(ctx, payer)
+	if err != nil {
+		return err
+	}
+
+	updatedOnHold, err := add(onHold, value)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(updatedOnHold) < 0 {
+		return fmt.Errorf("client account %s has insufficient funds", payer)
+	}
+
+	hold := &HoldData{
+		OperationID: operationID,
+		From:        payer,
+		To:          to,
+		Notary:      notary,
+		Value:       value.String(),
+		Expiration:  expiration,
+		Status:      holdOrdered,
+	}
+
+	err = putHold(ctx, hold)
+	if err != nil {
+		return err
+	}
+
+	err = putBalanceOnHold(ctx, payer, updatedOnHold)
+	if err != nil {
+		return err
+	}
+
+	err = emitHoldEvent(ctx, "HoldCreated", hold)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s held %s for a transfer to %s with notary %s", payer, value, to, notary)
+
+	return nil
+}
+
+// ExecuteHold transfers the held tokens from the payer to the payee
+// Only the notary can execute a hold, and only before it expires
+// This function triggers a HoldExecuted event
+func (s *SmartContract) ExecuteHold(ctx contractapi.TransactionContextInterface, operationID string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	hold, err := readOrderedHold(ctx, operationID)
+	if err != nil {
+		return err
+	}
+
+	if clientID != hold.Notary {
+		return fmt.Errorf("client is not authorized to execute hold %s", operationID)
+	}
+
+	if hold.Expiration != 0 {
+		expired, err := isExpired(ctx, hold.Expiration)
+		if err != nil {
+			return err
+		}
+		if expired {
+			return fmt.Errorf("hold %s expired at %d", operationID, hold.Expiration)
+		}
+	}
+
+	// Executing a hold is a transfer, so it is subject to the same pause and freeze checks as transferHelper
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, hold.From)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, hold.To)
+	if err != nil {
+		return err
+	}
+
+	value, err := parseAmount(hold.Value)
+	if err != nil {
+		return err
+	}
+
+	onHold, err := readBalanceOnHold(ctx, hold.From)
+	if err != nil {
+		return err
+	}
+
+	updatedOnHold, err := sub(onHold, value)
+	if err != nil {
+		return err
+	}
+
+	// The held tokens are spent, so only the payer's other holds stay reserved
+	err = debitAccount(ctx, hold.From, value, updatedOnHold)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	err = creditBalance(ctx, hold.From, hold.To, value)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	err = journalTransfer(ctx, hold.From, hold.To, value)
+	if err != nil {
+		return err
+	}
+
+	err = putBalanceOnHold(ctx, hold.From, updatedOnHold)
+	if err != nil {
+		return err
+	}
+
+	hold.Status = holdExecuted
+	err = putHold(ctx, hold)
+	if err != nil {
+		return err
+	}
+
+	err = emitHoldEvent(ctx, "HoldExecuted", hold)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("notary %s executed hold %s, %s transferred from %s to %s", clientID, operationID, value, hold.From, hold.To)
+
+	return nil
+}
+
+// ReleaseHold gives the held tokens back to the payer without transferring them
+// The notary and the payee can release a hold at any time, the payer only once it has expired
+// This function triggers a HoldReleased event
+func (s *SmartContract) ReleaseHold(ctx contractapi.TransactionContextInterface, operationID string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	hold, err := readOrderedHold(ctx, operationID)
+	if err != nil {
+		return err
+	}
+
+	switch clientID {
+	case hold.Notary:
+		hold.Status = holdReleasedByNotary
+	case hold.To:
+		hold.Status = holdReleasedByPayee
+	case hold.From:
+		expired := false
+		if hold.Expiration != 0 {
+			expired, err = isExpired(ctx, hold.Expiration)
+			if err != nil {
+				return err
+			}
+		}
+		if !expired {
+			return fmt.Errorf("hold %s has not expired, only the notary or the payee can release it", operationID)
+		}
+		hold.Status = holdReleasedOnExpiration
+	default:
+		return fmt.Errorf("client is not authorized to release hold %s", operationID)
+	}
+
+	value, err := parseAmount(hold.Value)
+	if err != nil {
+		return err
+	}
+
+	onHold, err := readBalanceOnHold(ctx, hold.From)
+	if err != nil {
+		return err
+	}
+
+	updatedOnHold, err := sub(onHold, value)
+	if err != nil {
+		return err
+	}
+
+	err = putBalanceOnHold(ctx, hold.From, updatedOnHold)
+	if err != nil {
+		return err
+	}
+
+	err = putHold(ctx, hold)
+	if err != nil {
+		return err
+	}
+
+	err = emitHoldEvent(ctx, "HoldReleased", hold)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s released hold %s of %s on account %s", clientID, operationID, value, hold.From)
+
+	return nil
+}
+
+// BalanceOnHold returns the amount of the account's tokens that are held
+func (s *SmartContract) BalanceOnHold(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	onHold, err := readBalanceOnHold(ctx, account)
+	if err != nil {
+		return "", err
+	}
+
+	return onHold.String(), nil
+}
+
+// SpendableBalanceOf returns the balance of the account that is not held
+func (s *SmartContract) SpendableBalanceOf(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	balance, _, err := readBalance(ctx, account)
+	if err != nil {
+		return "", err
+	}
+
+	onHold, err := readBalanceOnHold(ctx, account)
+	if err != nil {
+		return "", err
+	}
+
+	return new(big.Int).Sub(balance, onHold).String(), nil
+}
+
+// RetrieveHoldData returns the hold with the given operation id
+func (s *SmartContract) RetrieveHoldData(ctx contractapi.TransactionContextInterface, operationID string) (*HoldData, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	hold, exists, err := readHold(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("hold %s does not exist", operationID)
+	}
+
+	return hold, nil
+}
+
+// Helper Functions
+
+// isExpired returns whether the expiration time has been reached at the transaction timestamp
+func isExpired(ctx contractapi.TransactionContextInterface, expiration int64) (bool, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return false, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return txTimestamp.Seconds >= expiration, nil
+}
+
+// readHold reads the hold with the given operation id from the world state
+func readHold(ctx contractapi.TransactionContextInterface, operationID string) (*HoldData, bool, error) {
+	holdKey, err := ctx.GetStub().CreateCompositeKey(holdPrefix, []string{operationID})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create the composite key for prefix %s: %v", holdPrefix, err)
+	}
+
+	holdBytes, err := ctx.GetStub().GetState(holdKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read hold %s from world state: %v", operationID, err)
+	}
+	if holdBytes == nil {
+		return nil, false, nil
+	}
+
+	hold := new(HoldData)
+	err = json.Unmarshal(holdBytes, hold)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal hold %s: %v", operationID, err)
+	}
+
+	return hold, true, nil
+}
+
+// readOrderedHold reads a hold that has been neither executed nor released
+func readOrderedHold(ctx contractapi.TransactionContextInterface, operationID string) (*HoldData, error) {
+	hold, exists, err := readHold(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("hold %s does not exist", operationID)
+	}
+	if hold.Status != holdOrdered {
+		return nil, fmt.Errorf("hold %s is already %s", operationID, hold.Status)
+	}
+
+	return hold, nil
+}
+
+// putHold writes the hold to the world state
+func putHold(ctx contractapi.TransactionContextInterface, hold *HoldData) error {
+	holdKey, err := ctx.GetStub().CreateCompositeKey(holdPrefix, []string{hold.OperationID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", holdPrefix, err)
+	}
+
+	holdBytes, err := json.Marshal(hold)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hold %s: %v", hold.OperationID, err)
+	}
+
+	err = ctx.GetStub().PutState(holdKey, holdBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", holdKey, err)
+	}
+
+	return nil
+}
+
+// readBalanceOnHold returns the sum of the account's ordered holds, 0 if there is none
+func readBalanceOnHold(ctx contractapi.TransactionContextInterface, account string) (*big.Int, error) {
+	onHoldKey, err := ctx.GetStub().CreateCompositeKey(balanceOnHoldPrefix, []string{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", balanceOnHoldPrefix, err)
+	}
+
+	onHoldBytes, err := ctx.GetStub().GetState(onHoldKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance on hold of account %s from world state: %v", account, err)
+	}
+
+	onHold, err := amountFromBytes(onHoldBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance on hold of account %s: %v", account, err)
+	}
+
+	return onHold, nil
+}
+
+// putBalanceOnHold writes the sum of the account's ordered holds, deleting the entry when it drops to zero
+func putBalanceOnHold(ctx contractapi.TransactionContextInterface, account string, onHold *big.Int) error {
+	onHoldKey, err := ctx.GetStub().CreateCompositeKey(balanceOnHoldPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", balanceOnHoldPrefix, err)
+	}
+
+	if onHold.Sign() == 0 {
+		err = ctx.GetStub().DelState(onHoldKey)
+	} else {
+		err = ctx.GetStub().PutState(onHoldKey, []byte(onHold.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update balance on hold of account %s: %v", account, err)
+	}
+
+	return nil
+}
+
+// emitHoldEvent emits a hold event for the hold
+func emitHoldEvent(ctx contractapi.TransactionContextInterface, eventName string, hold *HoldData) error {
+	holdEventJSON, err := json.Marshal(holdEvent{hold.OperationID, hold.From, hold.To, hold.Notary, hold.Value, hold.Status})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, holdEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,369 @@
+package chaincode
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const hotAccountPrefix = "hotAccount"
+const balanceDeltaPrefix = "balanceDelta"
+
+// A hot account receives credits as delta rows keyed balanceDelta.account.from.value.txID, like the high-throughput sample,
+// holding the timestamp of the crediting transaction.
+// Credits never read the account balance key, so concurrent transfers to the same account do not cause MVCC read conflicts.
+// The balance is the balance key plus the sum of the delta rows. Debits, mints and burns fold the delta rows back into the
+// balance key before checking it, so they stay strictly checked and are serialized as for any other account.
+
+// maxTrackedTransactions bounds the number of transactions whose hot account credits are remembered by creditedDeltas
+const maxTrackedTransactions = 1000
+
+// creditedDeltas remembers the delta keys written by the transactions recently executed by this chaincode process.
+// Reads in a transaction do not see its own writes, so a second equal credit from the same account in one transaction,
+// e.g. from a chaincode that invokes this contract twice, would write the same delta key and be lost.
+// Such a credit is rejected instead, which aborts the whole transaction.
+var creditedDeltas = struct {
+	sync.Mutex
+	txIDs []string
+	keys  map[string]map[string]bool
+}{keys: make(map[string]map[string]bool)}
+
+// EnableHotAccount switches the account to hot account mode
+// Only the account itself or a client holding the ADMIN role can enable hot account mode
+func (s *SmartContract) EnableHotAccount(ctx contractapi.TransactionContextInterface, account string) error {
+	return setHotAccount(ctx, account, true)
+}
+
+// DisableHotAccount consolidates the account and switches it back to a single balance key
+// Only the account itself or a client holding the ADMIN role can disable hot account mode
+func (s *SmartContract) DisableHotAccount(ctx contractapi.TransactionContextInterface, account string) error {
+	return setHotAccount(ctx, account, false)
+}
+
+// IsHotAccount returns whether the account is in hot account mode
+func (s *SmartContract) IsHotAccount(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return isHotAccount(ctx, account)
+}
+
+// Consolidate folds the delta rows of a hot account into its balance key
+// It does not change the balance, so any client can call it, e.g. during a maintenance window
+func (s *SmartContract) Consolidate(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	hot, err := isHotAccount(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if !hot {
+		return "", fmt.Errorf("account %s is not a hot account", account)
+	}
+
+	balance, exists, err := consolidateBalance(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("the account %s does not exist", account)
+	}
+
+	err = ctx.GetStub().PutState(account, []byte(balance.String()))
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("hot account %s consolidated to a balance of %s", account, balance)
+
+	return balance.String(), nil
+}
+
+// Helper Functions
+
+// setHotAccount updates the hot account flag on behalf of the account itself or an ADMIN
+func setHotAccount(ctx contractapi.TransactionContextInterface, account string, hot bool) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	if clientID != account {
+		authorized, err := clientHasRole(ctx, adminRole)
+		if err != nil {
+			return fmt.Errorf("failed to check admin role: %v", err)
+		}
+		if !authorized {
+			return fmt.Errorf("client is not authorized to change the hot account mode of account %s", account)
+		}
+	}
+
+	currentlyHot, err := isHotAccount(ctx, account)
+	if err != nil {
+		return err
+	}
+	if currentlyHot == hot {
+		return fmt.Errorf("account %s hot account mode is already %t", account, hot)
+	}
+
+	hotAccountKey, err := ctx.GetStub().CreateCompositeKey(hotAccountPrefix, []string{account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", hotAccountPrefix, err)
+	}
+
+	if hot {
+		err = ctx.GetStub().PutState(hotAccountKey, []byte("true"))
+		if err != nil {
+			return fmt.Errorf("failed to enable hot account mode of account %s: %v", account, err)
+		}
+
+		log.Printf("client %s enabled hot account mode of account %s", clientID, account)
+
+		return nil
+	}
+
+	// Fold the delta rows back before leaving hot account mode, balances are then read from the balance key only
+	balance, exists, err := consolidateBalance(ctx, account)
+	if err != nil {
+		return err
+	}
+	if exists {
+		err = ctx.GetStub().PutState(account, []byte(balance.String()))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ctx.GetStub().DelState(hotAccountKey)
+	if err != nil {
+		return fmt.Errorf("failed to disable hot account mode of account %s: %v", account, err)
+	}
+
+	log.Printf("client %s disabled hot account mode of account %s", clientID, account)
+
+	return nil
+}
+
+// isHotAccount reads the hot account flag of the account from the world state
+func isHotAccount(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+	hotAccountKey, err := ctx.GetStub().CreateCompositeKey(hotAccountPrefix, []string{account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", hotAccountPrefix, err)
+	}
+
+	hotAccountBytes, err := ctx.GetStub().GetState(hotAccountKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read hot account mode of account %s from world state: %v", account, err)
+	}
+
+	return hotAccountBytes != nil, nil
+}
+
+// readBalance returns the balance of the account, aggregating the delta rows of a hot account
+// exists is false if the account has never held tokens
+func readBalance(ctx contractapi.TransactionContextInterface, account string) (*big.Int, bool, error) {
+	return aggregateBalance(ctx, account, false)
+}
+
+// consolidateBalance returns the balance of the account like readBalance, and deletes the delta rows of a hot account
+// The caller must write the returned balance, or its updated balance, to the account balance key
+func consolidateBalance(ctx contractapi.TransactionContextInterface, account string) (*big.Int, bool, error) {
+	return aggregateBalance(ctx, account, true)
+}
+
+// aggregateBalance sums the balance key and, for hot accounts, the delta rows of the account
+func aggregateBalance(ctx contractapi.TransactionContextInterface, account string, deleteDeltas bool) (*big.Int, bool, error) {
+	balanceBytes, err := ctx.GetStub().GetState(account)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read account %s from world state: %v", account, err)
+	}
+
+	balance, err := amountFromBytes(balanceBytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read balance of account %s: %v", account, err)
+	}
+	exists := balanceBytes != nil
+
+	hot, err := isHotAccount(ctx, account)
+	if err != nil {
+		return nil, false, err
+	}
+	if !hot {
+		return balance, exists, nil
+	}
+
+	credits, err := readHotAccountCredits(ctx, account)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, credit := range credits {
+		delta, err := parseAmount(credit.Amount)
+		if err != nil {
+			return nil, false, err
+		}
+
+		balance, err = add(balance, delta)
+		if err != nil {
+			return nil, false, err
+		}
+		exists = true
+
+		if deleteDeltas {
+			err = ctx.GetStub().DelState(credit.key)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to delete balance delta %s: %v", credit.key, err)
+			}
+		}
+	}
+
+	// Keep the folded credits so that GetAccountHistory can list them with the change that consolidated them
+	if deleteDeltas && len(credits) > 0 {
+		err = recordFoldedCredits(ctx, account, credits)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return balance, exists, nil
+}
+
+// readHotAccountCredits returns the credits held in the delta rows of the account, newest first
+func readHotAccountCredits(ctx contractapi.TransactionContextInterface, account string) ([]hotAccountCredit, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balanceDeltaPrefix, []string{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance deltas of account %s: %v", account, err)
+	}
+	defer iterator.Close()
+
+	credits := []hotAccountCredit{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be account.from.value.txID
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 4 {
+			return nil, fmt.Errorf("expected composite key with four parts (account:from:value:txID)")
+		}
+
+		// Delta rows written before credits were timestamped hold the null character, their timestamp is left at 0
+		timestamp, err := strconv.ParseInt(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			timestamp = 0
+		}
+
+		credits = append(credits, hotAccountCredit{
+			TxID:      compositeKeyParts[3],
+			Timestamp: timestamp,
+			From:      compositeKeyParts[1],
+			Amount:    compositeKeyParts[2],
+			key:       queryResponse.Key,
+		})
+	}
+
+	sort.SliceStable(credits, func(i, j int) bool {
+		return credits[i].Timestamp > credits[j].Timestamp
+	})
+
+	return credits, nil
+}
+
+// creditHotAccount credits a hot account with a delta row, without reading its balance key
+// The delta key holds the debited account, so credits from different accounts in one transaction never share a key,
+// and a repeated credit of equal value from the same account in one transaction is rejected by markDeltaCredited
+func creditHotAccount(ctx contractapi.TransactionContextInterface, from string, account string, value *big.Int) error {
+
+	// Only the first credit after a snapshot needs to aggregate the balance to record it
+	err := recordSnapshotValue(ctx, balanceSnapshotPrefix, []string{account}, func() (*big.Int, error) {
+		balance, _, err := readBalance(ctx, account)
+		return balance, err
+	})
+	if err != nil {
+		return err
+	}
+
+	deltaKey, err := ctx.GetStub().CreateCompositeKey(balanceDeltaPrefix, []string{account, from, value.String(), ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", balanceDeltaPrefix, err)
+	}
+
+	if !markDeltaCredited(ctx.GetStub().GetTxID(), deltaKey) {
+		return fmt.Errorf("hot account %s was already credited with %s by %s in this transaction", account, value, from)
+	}
+
+	// The delta row holds the transaction timestamp, so that GetAccountHistory can order the credit
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(deltaKey, []byte(strconv.FormatInt(txTimestamp.Seconds, 10)))
+	if err != nil {
+		return fmt.Errorf("failed to credit hot account %s: %v", account, err)
+	}
+
+	return nil
+}
+
+// markDeltaCredited records that the transaction wrote the delta key, it returns false if the key was already written
+func markDeltaCredited(txID string, deltaKey string) bool {
+	creditedDeltas.Lock()
+	defer creditedDeltas.Unlock()
+
+	keys, ok := creditedDeltas.keys[txID]
+	if !ok {
+		keys = make(map[string]bool)
+		creditedDeltas.keys[txID] = keys
+		creditedDeltas.txIDs = append(creditedDeltas.txIDs, txID)
+
+		// Forget the oldest transaction, which has completed by the time as many later transactions have started
+		if len(creditedDeltas.txIDs) > maxTrackedTransactions {
+			delete(creditedDeltas.keys, creditedDeltas.txIDs[0])
+			creditedDeltas.txIDs = creditedDeltas.txIDs[1:]
+		}
+	}
+
+	if keys[deltaKey] {
+		return false
+	}
+	keys[deltaKey] = true
+
+	return true
+}
@@ -0,0 +1,511 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const multisigPrefix = "multisig"
+const multisigProposalPrefix = "multisigProposal"
+
+// multisigAccountPrefix starts the ID of every multisig account
+// A colon never appears in a base64 encoded client ID, so no client can submit transactions as a multisig account
+const multisigAccountPrefix = "multisig:"
+
+// Proposal statuses
+const (
+	proposalPending  = "Pending"
+	proposalExecuted = "Executed"
+)
+
+// MultisigAccount is an account whose tokens move only once Threshold of its Signers approved the transfer
+type MultisigAccount struct {
+	ID        string   `json:"id"`
+	Signers   []string `json:"signers"`
+	Threshold int      `json:"threshold"`
+}
+
+// TransferProposal is a transfer out of a multisig account waiting for the approval of its signers
+// Value is a base-10 integer string, Expiration is a unix time in seconds
+type TransferProposal struct {
+	ID         string   `json:"id"`
+	Account    string   `json:"account"`
+	To         string   `json:"to"`
+	Value      string   `json:"value"`
+	Proposer   string   `json:"proposer"`
+	Approvals  []string `json:"approvals"`
+	Expiration int64    `json:"expiration"`
+	Status     string   `json:"status"`
+}
+
+// CreateMultisigAccount creates an account controlled by the signers and returns its ID
+// Tokens are sent to the returned ID like to any other account, and leave it only through an executed proposal
+// param {[]String} signers The client IDs of the signers
+// param {Number} threshold The number of signer approvals a transfer needs, between 1 and the number of signers
+// This function triggers a MultisigAccountCreated event
+func (s *SmartContract) CreateMultisigAccount(ctx contractapi.TransactionContextInterface, signers []string, threshold int) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if len(signers) == 0 {
+		return "", fmt.Errorf("a multisig account needs at least one signer")
+	}
+	if threshold < 1 || threshold > len(signers) {
+		return "", fmt.Errorf("threshold %d must be between 1 and the number of signers %d", threshold, len(signers))
+	}
+
+	seen := make(map[string]bool)
+	for _, signer := range signers {
+		if signer == "" {
+			return "", fmt.Errorf("signer must not be empty")
+		}
+		if seen[signer] {
+			return "", fmt.Errorf("duplicate signer %s", signer)
+		}
+		seen[signer] = true
+	}
+
+	account := &MultisigAccount{
+		ID:        multisigAccountPrefix + ctx.GetStub().GetTxID(),
+		Signers:   signers,
+		Threshold: threshold,
+	}
+
+	multisigKey, err := ctx.GetStub().CreateCompositeKey(multisigPrefix, []string{account.ID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", multisigPrefix, err)
+	}
+
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(multisigKey, accountJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to update state of smart contract for key %s: %v", multisigKey, err)
+	}
+
+	err = ctx.GetStub().SetEvent("MultisigAccountCreated", accountJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("multisig account %s created with %d of %d signers", account.ID, threshold, len(signers))
+
+	return account.ID, nil
+}
+
+// ProposeTransfer proposes a transfer of value from the multisig account to the "to" account and returns the proposal ID
+// Only signers of the account can propose, and the proposal counts as the proposer's approval
+// param {String} value The amount to transfer as a base-10 integer string
+// param {Number} expiration The unix time in seconds the proposal expires at
+// This function triggers a TransferProposed event
+func (s *SmartContract) ProposeTransfer(ctx contractapi.TransactionContextInterface, account string, to string, value string, expiration int64) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	multisig, err := readMultisigAccount(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if !isSigner(multisig, clientID) {
+		return "", fmt.Errorf("client is not a signer of multisig account %s", account)
+	}
+
+	if to == account {
+		return "", fmt.Errorf("cannot transfer to and from same client account")
+	}
+
+	transferValue, err := parseAmount(value)
+	if err != nil {
+		return "", err
+	}
+	if transferValue.Sign() <= 0 {
+		return "", fmt.Errorf("transfer amount must be a positive integer")
+	}
+
+	expired, err := isExpired(ctx, expiration)
+	if err != nil {
+		return "", err
+	}
+	if expired {
+		return "", fmt.Errorf("proposal expiration %d is not in the future", expiration)
+	}
+
+	proposal := &TransferProposal{
+		ID:         ctx.GetStub().GetTxID(),
+		Account:    account,
+		To:         to,
+		Value:      transferValue.String(),
+		Proposer:   clientID,
+		Approvals:  []string{clientID},
+		Expiration: expiration,
+		Status:     proposalPending,
+	}
+
+	err = putProposal(ctx, proposal)
+	if err != nil {
+		return "", err
+	}
+
+	err = emitProposalEvent(ctx, "TransferProposed", proposal)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("signer %s proposed transfer %s of %s from %s to %s", clientID, proposal.ID, proposal.Value, account, to)
+
+	return proposal.ID, nil
+}
+
+// ApproveProposal adds the calling signer's approval to a pending proposal
+// This function triggers a ProposalApproved event
+func (s *SmartContract) ApproveProposal(ctx contractapi.TransactionContextInterface, account string, proposalID string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	_, proposal, err := readSignerProposal(ctx, account, proposalID, clientID)
+	if err != nil {
+		return err
+	}
+
+	for _, approver := range proposal.Approvals {
+		if approver == clientID {
+			return fmt.Errorf("signer %s already approved proposal %s", clientID, proposalID)
+		}
+	}
+	proposal.Approvals = append(proposal.Approvals, clientID)
+
+	err = putProposal(ctx, proposal)
+	if err != nil {
+		return err
+	}
+
+	err = emitProposalEvent(ctx, "ProposalApproved", proposal)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("signer %s approved proposal %s, %d approvals", clientID, proposalID, len(proposal.Approvals))
+
+	return nil
+}
+
+// RevokeApproval removes the calling signer's approval from a pending proposal
+// This function triggers an ApprovalRevoked event
+func (s *SmartContract) RevokeApproval(ctx contractapi.TransactionContextInterface, account string, proposalID string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	_, proposal, err := readSignerProposal(ctx, account, proposalID, clientID)
+	if err != nil {
+		return err
+	}
+
+	approvals := []string{}
+	for _, approver := range proposal.Approvals {
+		if approver != clientID {
+			approvals = append(approvals, approver)
+		}
+	}
+	if len(approvals) == len(proposal.Approvals) {
+		return fmt.Errorf("signer %s has not approved proposal %s", clientID, proposalID)
+	}
+	proposal.Approvals = approvals
+
+	err = putProposal(ctx, proposal)
+	if err != nil {
+		return err
+	}
+
+	err = emitProposalEvent(ctx, "ApprovalRevoked", proposal)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("signer %s revoked its approval of proposal %s, %d approvals", clientID, proposalID, len(proposal.Approvals))
+
+	return nil
+}
+
+// ExecuteProposal transfers the proposed value out of the multisig account once the threshold of approvals is met
+// Any signer can execute a proposal, and only before it expires
+// This function triggers a ProposalExecuted event
+func (s *SmartContract) ExecuteProposal(ctx contractapi.TransactionContextInterface, account string, proposalID string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	multisig, proposal, err := readSignerProposal(ctx, account, proposalID, clientID)
+	if err != nil {
+		return err
+	}
+
+	if len(proposal.Approvals) < multisig.Threshold {
+		return fmt.Errorf("proposal %s has %d approvals, %d are required", proposalID, len(proposal.Approvals), multisig.Threshold)
+	}
+
+	value, err := parseAmount(proposal.Value)
+	if err != nil {
+		return err
+	}
+
+	err = transferHelper(ctx, proposal.Account, proposal.To, value)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	proposal.Status = proposalExecuted
+	err = putProposal(ctx, proposal)
+	if err != nil {
+		return err
+	}
+
+	err = emitProposalEvent(ctx, "ProposalExecuted", proposal)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("signer %s executed proposal %s, %s transferred from %s to %s", clientID, proposalID, value, proposal.Account, proposal.To)
+
+	return nil
+}
+
+// GetMultisigAccount returns the signers and threshold of a multisig account
+func (s *SmartContract) GetMultisigAccount(ctx contractapi.TransactionContextInterface, account string) (*MultisigAccount, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return readMultisigAccount(ctx, account)
+}
+
+// PendingProposals returns the proposals of the multisig account that are neither executed nor expired
+func (s *SmartContract) PendingProposals(ctx contractapi.TransactionContextInterface, account string) ([]*TransferProposal, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(multisigProposalPrefix, []string{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposals of multisig account %s: %v", account, err)
+	}
+	defer iterator.Close()
+
+	proposals := []*TransferProposal{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		proposal := new(TransferProposal)
+		err = json.Unmarshal(queryResponse.Value, proposal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal proposal %s: %v", queryResponse.Key, err)
+		}
+		if proposal.Status != proposalPending {
+			continue
+		}
+
+		expired, err := isExpired(ctx, proposal.Expiration)
+		if err != nil {
+			return nil, err
+		}
+		if !expired {
+			proposals = append(proposals, proposal)
+		}
+	}
+
+	return proposals, nil
+}
+
+// Helper Functions
+
+// isSigner returns whether the client is a signer of the multisig account
+func isSigner(multisig *MultisigAccount, clientID string) bool {
+	for _, signer := range multisig.Signers {
+		if signer == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readMultisigAccount reads the multisig account with the given ID from the world state
+func readMultisigAccount(ctx contractapi.TransactionContextInterface, account string) (*MultisigAccount, error) {
+	multisigKey, err := ctx.GetStub().CreateCompositeKey(multisigPrefix, []string{account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", multisigPrefix, err)
+	}
+
+	accountBytes, err := ctx.GetStub().GetState(multisigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read multisig account %s from world state: %v", account, err)
+	}
+	if accountBytes == nil {
+		return nil, fmt.Errorf("multisig account %s does not exist", account)
+	}
+
+	multisig := new(MultisigAccount)
+	err = json.Unmarshal(accountBytes, multisig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal multisig account %s: %v", account, err)
+	}
+
+	return multisig, nil
+}
+
+// readSignerProposal reads a pending, unexpired proposal of the multisig account on behalf of one of its signers
+func readSignerProposal(ctx contractapi.TransactionContextInterface, account string, proposalID string, clientID string) (*MultisigAccount, *TransferProposal, error) {
+	multisig, err := readMultisigAccount(ctx, account)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isSigner(multisig, clientID) {
+		return nil, nil, fmt.Errorf("client is not a signer of multisig account %s", account)
+	}
+
+	proposalKey, err := ctx.GetStub().CreateCompositeKey(multisigProposalPrefix, []string{account, proposalID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", multisigProposalPrefix, err)
+	}
+
+	proposalBytes, err := ctx.GetStub().GetState(proposalKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read proposal %s from world state: %v", proposalID, err)
+	}
+	if proposalBytes == nil {
+		return nil, nil, fmt.Errorf("proposal %s does not exist for multisig account %s", proposalID, account)
+	}
+
+	proposal := new(TransferProposal)
+	err = json.Unmarshal(proposalBytes, proposal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal proposal %s: %v", proposalID, err)
+	}
+
+	if proposal.Status != proposalPending {
+		return nil, nil, fmt.Errorf("proposal %s is already %s", proposalID, proposal.Status)
+	}
+
+	expired, err := isExpired(ctx, proposal.Expiration)
+	if err != nil {
+		return nil, nil, err
+	}
+	if expired {
+		return nil, nil, fmt.Errorf("proposal %s expired at %d", proposalID, proposal.Expiration)
+	}
+
+	return multisig, proposal, nil
+}
+
+// putProposal writes the proposal to the world state
+func putProposal(ctx contractapi.TransactionContextInterface, proposal *TransferProposal) error {
+	proposalKey, err := ctx.GetStub().CreateCompositeKey(multisigProposalPrefix, []string{proposal.Account, proposal.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", multisigProposalPrefix, err)
+	}
+
+	proposalBytes, err := json.Marshal(proposal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proposal %s: %v", proposal.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(proposalKey, proposalBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", proposalKey, err)
+	}
+
+	return nil
+}
+
+// emitProposalEvent emits a proposal event for the proposal
+func emitProposalEvent(ctx contractapi.TransactionContextInterface, eventName string, proposal *TransferProposal) error {
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, proposalJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,399 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const mspRootPrefix = "mspRoot"
+const noncePrefix = "nonce"
+
+// permitMessage is the canonical message an owner signs to authorize a Permit
+// Fields are marshalled in declaration order, and the channel and token symbol keep a permit from being replayed against another deployment
+type permitMessage struct {
+	Channel  string `json:"channel"`
+	Symbol   string `json:"symbol"`
+	Owner    string `json:"owner"`
+	Spender  string `json:"spender"`
+	Value    string `json:"value"`
+	Nonce    int    `json:"nonce"`
+	Deadline int64  `json:"deadline"`
+}
+
+// SetMSPRootCertificates records the PEM encoded root and intermediate CA certificates of an organization of the channel
+// Permit only accepts owner certificates that chain to the certificates of one of the recorded organizations
+// Calling it again for the same MSP ID replaces its certificates
+// Only clients holding the ADMIN role can record CA certificates
+func (s *SmartContract) SetMSPRootCertificates(ctx contractapi.TransactionContextInterface, mspID string, certificates string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to set MSP root certificates")
+	}
+
+	if mspID == "" {
+		return fmt.Errorf("MSP ID must not be empty")
+	}
+
+	caCerts, err := parseCertificates(certificates)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificates of %s: %v", mspID, err)
+	}
+	for _, caCert := range caCerts {
+		if !caCert.IsCA {
+			return fmt.Errorf("certificate %s of %s is not a CA certificate", caCert.Subject, mspID)
+		}
+	}
+
+	mspRootKey, err := ctx.GetStub().CreateCompositeKey(mspRootPrefix, []string{mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", mspRootPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(mspRootKey, []byte(certificates))
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", mspRootKey, err)
+	}
+
+	log.Printf("recorded %d CA certificates of %s", len(caCerts), mspID)
+
+	return nil
+}
+
+// Nonces returns the nonce the owner's next Permit must be signed with
+func (s *SmartContract) Nonces(ctx contractapi.TransactionContextInterface, owner string) (int, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return readNonce(ctx, owner)
+}
+
+// Permit sets the allowance of the spender over the owner's tokens, authorized by the owner's off-chain signature instead of the owner's transaction
+// The owner signs the SHA-256 hash of the JSON encoded permitMessage with the private key of their enrollment certificate
+// The owner never has to submit a transaction, a relayer submits the permit together with the owner's certificate
+// param {String} value The allowance as a base-10 integer string
+// param {Number} deadline The unix time in seconds after which the permit is no longer valid
+// param {Number} nonce The owner's current nonce as returned by Nonces()
+// param {String} signature The base64 encoded ASN.1 ECDSA signature
+// param {String} certificate The owner's PEM encoded enrollment certificate, it must chain to the CA certificates recorded with SetMSPRootCertificates() and its client ID must be the owner
+// This function triggers an Approval event
+func (s *SmartContract) Permit(ctx contractapi.TransactionContextInterface, owner string, spender string, value string, deadline int64, nonce int, signature string, certificate string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	allowance, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+	if allowance.Sign() < 0 {
+		return fmt.Errorf("allowance cannot be negative")
+	}
+
+	// Check the deadline against the transaction timestamp, which is the same on every endorsing peer
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	if txTimestamp.Seconds > deadline {
+		return fmt.Errorf("permit expired at %d", deadline)
+	}
+
+	// Check the owner's nonce, permits have to be submitted in order and cannot be replayed
+	currentNonce, err := readNonce(ctx, owner)
+	if err != nil {
+		return err
+	}
+	if nonce != currentNonce {
+		return fmt.Errorf("invalid nonce %d, expected %d", nonce, currentNonce)
+	}
+
+	// Verify the owner's signature over the canonical message
+	symbol, err := ctx.GetStub().GetState(symbolKey)
+	if err != nil {
+		return fmt.Errorf("failed to get Symbol: %v", err)
+	}
+
+	message := permitMessage{
+		Channel:  ctx.GetStub().GetChannelID(),
+		Symbol:   string(symbol),
+		Owner:    owner,
+		Spender:  spender,
+		Value:    allowance.String(),
+		Nonce:    nonce,
+		Deadline: deadline,
+	}
+	err = verifyOwnerSignature(ctx, owner, certificate, message, signature)
+	if err != nil {
+		return err
+	}
+
+	// Consume the nonce
+	nonceKey, err := ctx.GetStub().CreateCompositeKey(noncePrefix, []string{owner})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", noncePrefix, err)
+	}
+	err = ctx.GetStub().PutState(nonceKey, []byte(strconv.Itoa(currentNonce+1)))
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", nonceKey, err)
+	}
+
+	// Update the state of the smart contract with the allowance
+	err = putAllowance(ctx, owner, spender, allowance)
+	if err != nil {
+		return err
+	}
+
+	// Emit the Approval event
+	err = emitApproval(ctx, owner, spender, allowance)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("owner %s permitted a withdrawal allowance of %s for spender %s with nonce %d", owner, allowance, spender, nonce)
+
+	return nil
+}
+
+// Helper Functions
+
+// readNonce returns the owner's current permit nonce, 0 if the owner never used a permit
+func readNonce(ctx contractapi.TransactionContextInterface, owner string) (int, error) {
+	nonceKey, err := ctx.GetStub().CreateCompositeKey(noncePrefix, []string{owner})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the composite key for prefix %s: %v", noncePrefix, err)
+	}
+
+	nonceBytes, err := ctx.GetStub().GetState(nonceKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read nonce of %s from world state: %v", owner, err)
+	}
+	if nonceBytes == nil {
+		return 0, nil
+	}
+
+	nonce, err := strconv.Atoi(string(nonceBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse nonce of %s: %v", owner, err)
+	}
+
+	return nonce, nil
+}
+
+// verifyOwnerSignature checks the base64 encoded ECDSA signature of the message against the owner's certificate
+func verifyOwnerSignature(ctx contractapi.TransactionContextInterface, owner string, certificate string, message permitMessage, signature string) error {
+	cert, err := verifyOwnerCertificate(ctx, owner, certificate)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certificate of %s does not hold an ECDSA public key", owner)
+	}
+
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	digest := sha256.Sum256(messageJSON)
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signatureBytes) {
+		return fmt.Errorf("invalid signature for owner %s", owner)
+	}
+
+	return nil
+}
+
+// verifyOwnerCertificate parses the PEM encoded certificate, checks that the client ID it yields is the owner
+// and that it chains to the CA certificates of one of the organizations recorded with SetMSPRootCertificates()
+func verifyOwnerCertificate(ctx contractapi.TransactionContextInterface, owner string, certificate string) (*x509.Certificate, error) {
+	certs, err := parseCertificates(certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate of %s: %v", owner, err)
+	}
+	if len(certs) != 1 {
+		return nil, fmt.Errorf("expected a single certificate for %s, got %d", owner, len(certs))
+	}
+	cert := certs[0]
+
+	// The client ID is built like GetClientIdentity().GetID() builds it from the submitting identity's certificate
+	clientID := fmt.Sprintf("x509::%s::%s", distinguishedName(&cert.Subject), distinguishedName(&cert.Issuer))
+	if base64.StdEncoding.EncodeToString([]byte(clientID)) != owner {
+		return nil, fmt.Errorf("certificate does not belong to owner %s", owner)
+	}
+
+	// Check the chain at the transaction timestamp, which is the same on every endorsing peer
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(mspRootPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP root certificates: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		caCerts, err := parseCertificates(string(queryResponse.Value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MSP root certificates: %v", err)
+		}
+
+		// Every recorded CA certificate of the organization is a trust anchor
+		roots := x509.NewCertPool()
+		for _, caCert := range caCerts {
+			roots.AddCert(caCert)
+		}
+
+		_, err = cert.Verify(x509.VerifyOptions{
+			Roots:       roots,
+			CurrentTime: time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)),
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err == nil {
+			return cert, nil
+		}
+	}
+
+	return nil, fmt.Errorf("certificate of %s does not chain to the CA certificates of any recorded organization", owner)
+}
+
+// parseCertificates parses a PEM bundle holding one or more certificates
+func parseCertificates(certificates string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(certificates)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block of type %s", block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+
+	return certs, nil
+}
+
+// attributeTypeNames are the attribute types that distinguishedName writes by name, other types are written as an OID
+var attributeTypeNames = map[string]string{
+	"2.5.4.6":  "C",
+	"2.5.4.10": "O",
+	"2.5.4.11": "OU",
+	"2.5.4.3":  "CN",
+	"2.5.4.5":  "SERIALNUMBER",
+	"2.5.4.7":  "L",
+	"2.5.4.8":  "ST",
+	"2.5.4.9":  "STREET",
+	"2.5.4.17": "POSTALCODE",
+}
+
+// distinguishedName returns the RFC 2253 distinguished name exactly as the cid package writes it into client IDs,
+// which differs from pkix.Name.String() for attribute types that are not in attributeTypeNames
+func distinguishedName(name *pkix.Name) string {
+	r := name.ToRDNSequence()
+	s := ""
+	for i := 0; i < len(r); i++ {
+		rdn := r[len(r)-1-i]
+		if i > 0 {
+			s += ","
+		}
+		for j, tv := range rdn {
+			if j > 0 {
+				s += "+"
+			}
+			typeString := tv.Type.String()
+			typeName, ok := attributeTypeNames[typeString]
+			if !ok {
+				derBytes, err := asn1.Marshal(tv.Value)
+				if err == nil {
+					s += typeString + "=#" + hex.EncodeToString(derBytes)
+					continue // No value escaping necessary.
+				}
+				typeName = typeString
+			}
+			valueString := fmt.Sprint(tv.Value)
+			escaped := ""
+			begin := 0
+			for idx, c := range valueString {
+				if (idx == 0 && (c == ' ' || c == '#')) ||
+					(idx == len(valueString)-1 && c == ' ') {
+					escaped += valueString[begin:idx]
+					escaped += "\\" + string(c)
+					begin = idx + 1
+					continue
+				}
+				switch c {
+				case ',', '+', '"', '\\', '<', '>', ';':
+					escaped += valueString[begin:idx]
+					escaped += "\\" + string(c)
+					begin = idx + 1
+				}
+			}
+			escaped += valueString[begin:]
+			s += typeName + "=" + escaped
+		}
+	}
+	return s
+}
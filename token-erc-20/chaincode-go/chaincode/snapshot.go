@@ -0,0 +1,264 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define key names for options
+const currentSnapshotIDKey = "currentSnapshotId"
+
+// Define objectType names for prefix
+const balanceSnapshotPrefix = "balanceSnapshot"
+const totalSupplySnapshotPrefix = "totalSupplySnapshot"
+
+// snapshotEvent provides an organized struct for emitting Snapshot events
+type snapshotEvent struct {
+	ID int `json:"id"`
+}
+
+// Snapshot records the current balances and total supply under a new snapshot ID, which is returned
+// Balances are not copied, the value of an account is recorded lazily the first time it changes after the snapshot
+// Only clients holding the ADMIN role can take snapshots
+// This function triggers a Snapshot event
+func (s *SmartContract) Snapshot(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return 0, fmt.Errorf("client is not authorized to take snapshots")
+	}
+
+	currentID, err := currentSnapshotID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	snapshotID := currentID + 1
+	err = ctx.GetStub().PutState(currentSnapshotIDKey, []byte(strconv.Itoa(snapshotID)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to update current snapshot id: %v", err)
+	}
+
+	// Emit the Snapshot event
+	snapshotEventJSON, err := json.Marshal(snapshotEvent{snapshotID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("Snapshot", snapshotEventJSON)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("snapshot %d taken", snapshotID)
+
+	return snapshotID, nil
+}
+
+// BalanceOfAt returns the balance of the account at the time the snapshot was taken
+func (s *SmartContract) BalanceOfAt(ctx contractapi.TransactionContextInterface, account string, snapshotID int) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	recorded, found, err := snapshotValueAt(ctx, balanceSnapshotPrefix, []string{account}, snapshotID)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return recorded.String(), nil
+	}
+
+	// The balance has not changed since the snapshot, so the current balance is the balance at the snapshot
+	balance, _, err := readBalance(ctx, account)
+	if err != nil {
+		return "", err
+	}
+
+	return balance.String(), nil
+}
+
+// TotalSupplyAt returns the total token supply at the time the snapshot was taken
+func (s *SmartContract) TotalSupplyAt(ctx contractapi.TransactionContextInterface, snapshotID int) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	recorded, found, err := snapshotValueAt(ctx, totalSupplySnapshotPrefix, []string{}, snapshotID)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return recorded.String(), nil
+	}
+
+	// The total supply has not changed since the snapshot, so the current supply is the supply at the snapshot
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+
+	totalSupply, err := amountFromBytes(totalSupplyBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to read total token supply: %v", err)
+	}
+
+	return totalSupply.String(), nil
+}
+
+// Helper Functions
+
+// currentSnapshotID returns the ID of the latest snapshot, 0 if no snapshot was taken
+func currentSnapshotID(ctx contractapi.TransactionContextInterface) (int, error) {
+	idBytes, err := ctx.GetStub().GetState(currentSnapshotIDKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read current snapshot id from world state: %v", err)
+	}
+	if idBytes == nil {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(string(idBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse current snapshot id: %v", err)
+	}
+
+	return id, nil
+}
+
+// snapshotKeyID formats a snapshot ID so that composite keys sort in snapshot order
+func snapshotKeyID(id int) string {
+	return fmt.Sprintf("%020d", id)
+}
+
+// snapshotBalance records the balance of the account before its first change after the latest snapshot
+// It must be called with the current balance before the account balance is updated
+func snapshotBalance(ctx contractapi.TransactionContextInterface, account string, currentBalance *big.Int) error {
+	return recordSnapshotValue(ctx, balanceSnapshotPrefix, []string{account}, func() (*big.Int, error) {
+		return currentBalance, nil
+	})
+}
+
+// snapshotTotalSupply records the total supply before its first change after the latest snapshot
+// It must be called with the current total supply before the total supply is updated
+func snapshotTotalSupply(ctx contractapi.TransactionContextInterface, currentTotalSupply *big.Int) error {
+	return recordSnapshotValue(ctx, totalSupplySnapshotPrefix, []string{}, func() (*big.Int, error) {
+		return currentTotalSupply, nil
+	})
+}
+
+// recordSnapshotValue stores the current value under the latest snapshot ID unless a value was already recorded for it
+// currentValue is only called when a value has to be recorded
+func recordSnapshotValue(ctx contractapi.TransactionContextInterface, prefix string, attributes []string, currentValue func() (*big.Int, error)) error {
+	id, err := currentSnapshotID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Nothing to record before the first snapshot
+	if id == 0 {
+		return nil
+	}
+
+	snapshotKey, err := ctx.GetStub().CreateCompositeKey(prefix, append(attributes, snapshotKeyID(id)))
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", prefix, err)
+	}
+
+	recordedBytes, err := ctx.GetStub().GetState(snapshotKey)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot %s from world state: %v", snapshotKey, err)
+	}
+	if recordedBytes != nil {
+		return nil
+	}
+
+	value, err := currentValue()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(snapshotKey, []byte(value.String()))
+	if err != nil {
+		return fmt.Errorf("failed to record snapshot %s: %v", snapshotKey, err)
+	}
+
+	return nil
+}
+
+// snapshotValueAt returns the value recorded for the first snapshot at or after snapshotID
+// If the value has not changed since snapshotID, found is false and the current value applies
+func snapshotValueAt(ctx contractapi.TransactionContextInterface, prefix string, attributes []string, snapshotID int) (*big.Int, bool, error) {
+	currentID, err := currentSnapshotID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if snapshotID <= 0 || snapshotID > currentID {
+		return nil, false, fmt.Errorf("snapshot %d does not exist", snapshotID)
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(prefix, attributes)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get snapshots for prefix %s: %v", prefix, err)
+	}
+	defer iterator.Close()
+
+	// Composite keys are ordered by the zero padded snapshot ID, so the first ID at or after snapshotID holds the value
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, false, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, false, err
+		}
+
+		recordedID, err := strconv.Atoi(compositeKeyParts[len(compositeKeyParts)-1])
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse snapshot id of %s: %v", queryResponse.Key, err)
+		}
+		if recordedID < snapshotID {
+			continue
+		}
+
+		value, err := amountFromBytes(queryResponse.Value)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return value, true, nil
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,555 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const supplyRequestPrefix = "supplyRequest"
+const openSupplyRequestPrefix = "openSupplyRequest"
+
+// Define key names for options
+const separationOfDutiesKey = "separationOfDuties"
+
+// Supply request types and statuses
+const (
+	supplyRequestMint     = "Mint"
+	supplyRequestBurn     = "Burn"
+	supplyRequestOpen     = "Open"
+	supplyRequestApproved = "Approved"
+	supplyRequestRejected = "Rejected"
+)
+
+// SupplyRequest is a mint or burn waiting for a second identity to approve it, stored under supplyRequest~id
+// A mint credits Account on approval, a burn debits it, Amount is a base-10 integer string
+// Reference is free text linking the request to the off-chain operation, such as a payment order
+type SupplyRequest struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Account   string `json:"account"`
+	Amount    string `json:"amount"`
+	Reference string `json:"reference"`
+	Requester string `json:"requester"`
+	Checker   string `json:"checker"`
+	Status    string `json:"status"`
+}
+
+// separationOfDutiesEvent provides an organized struct for emitting the SeparationOfDutiesEnabled event
+type separationOfDutiesEvent struct {
+	Account string `json:"account"`
+}
+
+// EnableSeparationOfDuties disables the direct Mint, Burn and CreateVestingSchedule paths, so that every supply change
+// needs a request and the approval of a second identity. Separation of duties can not be disabled once enabled
+// Only clients holding the ADMIN role can enable separation of duties
+// This function triggers a SeparationOfDutiesEnabled event
+func (s *SmartContract) EnableSeparationOfDuties(ctx contractapi.TransactionContextInterface) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to enable separation of duties")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	enabled, err := isSeparationOfDutiesEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return fmt.Errorf("separation of duties is already enabled")
+	}
+
+	err = ctx.GetStub().PutState(separationOfDutiesKey, []byte("true"))
+	if err != nil {
+		return fmt.Errorf("failed to enable separation of duties: %v", err)
+	}
+
+	// Emit the SeparationOfDutiesEnabled event
+	separationOfDutiesEventJSON, err := json.Marshal(separationOfDutiesEvent{sender})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("SeparationOfDutiesEnabled", separationOfDutiesEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("client %s enabled separation of duties", sender)
+
+	return nil
+}
+
+// SeparationOfDutiesEnabled returns true if supply changes can only be made through approved requests
+func (s *SmartContract) SeparationOfDutiesEnabled(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return isSeparationOfDutiesEnabled(ctx)
+}
+
+// RequestMint requests a mint of amount tokens to the beneficiary and returns the request ID
+// Only clients holding the MINTER role can request mints, and a different MINTER has to approve the request
+// param {String} amount The amount to mint as a base-10 integer string
+// This function triggers a MintRequested event
+func (s *SmartContract) RequestMint(ctx contractapi.TransactionContextInterface, amount string, beneficiary string, reference string) (string, error) {
+	return requestSupplyChange(ctx, supplyRequestMint, amount, beneficiary, reference)
+}
+
+// ApproveMint mints the tokens of an open mint request to its beneficiary
+// Only clients holding the MINTER role other than the requester can approve a mint
+// This function triggers a Transfer event
+func (s *SmartContract) ApproveMint(ctx contractapi.TransactionContextInterface, requestID string) error {
+	return approveSupplyChange(ctx, supplyRequestMint, requestID)
+}
+
+// RejectMint closes an open mint request without minting
+// Any client holding the MINTER role can reject a mint, including the requester withdrawing its own request
+// This function triggers a MintRejected event
+func (s *SmartContract) RejectMint(ctx contractapi.TransactionContextInterface, requestID string) error {
+	return rejectSupplyChange(ctx, supplyRequestMint, requestID)
+}
+
+// RequestBurn requests a redemption of amount tokens from the account and returns the request ID
+// Only clients holding the BURNER role can request burns, and a different BURNER has to approve the request
+// To burn from an account other than its own, the requester needs an allowance from the account, which the burn consumes
+// param {String} amount The amount to burn as a base-10 integer string
+// This function triggers a BurnRequested event
+func (s *SmartContract) RequestBurn(ctx contractapi.TransactionContextInterface, amount string, account string, reference string) (string, error) {
+	return requestSupplyChange(ctx, supplyRequestBurn, amount, account, reference)
+}
+
+// ApproveBurn burns the tokens of an open burn request from its account
+// Only clients holding the BURNER role other than the requester can approve a burn
+// This function triggers a Transfer event
+func (s *SmartContract) ApproveBurn(ctx contractapi.TransactionContextInterface, requestID string) error {
+	return approveSupplyChange(ctx, supplyRequestBurn, requestID)
+}
+
+// RejectBurn closes an open burn request without burning
+// Any client holding the BURNER role can reject a burn, including the requester withdrawing its own request
+// This function triggers a BurnRejected event
+func (s *SmartContract) RejectBurn(ctx contractapi.TransactionContextInterface, requestID string) error {
+	return rejectSupplyChange(ctx, supplyRequestBurn, requestID)
+}
+
+// GetSupplyRequest returns the mint or burn request with the given ID
+func (s *SmartContract) GetSupplyRequest(ctx contractapi.TransactionContextInterface, requestID string) (*SupplyRequest, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return readSupplyRequest(ctx, requestID)
+}
+
+// OpenSupplyRequests returns the mint and burn requests that have been neither approved nor rejected
+func (s *SmartContract) OpenSupplyRequests(ctx contractapi.TransactionContextInterface) ([]*SupplyRequest, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(openSupplyRequestPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open supply requests: %v", err)
+	}
+	defer iterator.Close()
+
+	requests := []*SupplyRequest{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be requestID
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 1 {
+			return nil, fmt.Errorf("expected composite key with one part (requestID)")
+		}
+
+		request, err := readSupplyRequest(ctx, compositeKeyParts[0])
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// Helper Functions
+
+// isSeparationOfDutiesEnabled reads the separation of duties flag from the world state
+func isSeparationOfDutiesEnabled(ctx contractapi.TransactionContextInterface) (bool, error) {
+	separationOfDutiesBytes, err := ctx.GetStub().GetState(separationOfDutiesKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read separation of duties from world state: %v", err)
+	}
+
+	return separationOfDutiesBytes != nil, nil
+}
+
+// checkDirectSupplyChange returns an error if separation of duties disabled the supply changes of a single identity
+// Dependant functions include Mint, Burn and CreateVestingSchedule
+func checkDirectSupplyChange(ctx contractapi.TransactionContextInterface) error {
+	enabled, err := isSeparationOfDutiesEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return fmt.Errorf("separation of duties is enabled, supply changes need a request approved by a second identity")
+	}
+
+	return nil
+}
+
+// supplyRequestRole returns the role that can request, approve and reject requests of the type
+func supplyRequestRole(requestType string) string {
+	if requestType == supplyRequestMint {
+		return minterRole
+	}
+	return burnerRole
+}
+
+// checkSupplyRequestRole checks that the contract is initialized and the client holds the role for the request type, and returns the client ID
+func checkSupplyRequestRole(ctx contractapi.TransactionContextInterface, requestType string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	role := supplyRequestRole(requestType)
+	authorized, err := clientHasRole(ctx, role)
+	if err != nil {
+		return "", fmt.Errorf("failed to check %s role: %v", role, err)
+	}
+	if !authorized {
+		return "", fmt.Errorf("client is not authorized to handle %s requests", requestType)
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	return clientID, nil
+}
+
+// requestSupplyChange records an open mint or burn request
+func requestSupplyChange(ctx contractapi.TransactionContextInterface, requestType string, amount string, account string, reference string) (string, error) {
+	requester, err := checkSupplyRequestRole(ctx, requestType)
+	if err != nil {
+		return "", err
+	}
+
+	if account == "" || account == "0x0" {
+		return "", fmt.Errorf("%s account must be a client account", requestType)
+	}
+
+	value, err := parseAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	if value.Sign() <= 0 {
+		return "", fmt.Errorf("%s amount must be a positive integer", requestType)
+	}
+
+	request := &SupplyRequest{
+		ID:        ctx.GetStub().GetTxID(),
+		Type:      requestType,
+		Account:   account,
+		Amount:    value.String(),
+		Reference: reference,
+		Requester: requester,
+		Status:    supplyRequestOpen,
+	}
+
+	err = putSupplyRequest(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	err = emitSupplyRequestEvent(ctx, requestType+"Requested", request)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%s request %s of %s for account %s by %s", requestType, request.ID, request.Amount, account, requester)
+
+	return request.ID, nil
+}
+
+// approveSupplyChange mints or burns the tokens of an open request, on behalf of a checker other than the requester
+func approveSupplyChange(ctx contractapi.TransactionContextInterface, requestType string, requestID string) error {
+	checker, err := checkSupplyRequestRole(ctx, requestType)
+	if err != nil {
+		return err
+	}
+
+	request, err := readOpenSupplyRequest(ctx, requestType, requestID)
+	if err != nil {
+		return err
+	}
+
+	// Separation of duties, the maker of a request cannot also be its checker
+	if checker == request.Requester {
+		return fmt.Errorf("client requested %s %s and cannot approve it", requestType, requestID)
+	}
+
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, request.Account)
+	if err != nil {
+		return err
+	}
+
+	value, err := parseAmount(request.Amount)
+	if err != nil {
+		return err
+	}
+
+	var transferEvent event
+	if requestType == supplyRequestMint {
+		err = creditBalance(ctx, requestID, request.Account, value)
+		if err != nil {
+			return err
+		}
+
+		err = recordJournal(ctx, request.Account, "0x0", value)
+		if err != nil {
+			return err
+		}
+
+		// Update the totalSupply, the mint is rejected if it exceeds the cap
+		err = increaseTotalSupply(ctx, value)
+		if err != nil {
+			return err
+		}
+
+		transferEvent = event{"0x0", request.Account, value.String()}
+	} else {
+		// Burning from another account consumes the requester's allowance, like TransferFrom
+		if request.Account != request.Requester {
+			currentAllowance, err := readAllowance(ctx, request.Account, request.Requester)
+			if err != nil {
+				return err
+			}
+			if currentAllowance.Cmp(value) < 0 {
+				return fmt.Errorf("requester does not have enough allowance for burn")
+			}
+
+			err = putAllowance(ctx, request.Account, request.Requester, new(big.Int).Sub(currentAllowance, value))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Held tokens cannot be burned
+		err = debitBalance(ctx, request.Account, value)
+		if err != nil {
+			return err
+		}
+
+		err = recordJournal(ctx, request.Account, "0x0", new(big.Int).Neg(value))
+		if err != nil {
+			return err
+		}
+
+		err = decreaseTotalSupply(ctx, value)
+		if err != nil {
+			return err
+		}
+
+		transferEvent = event{request.Account, "0x0", value.String()}
+	}
+
+	request.Checker = checker
+	request.Status = supplyRequestApproved
+	err = putSupplyRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	// Emit the Transfer event
+	transferEventJSON, err := json.Marshal(transferEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("Transfer", transferEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("%s request %s of %s for account %s approved by %s", requestType, requestID, value, request.Account, checker)
+
+	return nil
+}
+
+// rejectSupplyChange closes an open request without changing the supply
+func rejectSupplyChange(ctx contractapi.TransactionContextInterface, requestType string, requestID string) error {
+	checker, err := checkSupplyRequestRole(ctx, requestType)
+	if err != nil {
+		return err
+	}
+
+	request, err := readOpenSupplyRequest(ctx, requestType, requestID)
+	if err != nil {
+		return err
+	}
+
+	request.Checker = checker
+	request.Status = supplyRequestRejected
+	err = putSupplyRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	err = emitSupplyRequestEvent(ctx, requestType+"Rejected", request)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s request %s rejected by %s", requestType, requestID, checker)
+
+	return nil
+}
+
+// readSupplyRequest reads the request with the given ID from the world state
+func readSupplyRequest(ctx contractapi.TransactionContextInterface, requestID string) (*SupplyRequest, error) {
+	requestKey, err := ctx.GetStub().CreateCompositeKey(supplyRequestPrefix, []string{requestID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", supplyRequestPrefix, err)
+	}
+
+	requestBytes, err := ctx.GetStub().GetState(requestKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supply request %s from world state: %v", requestID, err)
+	}
+	if requestBytes == nil {
+		return nil, fmt.Errorf("supply request %s does not exist", requestID)
+	}
+
+	request := new(SupplyRequest)
+	err = json.Unmarshal(requestBytes, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal supply request %s: %v", requestID, err)
+	}
+
+	return request, nil
+}
+
+// readOpenSupplyRequest reads a request of the type that has been neither approved nor rejected
+func readOpenSupplyRequest(ctx contractapi.TransactionContextInterface, requestType string, requestID string) (*SupplyRequest, error) {
+	request, err := readSupplyRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Type != requestType {
+		return nil, fmt.Errorf("supply request %s is a %s request", requestID, request.Type)
+	}
+	if request.Status != supplyRequestOpen {
+		return nil, fmt.Errorf("supply request %s is already %s", requestID, request.Status)
+	}
+
+	return request, nil
+}
+
+// putSupplyRequest writes the request and keeps the index of open requests up to date
+func putSupplyRequest(ctx contractapi.TransactionContextInterface, request *SupplyRequest) error {
+	requestKey, err := ctx.GetStub().CreateCompositeKey(supplyRequestPrefix, []string{request.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", supplyRequestPrefix, err)
+	}
+
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal supply request %s: %v", request.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(requestKey, requestBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", requestKey, err)
+	}
+
+	openKey, err := ctx.GetStub().CreateCompositeKey(openSupplyRequestPrefix, []string{request.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", openSupplyRequestPrefix, err)
+	}
+
+	if request.Status == supplyRequestOpen {
+		err = ctx.GetStub().PutState(openKey, []byte{0x00})
+	} else {
+		err = ctx.GetStub().DelState(openKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", openKey, err)
+	}
+
+	return nil
+}
+
+// emitSupplyRequestEvent emits a supply request event for the request
+func emitSupplyRequestEvent(ctx contractapi.TransactionContextInterface, eventName string, request *SupplyRequest) error {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, requestJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"strconv"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -15,6 +16,7 @@ const nameKey = "name"
 const symbolKey = "symbol"
 const decimalsKey = "decimals"
 const totalSupplyKey = "totalSupply"
+const capKey = "cap"
 
 // Define objectType names for prefix
 const allowancePrefix = "allowance"
@@ -27,15 +29,17 @@ type SmartContract struct {
 }
 
 // event provides an organized struct for emitting events
+// Value is a base-10 string so that amounts beyond the range of a JSON number are not truncated
 type event struct {
 	From  string `json:"from"`
 	To    string `json:"to"`
-	Value int    `json:"value"`
+	Value string `json:"value"`
 }
 
 // Mint creates new tokens and adds them to minter's account balance
 // This function triggers a Transfer event
-func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount int) error {
+// amount is a base-10 integer string in the token's smallest unit
+func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount string) error {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
@@ -46,77 +50,78 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	// Check minter authorization - the MINTER role is granted to the initializing organization and can be changed with GrantRole() and RevokeRole()
+	authorized, err := clientHasRole(ctx, minterRole)
 	if err != nil {
-		return fmt.Errorf("failed to get MSPID: %v", err)
+		return fmt.Errorf("failed to check minter role: %v", err)
 	}
-	if clientMSPID != "Org1MSP" {
+	if !authorized {
 		return fmt.Errorf("client is not authorized to mint new tokens")
 	}
 
+	err = checkDirectSupplyChange(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Get ID of submitting client identity
 	minter, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	if amount <= 0 {
-		return fmt.Errorf("mint amount must be a positive integer")
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return err
 	}
 
-	currentBalanceBytes, err := ctx.GetStub().GetState(minter)
+	err = checkNotFrozen(ctx, minter)
 	if err != nil {
-		return fmt.Errorf("failed to read minter account %s from world state: %v", minter, err)
+		return err
 	}
 
-	var currentBalance int
+	mintAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if mintAmount.Sign() <= 0 {
+		return fmt.Errorf("mint amount must be a positive integer")
+	}
 
 	// If minter current balance doesn't yet exist, we'll create it with a current balance of 0
-	if currentBalanceBytes == nil {
-		currentBalance = 0
-	} else {
-		currentBalance, _ = strconv.Atoi(string(currentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+	currentBalance, _, err := consolidateBalance(ctx, minter)
+	if err != nil {
+		return fmt.Errorf("failed to read minter account %s balance: %v", minter, err)
 	}
 
-	updatedBalance, err := add(currentBalance, amount)
+	updatedBalance, err := add(currentBalance, mintAmount)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(minter, []byte(strconv.Itoa(updatedBalance)))
+	err = snapshotBalance(ctx, minter, currentBalance)
 	if err != nil {
 		return err
 	}
 
-	// Update the totalSupply
-	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	err = ctx.GetStub().PutState(minter, []byte(updatedBalance.String()))
 	if err != nil {
-		return fmt.Errorf("failed to retrieve total token supply: %v", err)
-	}
-
-	var totalSupply int
-
-	// If no tokens have been minted, initialize the totalSupply
-	if totalSupplyBytes == nil {
-		totalSupply = 0
-	} else {
-		totalSupply, _ = strconv.Atoi(string(totalSupplyBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
+		return err
 	}
 
-	// Add the mint amount to the total supply and update the state
-	totalSupply, err = add(totalSupply, amount)
+	err = recordJournal(ctx, minter, "0x0", mintAmount)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(totalSupplyKey, []byte(strconv.Itoa(totalSupply)))
+	// Update the totalSupply, the mint is rejected if it exceeds the cap
+	err = increaseTotalSupply(ctx, mintAmount)
 	if err != nil {
 		return err
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{"0x0", minter, amount}
+	transferEvent := event{"0x0", minter, mintAmount.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -126,14 +131,15 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("minter account %s balance updated from %d to %d", minter, currentBalance, updatedBalance)
+	log.Printf("minter account %s balance updated from %s to %s", minter, currentBalance, updatedBalance)
 
 	return nil
 }
 
 // Burn redeems tokens the minter's account balance
 // This function triggers a Transfer event
-func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount int) error {
+// amount is a base-10 integer string in the token's smallest unit
+func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount string) error {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
@@ -143,13 +149,18 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 	if !initialized {
 		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to burn new tokens
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	// Check burner authorization - the BURNER role is granted to the initializing organization and can be changed with GrantRole() and RevokeRole()
+	authorized, err := clientHasRole(ctx, burnerRole)
 	if err != nil {
-		return fmt.Errorf("failed to get MSPID: %v", err)
+		return fmt.Errorf("failed to check burner role: %v", err)
 	}
-	if clientMSPID != "Org1MSP" {
-		return fmt.Errorf("client is not authorized to mint new tokens")
+	if !authorized {
+		return fmt.Errorf("client is not authorized to burn tokens")
+	}
+
+	err = checkDirectSupplyChange(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Get ID of submitting client identity
@@ -158,60 +169,71 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	if amount <= 0 {
-		return errors.New("burn amount must be a positive integer")
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return err
 	}
 
-	currentBalanceBytes, err := ctx.GetStub().GetState(minter)
+	err = checkNotFrozen(ctx, minter)
 	if err != nil {
-		return fmt.Errorf("failed to read minter account %s from world state: %v", minter, err)
+		return err
 	}
 
-	var currentBalance int
+	burnAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if burnAmount.Sign() <= 0 {
+		return errors.New("burn amount must be a positive integer")
+	}
+
+	currentBalance, exists, err := consolidateBalance(ctx, minter)
+	if err != nil {
+		return fmt.Errorf("failed to read minter account %s balance: %v", minter, err)
+	}
 
 	// Check if minter current balance exists
-	if currentBalanceBytes == nil {
+	if !exists {
 		return errors.New("The balance does not exist")
 	}
 
-	currentBalance, _ = strconv.Atoi(string(currentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
-
-	updatedBalance, err := sub(currentBalance, amount)
+	// Held tokens cannot be burned
+	onHold, err := readBalanceOnHold(ctx, minter)
 	if err != nil {
 		return err
 	}
+	if new(big.Int).Sub(currentBalance, onHold).Cmp(burnAmount) < 0 {
+		return fmt.Errorf("minter account %s has insufficient funds", minter)
+	}
 
-	err = ctx.GetStub().PutState(minter, []byte(strconv.Itoa(updatedBalance)))
+	updatedBalance, err := sub(currentBalance, burnAmount)
 	if err != nil {
 		return err
 	}
 
-	// Update the totalSupply
-	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	err = snapshotBalance(ctx, minter, currentBalance)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve total token supply: %v", err)
+		return err
 	}
 
-	// If no tokens have been minted, throw error
-	if totalSupplyBytes == nil {
-		return errors.New("totalSupply does not exist")
+	err = ctx.GetStub().PutState(minter, []byte(updatedBalance.String()))
+	if err != nil {
+		return err
 	}
 
-	totalSupply, _ := strconv.Atoi(string(totalSupplyBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
-
-	// Subtract the burn amount to the total supply and update the state
-	totalSupply, err = sub(totalSupply, amount)
+	err = recordJournal(ctx, minter, "0x0", new(big.Int).Neg(burnAmount))
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(totalSupplyKey, []byte(strconv.Itoa(totalSupply)))
+	// Update the totalSupply
+	err = decreaseTotalSupply(ctx, burnAmount)
 	if err != nil {
 		return err
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{minter, "0x0", amount}
+	transferEvent := event{minter, "0x0", burnAmount.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -221,7 +243,7 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("minter account %s balance updated from %d to %d", minter, currentBalance, updatedBalance)
+	log.Printf("minter account %s balance updated from %s to %s", minter, currentBalance, updatedBalance)
 
 	return nil
 }
@@ -229,7 +251,7 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 // Transfer transfers tokens from client account to recipient account
 // recipient account must be a valid clientID as returned by the ClientID() function
 // This function triggers a Transfer event
-func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount int) error {
+func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount string) error {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
@@ -246,13 +268,18 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, re
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	err = transferHelper(ctx, clientID, recipient, amount)
+	value, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	err = transferHelper(ctx, clientID, recipient, value)
 	if err != nil {
 		return fmt.Errorf("failed to transfer: %v", err)
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{clientID, recipient, amount}
+	transferEvent := event{clientID, recipient, value.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -266,59 +293,56 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, re
 }
 
 // BalanceOf returns the balance of the given account
-func (s *SmartContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (int, error) {
+// The balance is returned as a base-10 integer string
+func (s *SmartContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (string, error) {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
 	}
 	if !initialized {
-		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
-	balanceBytes, err := ctx.GetStub().GetState(account)
+	balance, exists, err := readBalance(ctx, account)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read from world state: %v", err)
+		return "", err
 	}
-	if balanceBytes == nil {
-		return 0, fmt.Errorf("the account %s does not exist", account)
+	if !exists {
+		return "", fmt.Errorf("the account %s does not exist", account)
 	}
 
-	balance, _ := strconv.Atoi(string(balanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
-
-	return balance, nil
+	return balance.String(), nil
 }
 
 // ClientAccountBalance returns the balance of the requesting client's account
-func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface) (int, error) {
+func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface) (string, error) {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
 	}
 	if !initialized {
-		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
 	// Get ID of submitting client identity
 	clientID, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get client id: %v", err)
+		return "", fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	balanceBytes, err := ctx.GetStub().GetState(clientID)
+	balance, exists, err := readBalance(ctx, clientID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read from world state: %v", err)
+		return "", err
 	}
-	if balanceBytes == nil {
-		return 0, fmt.Errorf("the account %s does not exist", clientID)
+	if !exists {
+		return "", fmt.Errorf("the account %s does not exist", clientID)
 	}
 
-	balance, _ := strconv.Atoi(string(balanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
-
-	return balance, nil
+	return balance.String(), nil
 }
 
 // ClientAccountID returns the id of the requesting client's account
@@ -345,41 +369,38 @@ func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterf
 }
 
 // TotalSupply returns the total token supply
-func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface) (int, error) {
+func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface) (string, error) {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
 	}
 	if !initialized {
-		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
 	// Retrieve total supply of tokens from state of smart contract
 	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
 	if err != nil {
-		return 0, fmt.Errorf("failed to retrieve total token supply: %v", err)
+		return "", fmt.Errorf("failed to retrieve total token supply: %v", err)
 	}
 
-	var totalSupply int
-
 	// If no tokens have been minted, return 0
-	if totalSupplyBytes == nil {
-		totalSupply = 0
-	} else {
-		totalSupply, _ = strconv.Atoi(string(totalSupplyBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
+	totalSupply, err := amountFromBytes(totalSupplyBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to read total token supply: %v", err)
 	}
 
-	log.Printf("TotalSupply: %d tokens", totalSupply)
+	log.Printf("TotalSupply: %s tokens", totalSupply)
 
-	return totalSupply, nil
+	return totalSupply.String(), nil
 }
 
 // Approve allows the spender to withdraw from the calling client's token account
 // The spender can withdraw multiple times if necessary, up to the value amount
 // This function triggers an Approval event
-func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spender string, value int) error {
+func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spender string, value string) error {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
@@ -396,75 +417,57 @@ func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spe
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	// Create allowanceKey
-	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{owner, spender})
+	allowance, err := parseAmount(value)
 	if err != nil {
-		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+		return err
+	}
+	if allowance.Sign() < 0 {
+		return fmt.Errorf("allowance cannot be negative")
 	}
 
-	// Update the state of the smart contract by adding the allowanceKey and value
-	err = ctx.GetStub().PutState(allowanceKey, []byte(strconv.Itoa(value)))
+	// Update the state of the smart contract with the allowance, approving 0 revokes the spender
+	err = putAllowance(ctx, owner, spender, allowance)
 	if err != nil {
-		return fmt.Errorf("failed to update state of smart contract for key %s: %v", allowanceKey, err)
+		return err
 	}
 
 	// Emit the Approval event
-	approvalEvent := event{owner, spender, value}
-	approvalEventJSON, err := json.Marshal(approvalEvent)
-	if err != nil {
-		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
-	}
-	err = ctx.GetStub().SetEvent("Approval", approvalEventJSON)
+	err = emitApproval(ctx, owner, spender, allowance)
 	if err != nil {
-		return fmt.Errorf("failed to set event: %v", err)
+		return err
 	}
 
-	log.Printf("client %s approved a withdrawal allowance of %d for spender %s", owner, value, spender)
+	log.Printf("client %s approved a withdrawal allowance of %s for spender %s", owner, allowance, spender)
 
 	return nil
 }
 
 // Allowance returns the amount still available for the spender to withdraw from the owner
-func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int, error) {
+func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (string, error) {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
 	}
 	if !initialized {
-		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
-	}
-
-	// Create allowanceKey
-	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{owner, spender})
-	if err != nil {
-		return 0, fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
 	// Read the allowance amount from the world state
-	allowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	allowance, err := readAllowance(ctx, owner, spender)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read allowance for %s from world state: %v", allowanceKey, err)
+		return "", err
 	}
 
-	var allowance int
+	log.Printf("The allowance left for spender %s to withdraw from owner %s: %s", spender, owner, allowance)
 
-	// If no current allowance, set allowance to 0
-	if allowanceBytes == nil {
-		allowance = 0
-	} else {
-		allowance, err = strconv.Atoi(string(allowanceBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
-	}
-
-	log.Printf("The allowance left for spender %s to withdraw from owner %s: %d", spender, owner, allowance)
-
-	return allowance, nil
+	return allowance.String(), nil
 }
 
 // TransferFrom transfers the value amount from the "from" address to the "to" address
 // This function triggers a Transfer event
-func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, value int) error {
+func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, value string) error {
 
 	// Check if contract has been intilized first
 	initialized, err := checkInitialized(ctx)
@@ -481,45 +484,47 @@ func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	// Create allowanceKey
-	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{from, spender})
+	// A frozen spender cannot use its allowances, the pause and the from and to accounts are checked by transferHelper
+	err = checkNotFrozen(ctx, spender)
 	if err != nil {
-		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+		return err
 	}
 
 	// Retrieve the allowance of the spender
-	currentAllowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	currentAllowance, err := readAllowance(ctx, from, spender)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve the allowance for %s from world state: %v", allowanceKey, err)
+		return err
 	}
 
-	var currentAllowance int
-	currentAllowance, _ = strconv.Atoi(string(currentAllowanceBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
+	transferValue, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
 
 	// Check if transferred value is less than allowance
-	if currentAllowance < value {
+	if currentAllowance.Cmp(transferValue) < 0 {
 		return fmt.Errorf("spender does not have enough allowance for transfer")
 	}
 
 	// Initiate the transfer
-	err = transferHelper(ctx, from, to, value)
+	err = transferHelper(ctx, from, to, transferValue)
 	if err != nil {
 		return fmt.Errorf("failed to transfer: %v", err)
 	}
 
 	// Decrease the allowance
-	updatedAllowance, err := sub(currentAllowance, value)
+	updatedAllowance, err := sub(currentAllowance, transferValue)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(allowanceKey, []byte(strconv.Itoa(updatedAllowance)))
+	err = putAllowance(ctx, from, spender, updatedAllowance)
 	if err != nil {
 		return err
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{from, to, value}
+	transferEvent := event{from, to, transferValue.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -529,7 +534,7 @@ func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("spender %s allowance updated from %d to %d", spender, currentAllowance, updatedAllowance)
+	log.Printf("spender %s allowance updated from %s to %s", spender, currentAllowance, updatedAllowance)
 
 	return nil
 }
@@ -578,11 +583,67 @@ func (s *SmartContract) Symbol(ctx contractapi.TransactionContextInterface) (str
 	return string(bytes), nil
 }
 
+// Decimals returns the number of decimals the token uses
+// e.g. 8 means to divide the token amount by 100000000 to get its user representation
+// returns {Number} Returns the decimals of the token
+
+func (s *SmartContract) Decimals(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	bytes, err := ctx.GetStub().GetState(decimalsKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Decimals: %v", err)
+	}
+
+	decimals, err := strconv.Atoi(string(bytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse decimals %s: %v", string(bytes), err)
+	}
+
+	return decimals, nil
+}
+
+// Cap returns the maximum total supply of the token, 0 if the supply is not capped
+func (s *SmartContract) Cap(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	capBytes, err := ctx.GetStub().GetState(capKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Cap: %v", err)
+	}
+
+	supplyCap, err := amountFromBytes(capBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cap: %v", err)
+	}
+
+	return supplyCap.String(), nil
+}
+
 // Set information for a token and intialize contract.
+// The calling client is granted the ADMIN and PAUSER roles, and its MSP the MINTER and BURNER roles
+// This function triggers a RolesGranted event
 // param {String} name The name of the token
 // param {String} symbol The symbol of the token
 // param {String} decimals The decimals used for the token operations
-func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string, decimals string) (bool, error) {
+// param {String} cap The maximum total supply as a base-10 integer string, 0 for an uncapped supply
+func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string, decimals string, cap string) (bool, error) {
 
 	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to intitialize contract
 	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
@@ -602,6 +663,14 @@ func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface,
 		return false, fmt.Errorf("contract options are already set, client is not authorized to change them")
 	}
 
+	supplyCap, err := parseAmount(cap)
+	if err != nil {
+		return false, err
+	}
+	if supplyCap.Sign() < 0 {
+		return false, fmt.Errorf("cap cannot be negative")
+	}
+
 	err = ctx.GetStub().PutState(nameKey, []byte(name))
 	if err != nil {
 		return false, fmt.Errorf("failed to set token name: %v", err)
@@ -617,49 +686,103 @@ func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface,
 		return false, fmt.Errorf("failed to set token name: %v", err)
 	}
 
+	if supplyCap.Sign() > 0 {
+		err = ctx.GetStub().PutState(capKey, []byte(supplyCap.String()))
+		if err != nil {
+			return false, fmt.Errorf("failed to set cap: %v", err)
+		}
+	}
+
+	// The initializing client becomes the first admin and pauser, and its organization keeps the central banker privileges to mint and burn
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = seedRoles(ctx, clientID, clientMSPID)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
 // Helper Functions
 
 // transferHelper is a helper function that transfers tokens from the "from" address to the "to" address
+// Transfers are rejected while the contract is paused or when either account is frozen
 // Dependant functions include Transfer and TransferFrom
-func transferHelper(ctx contractapi.TransactionContextInterface, from string, to string, value int) error {
+func transferHelper(ctx contractapi.TransactionContextInterface, from string, to string, value *big.Int) error {
+
+	err := checkNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	err = checkNotFrozen(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	return moveBalance(ctx, from, to, value)
+}
+
+// moveBalance debits the "from" account and credits the "to" account without any pause or freeze checks
+// Dependant functions include transferHelper and ForceTransfer
+func moveBalance(ctx contractapi.TransactionContextInterface, from string, to string, value *big.Int) error {
 
 	if from == to {
 		return fmt.Errorf("cannot transfer to and from same client account")
 	}
 
-	if value < 0 { // transfer of 0 is allowed in ERC-20, so just validate against negative amounts
+	if value.Sign() < 0 { // transfer of 0 is allowed in ERC-20, so just validate against negative amounts
 		return fmt.Errorf("transfer amount cannot be negative")
 	}
 
-	fromCurrentBalanceBytes, err := ctx.GetStub().GetState(from)
+	err := debitBalance(ctx, from, value)
 	if err != nil {
-		return fmt.Errorf("failed to read client account %s from world state: %v", from, err)
+		return err
 	}
 
-	if fromCurrentBalanceBytes == nil {
-		return fmt.Errorf("client account %s has no balance", from)
+	err = creditBalance(ctx, from, to, value)
+	if err != nil {
+		return err
 	}
 
-	fromCurrentBalance, _ := strconv.Atoi(string(fromCurrentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+	return journalTransfer(ctx, from, to, value)
+}
 
-	if fromCurrentBalance < value {
-		return fmt.Errorf("client account %s has insufficient funds", from)
+// debitBalance subtracts the value from the balance of the "from" account, which must hold enough funds besides its held tokens
+// Dependant functions include moveBalance and batchTransferHelper
+func debitBalance(ctx contractapi.TransactionContextInterface, from string, value *big.Int) error {
+	onHold, err := readBalanceOnHold(ctx, from)
+	if err != nil {
+		return err
 	}
 
-	toCurrentBalanceBytes, err := ctx.GetStub().GetState(to)
+	return debitAccount(ctx, from, value, onHold)
+}
+
+// debitAccount subtracts the value from the balance of the "from" account, leaving at least the reserved amount in it
+// Dependant functions include debitBalance and ExecuteHold
+func debitAccount(ctx contractapi.TransactionContextInterface, from string, value *big.Int, reserved *big.Int) error {
+
+	fromCurrentBalance, fromExists, err := consolidateBalance(ctx, from)
 	if err != nil {
-		return fmt.Errorf("failed to read recipient account %s from world state: %v", to, err)
+		return fmt.Errorf("failed to read client account %s balance: %v", from, err)
 	}
 
-	var toCurrentBalance int
-	// If recipient current balance doesn't yet exist, we'll create it with a current balance of 0
-	if toCurrentBalanceBytes == nil {
-		toCurrentBalance = 0
-	} else {
-		toCurrentBalance, _ = strconv.Atoi(string(toCurrentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+	if !fromExists {
+		return fmt.Errorf("client account %s has no balance", from)
+	}
+
+	if new(big.Int).Sub(fromCurrentBalance, reserved).Cmp(value) < 0 {
+		return fmt.Errorf("client account %s has insufficient funds", from)
 	}
 
 	fromUpdatedBalance, err := sub(fromCurrentBalance, value)
@@ -667,39 +790,159 @@ func transferHelper(ctx contractapi.TransactionContextInterface, from string, to
 		return err
 	}
 
+	err = snapshotBalance(ctx, from, fromCurrentBalance)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(from, []byte(fromUpdatedBalance.String()))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s balance updated from %s to %s", from, fromCurrentBalance, fromUpdatedBalance)
+
+	return nil
+}
+
+// creditBalance adds the value to the balance of the "to" account
+// The "from" account is the debited account, or the vesting schedule or supply request that creates the tokens
+// Dependant functions include moveBalance, batchTransferHelper and Release
+func creditBalance(ctx contractapi.TransactionContextInterface, from string, to string, value *big.Int) error {
+
+	// Credit a hot account with a delta row, so that its balance key is not read
+	hot, err := isHotAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+	if hot {
+		err = creditHotAccount(ctx, from, to, value)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("hot account %s credited with %s", to, value)
+
+		return nil
+	}
+
+	// If recipient current balance doesn't yet exist, we'll create it with a current balance of 0
+	toCurrentBalance, _, err := consolidateBalance(ctx, to)
+	if err != nil {
+		return fmt.Errorf("failed to read recipient account %s balance: %v", to, err)
+	}
+
 	toUpdatedBalance, err := add(toCurrentBalance, value)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(from, []byte(strconv.Itoa(fromUpdatedBalance)))
+	err = snapshotBalance(ctx, to, toCurrentBalance)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(to, []byte(toUpdatedBalance.String()))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("recipient %s balance updated from %s to %s", to, toCurrentBalance, toUpdatedBalance)
+
+	return nil
+}
+
+// increaseTotalSupply adds the amount to the total supply, rejecting an increase beyond the cap
+// Dependant functions include Mint and CreateVestingSchedule
+func increaseTotalSupply(ctx contractapi.TransactionContextInterface, amount *big.Int) error {
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+
+	// If no tokens have been minted, initialize the totalSupply
+	totalSupply, err := amountFromBytes(totalSupplyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read total token supply: %v", err)
+	}
+
+	updatedTotalSupply, err := add(totalSupply, amount)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(to, []byte(strconv.Itoa(toUpdatedBalance)))
+	capBytes, err := ctx.GetStub().GetState(capKey)
+	if err != nil {
+		return fmt.Errorf("failed to get Cap: %v", err)
+	}
+	if capBytes != nil {
+		supplyCap, err := amountFromBytes(capBytes)
+		if err != nil {
+			return fmt.Errorf("failed to read cap: %v", err)
+		}
+		if updatedTotalSupply.Cmp(supplyCap) > 0 {
+			return fmt.Errorf("total supply of %s would exceed the cap of %s", updatedTotalSupply, supplyCap)
+		}
+	}
+
+	err = snapshotTotalSupply(ctx, totalSupply)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("client %s balance updated from %d to %d", from, fromCurrentBalance, fromUpdatedBalance)
-	log.Printf("recipient %s balance updated from %d to %d", to, toCurrentBalance, toUpdatedBalance)
+	err = ctx.GetStub().PutState(totalSupplyKey, []byte(updatedTotalSupply.String()))
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// add two number checking for overflow
-func add(b int, q int) (int, error) {
+// decreaseTotalSupply subtracts the amount from the total supply
+// Dependant functions include Burn and Revoke
+func decreaseTotalSupply(ctx contractapi.TransactionContextInterface, amount *big.Int) error {
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+
+	// If no tokens have been minted, throw error
+	if totalSupplyBytes == nil {
+		return errors.New("totalSupply does not exist")
+	}
+
+	totalSupply, err := amountFromBytes(totalSupplyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read total token supply: %v", err)
+	}
+
+	updatedTotalSupply, err := sub(totalSupply, amount)
+	if err != nil {
+		return err
+	}
+
+	err = snapshotTotalSupply(ctx, totalSupply)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(totalSupplyKey, []byte(updatedTotalSupply.String()))
+	if err != nil {
+		return err
+	}
 
-	// Check overflow
-	var sum int
-	sum = q + b
+	return nil
+}
+
+// add two numbers, token amounts are unbounded so only negative operands are rejected
+func add(b *big.Int, q *big.Int) (*big.Int, error) {
 
-	if (sum < q || sum < b) == (b >= 0 && q >= 0) {
-		return 0, fmt.Errorf("Math: addition overflow occurred %d + %d", b, q)
+	// Check operands
+	if b.Sign() < 0 || q.Sign() < 0 {
+		return nil, fmt.Errorf("Math: addition of negative number %s + %s", b, q)
 	}
 
-	return sum, nil
+	return new(big.Int).Add(b, q), nil
 }
 
 // Checks that contract options have been already initialized
@@ -716,18 +959,41 @@ func checkInitialized(ctx contractapi.TransactionContextInterface) (bool, error)
 	return true, nil
 }
 
-// sub two number checking for overflow
-func sub(b int, q int) (int, error) {
+// sub two number checking for underflow
+func sub(b *big.Int, q *big.Int) (*big.Int, error) {
 
-	// sub two number checking 
-	if q <= 0 {
-		return 0, fmt.Errorf("Error: the subtraction number is %d, it should be greater than 0", q)
+	// sub two number checking
+	if q.Sign() <= 0 {
+		return nil, fmt.Errorf("Error: the subtraction number is %s, it should be greater than 0", q)
 	}
-	if b < q {
-		return 0, fmt.Errorf("Error: the number %d is not enough to be subtracted by %d", b, q)
+	if b.Cmp(q) < 0 {
+		return nil, fmt.Errorf("Error: the number %s is not enough to be subtracted by %s", b, q)
+	}
+
+	return new(big.Int).Sub(b, q), nil
+}
+
+// parseAmount parses a token amount passed as a base-10 integer string
+func parseAmount(amount string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q, it should be a base-10 integer", amount)
+	}
+
+	return value, nil
+}
+
+// amountFromBytes parses a balance, allowance or total supply read from the world state, nil is read as 0
+// Amounts are stored as base-10 strings, which is also how earlier versions of this contract wrote them with strconv.Itoa()
+func amountFromBytes(amountBytes []byte) (*big.Int, error) {
+	if amountBytes == nil {
+		return big.NewInt(0), nil
+	}
+
+	amount, ok := new(big.Int).SetString(string(amountBytes), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q stored in world state", string(amountBytes))
 	}
-	var diff int
-	diff = b - q
 
-	return diff, nil
+	return amount, nil
 }
@@ -0,0 +1,491 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const minter = "x509::CN=minter,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
+const recipient = "x509::CN=recipient,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org2.example.com,O=org2.example.com,L=Hursley,ST=Hampshire,C=UK"
+const notary = "x509::CN=notary,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
+
+var minter64 = base64.StdEncoding.EncodeToString([]byte(minter))
+var recipient64 = base64.StdEncoding.EncodeToString([]byte(recipient))
+var notary64 = base64.StdEncoding.EncodeToString([]byte(notary))
+
+type MockStub struct {
+	shim.ChaincodeStubInterface
+	mock.Mock
+}
+
+func (ms *MockStub) GetState(key string) ([]byte, error) {
+	args := ms.Called(key)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (ms *MockStub) PutState(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) DelState(key string) error {
+	args := ms.Called(key)
+	return args.Error(0)
+}
+
+func (ms *MockStub) SetEvent(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	args := ms.Called(objectType, keys)
+	return &MockIterator{records: args.Get(0).([]*queryresult.KV)}, args.Error(1)
+}
+
+func (ms *MockStub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	args := ms.Called(startKey, endKey)
+	return &MockIterator{records: args.Get(0).([]*queryresult.KV)}, args.Error(1)
+}
+
+func (ms *MockStub) GetChannelID() string {
+	args := ms.Called()
+	return args.String(0)
+}
+
+func (ms *MockStub) GetTxID() string {
+	args := ms.Called()
+	return args.String(0)
+}
+
+func (ms *MockStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	args := ms.Called()
+	return args.Get(0).(*timestamppb.Timestamp), args.Error(1)
+}
+
+// CreateCompositeKey and SplitCompositeKey use the key format of the peer, so that the tests can build the keys the contract reads
+func (ms *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (ms *MockStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	components := strings.Split(strings.TrimSuffix(strings.TrimPrefix(compositeKey, "\x00"), "\x00"), "\x00")
+	return components[0], components[1:], nil
+}
+
+type MockClientIdentity struct {
+	cid.ClientIdentity
+	mock.Mock
+}
+
+func (mci *MockClientIdentity) GetID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetMSPID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+type MockContext struct {
+	contractapi.TransactionContextInterface
+	mock.Mock
+}
+
+func (mc *MockContext) GetStub() shim.ChaincodeStubInterface {
+	args := mc.Called()
+	return args.Get(0).(*MockStub)
+}
+
+func (mc *MockContext) GetClientIdentity() cid.ClientIdentity {
+	args := mc.Called()
+	return args.Get(0).(*MockClientIdentity)
+}
+
+type MockIterator struct {
+	shim.StateQueryIteratorInterface
+	records []*queryresult.KV
+}
+
+func (it *MockIterator) HasNext() bool {
+	return len(it.records) > 0
+}
+
+func (it *MockIterator) Next() (*queryresult.KV, error) {
+	record := it.records[0]
+	it.records = it.records[1:]
+	return record, nil
+}
+
+func (it *MockIterator) Close() error {
+	return nil
+}
+
+// setupStub returns a context for the client with the given ID of an initialized contract
+// The state read by a test is mocked by the test with mockState
+func setupStub(clientID string) (*MockContext, *MockStub) {
+	anyString := mock.AnythingOfType("string")
+	anyUint8Slice := mock.AnythingOfType("[]uint8")
+
+	ms := new(MockStub)
+
+	ms.On("GetState", nameKey).Return([]byte("Token"), nil)
+	ms.On("GetState", symbolKey).Return([]byte("TOK"), nil)
+	ms.On("GetState", pausedKey).Return([]byte(nil), nil)
+	ms.On("GetState", currentSnapshotIDKey).Return([]byte(nil), nil)
+	ms.On("GetState", capKey).Return([]byte(nil), nil)
+
+	ms.On("PutState", anyString, anyUint8Slice).Return(nil)
+	ms.On("DelState", anyString).Return(nil)
+	ms.On("SetEvent", anyString, anyUint8Slice).Return(nil)
+
+	ms.On("GetChannelID").Return("mychannel")
+	ms.On("GetTxID").Return("tx1")
+	ms.On("GetTxTimestamp").Return(&timestamppb.Timestamp{Seconds: 1700000000}, nil)
+
+	mci := new(MockClientIdentity)
+	mci.On("GetID").Return(clientID, nil)
+	mci.On("GetMSPID").Return("Org1MSP", nil)
+
+	mc := new(MockContext)
+	mc.On("GetStub").Return(ms)
+	mc.On("GetClientIdentity").Return(mci)
+	return mc, ms
+}
+
+// mockState mocks the value of a key, an empty value mocks a key that is not in the world state
+func mockState(ms *MockStub, key string, value string) {
+	if value == "" {
+		ms.On("GetState", key).Return([]byte(nil), nil)
+		return
+	}
+	ms.On("GetState", key).Return([]byte(value), nil)
+}
+
+// mockAccount mocks the balance of a client account that is neither frozen, hot nor holding tokens
+// The first mock of a key wins, so a test mocks the held balance of an account before calling mockAccount
+func mockAccount(ms *MockStub, account string, balance string) {
+	mockState(ms, account, balance)
+	mockState(ms, compositeKey(frozenPrefix, account), "")
+	mockState(ms, compositeKey(hotAccountPrefix, account), "")
+	mockState(ms, compositeKey(balanceOnHoldPrefix, account), "")
+}
+
+func compositeKey(objectType string, attributes ...string) string {
+	key, _ := shim.CreateCompositeKey(objectType, attributes)
+	return key
+}
+
+// newCertificate creates a certificate for the common name, self-signed when parent is nil
+func newCertificate(commonName string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"org1.example.com"}},
+		NotBefore:             time.Unix(1600000000, 0),
+		NotAfter:              time.Unix(1800000000, 0),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	certDER, _ := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	cert, _ := x509.ParseCertificate(certDER)
+	return cert, key
+}
+
+func encodeCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func signPermit(key *ecdsa.PrivateKey, message permitMessage) string {
+	messageJSON, _ := json.Marshal(message)
+	digest := sha256.Sum256(messageJSON)
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestPermit(t *testing.T) {
+	ctx, ms := setupStub(recipient64)
+	c := new(SmartContract)
+
+	caCert, caKey := newCertificate("ca.org1.example.com", true, nil, nil)
+	ownerCert, ownerKey := newCertificate("owner", false, caCert, caKey)
+	owner := base64.StdEncoding.EncodeToString([]byte("x509::" + distinguishedName(&ownerCert.Subject) + "::" + distinguishedName(&ownerCert.Issuer)))
+
+	ms.On("GetStateByPartialCompositeKey", mspRootPrefix, []string{}).Return([]*queryresult.KV{{Key: compositeKey(mspRootPrefix, "Org1MSP"), Value: []byte(encodeCertificate(caCert))}}, nil)
+	mockState(ms, compositeKey(noncePrefix, owner), "")
+	mockState(ms, compositeKey(noncePrefix, minter64), "")
+	mockState(ms, compositeKey(allowancePrefix, owner, recipient64), "")
+
+	message := permitMessage{Channel: "mychannel", Symbol: "TOK", Owner: owner, Spender: recipient64, Value: "100", Nonce: 0, Deadline: 1700000100}
+
+	err := c.Permit(ctx, owner, recipient64, "100", 1700000100, 0, signPermit(ownerKey, message), encodeCertificate(ownerCert))
+	assert.NoError(t, err)
+	ms.AssertCalled(t, "PutState", compositeKey(noncePrefix, owner), []byte("1"))
+	ms.AssertCalled(t, "PutState", compositeKey(allowancePrefix, owner, recipient64), []byte("100"))
+
+	// The signature covers the value, a relayer cannot raise the allowance
+	err = c.Permit(ctx, owner, recipient64, "1000", 1700000100, 0, signPermit(ownerKey, message), encodeCertificate(ownerCert))
+	assert.EqualError(t, err, "invalid signature for owner "+owner)
+
+	err = c.Permit(ctx, owner, recipient64, "100", 1700000100, 1, signPermit(ownerKey, message), encodeCertificate(ownerCert))
+	assert.EqualError(t, err, "invalid nonce 1, expected 0")
+
+	err = c.Permit(ctx, owner, recipient64, "100", 1699999999, 0, signPermit(ownerKey, message), encodeCertificate(ownerCert))
+	assert.EqualError(t, err, "permit expired at 1699999999")
+
+	// A certificate issued by a CA that was not recorded with SetMSPRootCertificates does not chain
+	otherCACert, otherCAKey := newCertificate("ca.org1.example.com", true, nil, nil)
+	otherCert, otherKey := newCertificate("owner", false, otherCACert, otherCAKey)
+
+	err = c.Permit(ctx, owner, recipient64, "100", 1700000100, 0, signPermit(otherKey, message), encodeCertificate(otherCert))
+	assert.EqualError(t, err, "certificate of "+owner+" does not chain to the CA certificates of any recorded organization")
+
+	// The certificate must belong to the owner
+	err = c.Permit(ctx, minter64, recipient64, "100", 1700000100, 0, signPermit(ownerKey, message), encodeCertificate(ownerCert))
+	assert.EqualError(t, err, "certificate does not belong to owner "+minter64)
+}
+
+func TestHold(t *testing.T) {
+	ctx, ms := setupStub(minter64)
+	c := new(SmartContract)
+
+	mockState(ms, compositeKey(balanceOnHoldPrefix, minter64), "60")
+	mockAccount(ms, minter64, "100")
+	mockAccount(ms, recipient64, "")
+	mockState(ms, compositeKey(holdPrefix, "op2"), "")
+
+	// Held tokens cannot be held again
+	err := c.Hold(ctx, "op2", recipient64, notary64, "50", 0)
+	assert.EqualError(t, err, "client account "+minter64+" has insufficient funds")
+
+	err = c.Hold(ctx, "op2", recipient64, notary64, "40", 0)
+	assert.NoError(t, err)
+	ms.AssertCalled(t, "PutState", compositeKey(balanceOnHoldPrefix, minter64), []byte("100"))
+
+	// Held tokens stay in the balance but cannot be transferred
+	err = c.Transfer(ctx, recipient64, "50")
+	assert.EqualError(t, err, "failed to transfer: client account "+minter64+" has insufficient funds")
+
+	err = c.Transfer(ctx, recipient64, "40")
+	assert.NoError(t, err)
+	ms.AssertCalled(t, "PutState", minter64, []byte("60"))
+	ms.AssertCalled(t, "PutState", recipient64, []byte("40"))
+
+	balance, _ := c.BalanceOf(ctx, minter64)
+	assert.Equal(t, "100", balance)
+
+	spendable, _ := c.SpendableBalanceOf(ctx, minter64)
+	assert.Equal(t, "40", spendable)
+}
+
+func TestExecuteHold(t *testing.T) {
+	holdStr := "{\"operationId\":\"op1\",\"from\":\"" + minter64 + "\",\"to\":\"" + recipient64 + "\",\"notary\":\"" + notary64 + "\",\"value\":\"60\",\"expiration\":1700000100,\"status\":\"Ordered\"}"
+	expiredHoldStr := "{\"operationId\":\"op2\",\"from\":\"" + minter64 + "\",\"to\":\"" + recipient64 + "\",\"notary\":\"" + notary64 + "\",\"value\":\"10\",\"expiration\":1699999999,\"status\":\"Ordered\"}"
+
+	ctx, ms := setupStub(notary64)
+	c := new(SmartContract)
+
+	mockState(ms, compositeKey(holdPrefix, "op1"), holdStr)
+	mockState(ms, compositeKey(holdPrefix, "op2"), expiredHoldStr)
+	mockState(ms, compositeKey(balanceOnHoldPrefix, minter64), "60")
+	mockAccount(ms, minter64, "100")
+	mockAccount(ms, recipient64, "")
+
+	// The held tokens are spent, so the payer's spendable balance is not reduced twice
+	err := c.ExecuteHold(ctx, "op1")
+	assert.NoError(t, err)
+	ms.AssertCalled(t, "PutState", minter64, []byte("40"))
+	ms.AssertCalled(t, "PutState", recipient64, []byte("60"))
+	ms.AssertCalled(t, "DelState", compositeKey(balanceOnHoldPrefix, minter64))
+
+	err = c.ExecuteHold(ctx, "op2")
+	assert.EqualError(t, err, "hold op2 expired at 1699999999")
+
+	// Only the notary can execute a hold
+	ctx, ms = setupStub(minter64)
+	mockState(ms, compositeKey(holdPrefix, "op1"), holdStr)
+
+	err = c.ExecuteHold(ctx, "op1")
+	assert.EqualError(t, err, "client is not authorized to execute hold op1")
+}
+
+func TestMultisigThreshold(t *testing.T) {
+	account := multisigAccountPrefix + "tx0"
+	accountStr := "{\"id\":\"" + account + "\",\"signers\":[\"" + minter64 + "\",\"" + notary64 + "\"],\"threshold\":2}"
+	proposal := &TransferProposal{ID: "p1", Account: account, To: recipient64, Value: "30", Proposer: minter64, Approvals: []string{minter64}, Expiration: 1700000100, Status: proposalPending}
+	proposalBytes, _ := json.Marshal(proposal)
+
+	ctx, ms := setupStub(minter64)
+	c := new(SmartContract)
+
+	mockState(ms, compositeKey(multisigPrefix, account), accountStr)
+	mockState(ms, compositeKey(multisigProposalPrefix, account, "p1"), string(proposalBytes))
+
+	_, err := c.CreateMultisigAccount(ctx, []string{minter64, notary64}, 3)
+	assert.EqualError(t, err, "threshold 3 must be between 1 and the number of signers 2")
+
+	err = c.ExecuteProposal(ctx, account, "p1")
+	assert.EqualError(t, err, "proposal p1 has 1 approvals, 2 are required")
+
+	err = c.ApproveProposal(ctx, account, "p1")
+	assert.EqualError(t, err, "signer "+minter64+" already approved proposal p1")
+
+	// Only signers can approve
+	ctx, ms = setupStub(recipient64)
+	mockState(ms, compositeKey(multisigPrefix, account), accountStr)
+
+	err = c.ApproveProposal(ctx, account, "p1")
+	assert.EqualError(t, err, "client is not a signer of multisig account "+account)
+
+	// The second signer meets the threshold
+	ctx, ms = setupStub(notary64)
+	mockState(ms, compositeKey(multisigPrefix, account), accountStr)
+	mockState(ms, compositeKey(multisigProposalPrefix, account, "p1"), string(proposalBytes))
+
+	err = c.ApproveProposal(ctx, account, "p1")
+	assert.NoError(t, err)
+
+	proposal.Approvals = []string{minter64, notary64}
+	approvedBytes, _ := json.Marshal(proposal)
+	ms.AssertCalled(t, "PutState", compositeKey(multisigProposalPrefix, account, "p1"), approvedBytes)
+
+	ctx, ms = setupStub(notary64)
+	mockState(ms, compositeKey(multisigPrefix, account), accountStr)
+	mockState(ms, compositeKey(multisigProposalPrefix, account, "p1"), string(approvedBytes))
+	mockAccount(ms, account, "100")
+	mockAccount(ms, recipient64, "")
+
+	err = c.ExecuteProposal(ctx, account, "p1")
+	assert.NoError(t, err)
+	ms.AssertCalled(t, "PutState", account, []byte("70"))
+	ms.AssertCalled(t, "PutState", recipient64, []byte("30"))
+
+	proposal.Status = proposalExecuted
+	executedBytes, _ := json.Marshal(proposal)
+	ms.AssertCalled(t, "PutState", compositeKey(multisigProposalPrefix, account, "p1"), executedBytes)
+}
+
+func TestMultisigExpiration(t *testing.T) {
+	account := multisigAccountPrefix + "tx0"
+	accountStr := "{\"id\":\"" + account + "\",\"signers\":[\"" + minter64 + "\",\"" + notary64 + "\"],\"threshold\":2}"
+	proposal := &TransferProposal{ID: "p2", Account: account, To: recipient64, Value: "30", Proposer: minter64, Approvals: []string{minter64, notary64}, Expiration: 1699999999, Status: proposalPending}
+	proposalBytes, _ := json.Marshal(proposal)
+
+	ctx, ms := setupStub(notary64)
+	c := new(SmartContract)
+
+	mockState(ms, compositeKey(multisigPrefix, account), accountStr)
+	mockState(ms, compositeKey(multisigProposalPrefix, account, "p2"), string(proposalBytes))
+
+	_, err := c.ProposeTransfer(ctx, account, recipient64, "30", 1700000000)
+	assert.EqualError(t, err, "proposal expiration 1700000000 is not in the future")
+
+	// An expired proposal cannot be executed even though it met the threshold
+	err = c.ExecuteProposal(ctx, account, "p2")
+	assert.EqualError(t, err, "proposal p2 expired at 1699999999")
+
+	err = c.RevokeApproval(ctx, account, "p2")
+	assert.EqualError(t, err, "proposal p2 expired at 1699999999")
+}
+
+func TestApproveMint(t *testing.T) {
+	requestStr := "{\"id\":\"r1\",\"type\":\"Mint\",\"account\":\"" + recipient64 + "\",\"amount\":\"50\",\"reference\":\"order 1\",\"requester\":\"" + minter64 + "\",\"checker\":\"\",\"status\":\"Open\"}"
+
+	ctx, ms := setupStub(minter64)
+	c := new(SmartContract)
+
+	mockState(ms, separationOfDutiesKey, "\x00")
+	mockState(ms, compositeKey(rolePrefix, minterRole, minter64), "\x00")
+	mockState(ms, compositeKey(supplyRequestPrefix, "r1"), requestStr)
+
+	// With separation of duties the minter can only request a mint
+	err := c.Mint(ctx, "50")
+	assert.EqualError(t, err, "separation of duties is enabled, supply changes need a request approved by a second identity")
+
+	// The maker of a request cannot also be its checker
+	err = c.ApproveMint(ctx, "r1")
+	assert.EqualError(t, err, "client requested Mint r1 and cannot approve it")
+
+	ctx, ms = setupStub(recipient64)
+	mockState(ms, compositeKey(rolePrefix, minterRole, recipient64), "")
+	mockState(ms, compositeKey(rolePrefix, minterRole, "Org1MSP"), "")
+
+	err = c.ApproveMint(ctx, "r1")
+	assert.EqualError(t, err, "client is not authorized to handle Mint requests")
+
+	ctx, ms = setupStub(notary64)
+	mockState(ms, compositeKey(rolePrefix, minterRole, notary64), "\x00")
+	mockState(ms, compositeKey(supplyRequestPrefix, "r1"), requestStr)
+	mockState(ms, totalSupplyKey, "100")
+	mockAccount(ms, recipient64, "")
+
+	err = c.ApproveMint(ctx, "r1")
+	assert.NoError(t, err)
+	ms.AssertCalled(t, "PutState", recipient64, []byte("50"))
+	ms.AssertCalled(t, "PutState", totalSupplyKey, []byte("150"))
+	ms.AssertCalled(t, "SetEvent", "Transfer", []byte("{\"from\":\"0x0\",\"to\":\""+recipient64+"\",\"value\":\"50\"}"))
+}
+
+func TestAuditSupply(t *testing.T) {
+	ctx, ms := setupStub(recipient64)
+	c := new(SmartContract)
+
+	mockState(ms, totalSupplyKey, "120")
+
+	// Contract options share the simple key namespace with the balances and are not counted
+	ms.On("GetStateByRange", "", "").Return([]*queryresult.KV{
+		{Key: minter64, Value: []byte("60")},
+		{Key: multisigAccountPrefix + "tx0", Value: []byte("5")},
+		{Key: nameKey, Value: []byte("Token")},
+		{Key: recipient64, Value: []byte("30")},
+		{Key: totalSupplyKey, Value: []byte("120")},
+	}, nil)
+	ms.On("GetStateByPartialCompositeKey", balanceDeltaPrefix, []string{}).Return([]*queryresult.KV{
+		{Key: compositeKey(balanceDeltaPrefix, recipient64, minter64, "5", "tx2"), Value: []byte("1700000000")},
+		{Key: compositeKey(balanceDeltaPrefix, recipient64, "7", "tx3"), Value: []byte("1700000000")},
+	}, nil)
+	ms.On("GetStateByPartialCompositeKey", vestingSchedulePrefix, []string{}).Return([]*queryresult.KV{
+		{Key: compositeKey(vestingSchedulePrefix, "v1"), Value: []byte("{\"id\":\"v1\",\"beneficiary\":\"" + recipient64 + "\",\"amount\":\"20\",\"released\":\"10\"}")},
+	}, nil)
+
+	audit, err := c.AuditSupply(ctx)
+	assert.NoError(t, err)
+
+	expected := &SupplyAudit{
+		TotalSupply: "120",
+		Balances:    "100",
+		Vesting:     "10",
+		Consistent:  false,
+		Anomalies: []string{
+			fmt.Sprintf("balance delta %q does not have four parts (account:from:value:txID)", compositeKey(balanceDeltaPrefix, recipient64, "7", "tx3")),
+			"balances 100 and vesting 10 add up to 110, total supply is 120",
+		},
+	}
+	assert.Equal(t, expected, audit)
+}
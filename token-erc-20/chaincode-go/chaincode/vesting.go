@@ -0,0 +1,395 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const vestingSchedulePrefix = "vestingSchedule"
+
+// VestingSchedule locks minted tokens for a beneficiary and releases them linearly over time
+// Nothing vests before Start + Cliff, everything has vested at Start + Duration
+// Amount and Released are base-10 integer strings, times are unix seconds
+type VestingSchedule struct {
+	ID          string `json:"id"`
+	Beneficiary string `json:"beneficiary"`
+	Amount      string `json:"amount"`
+	Released    string `json:"released"`
+	Start       int64  `json:"start"`
+	Cliff       int64  `json:"cliff"`
+	Duration    int64  `json:"duration"`
+	Revocable   bool   `json:"revocable"`
+	Revoked     bool   `json:"revoked"`
+}
+
+// vestingEvent provides an organized struct for emitting VestingScheduleCreated, TokensReleased and VestingScheduleRevoked events
+type vestingEvent struct {
+	ID          string `json:"id"`
+	Beneficiary string `json:"beneficiary"`
+	Value       string `json:"value"`
+}
+
+// CreateVestingSchedule mints tokens into a new vesting schedule for the beneficiary and returns the schedule ID
+// The minted tokens count towards the total supply and the cap, but are not part of any balance until released
+// Only clients holding the MINTER role can create vesting schedules
+// param {String} beneficiary The client account that receives the released tokens, it must not be empty or "0x0"
+// param {String} amount The amount to vest as a base-10 integer string
+// param {Number} start The unix time in seconds the schedule starts vesting at
+// param {Number} cliff The number of seconds after start before which nothing can be released
+// param {Number} duration The number of seconds after start at which the whole amount has vested
+// param {Boolean} revocable Whether an ADMIN can revoke the unvested tokens
+// This function triggers a VestingScheduleCreated event
+func (s *SmartContract) CreateVestingSchedule(ctx contractapi.TransactionContextInterface, beneficiary string, amount string, start int64, cliff int64, duration int64, revocable bool) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization
+	authorized, err := clientHasRole(ctx, minterRole)
+	if err != nil {
+		return "", fmt.Errorf("failed to check minter role: %v", err)
+	}
+	if !authorized {
+		return "", fmt.Errorf("client is not authorized to create vesting schedules")
+	}
+
+	err = checkDirectSupplyChange(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Tokens vested for an empty or zero address could never be released
+	if beneficiary == "" || beneficiary == "0x0" {
+		return "", fmt.Errorf("vesting beneficiary must be a client account")
+	}
+
+	vestingAmount, err := parseAmount(amount)
+	if err != nil {
+		return "", err
+	}
+	if vestingAmount.Sign() <= 0 {
+		return "", fmt.Errorf("vesting amount must be a positive integer")
+	}
+	if duration <= 0 {
+		return "", fmt.Errorf("vesting duration must be positive")
+	}
+	if cliff < 0 || cliff > duration {
+		return "", fmt.Errorf("vesting cliff must be between 0 and the duration")
+	}
+
+	// Mint the vested tokens, the schedule is rejected if it exceeds the cap
+	err = increaseTotalSupply(ctx, vestingAmount)
+	if err != nil {
+		return "", err
+	}
+
+	schedule := &VestingSchedule{
+		ID:          ctx.GetStub().GetTxID(),
+		Beneficiary: beneficiary,
+		Amount:      vestingAmount.String(),
+		Released:    "0",
+		Start:       start,
+		Cliff:       cliff,
+		Duration:    duration,
+		Revocable:   revocable,
+	}
+
+	err = putVestingSchedule(ctx, schedule)
+	if err != nil {
+		return "", err
+	}
+
+	err = emitVestingEvent(ctx, "VestingScheduleCreated", schedule, vestingAmount)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("vesting schedule %s created for beneficiary %s with %s tokens", schedule.ID, beneficiary, vestingAmount)
+
+	return schedule.ID, nil
+}
+
+// Release transfers the vested tokens of the schedule that have not been released yet to its beneficiary
+// Any client can trigger a release, tokens are always credited to the beneficiary
+// This function triggers a TokensReleased event
+func (s *SmartContract) Release(ctx contractapi.TransactionContextInterface, scheduleID string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = checkNotPaused(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	schedule, err := readVestingSchedule(ctx, scheduleID)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkNotFrozen(ctx, schedule.Beneficiary)
+	if err != nil {
+		return "", err
+	}
+
+	vested, err := vestedAmount(ctx, schedule)
+	if err != nil {
+		return "", err
+	}
+
+	released, err := parseAmount(schedule.Released)
+	if err != nil {
+		return "", err
+	}
+
+	releasable := new(big.Int).Sub(vested, released)
+	if releasable.Sign() <= 0 {
+		return "", fmt.Errorf("no tokens of vesting schedule %s are due for release", scheduleID)
+	}
+
+	err = creditBalance(ctx, scheduleID, schedule.Beneficiary, releasable)
+	if err != nil {
+		return "", err
+	}
+
+	err = recordJournal(ctx, schedule.Beneficiary, "0x0", releasable)
+	if err != nil {
+		return "", err
+	}
+
+	schedule.Released = vested.String()
+	err = putVestingSchedule(ctx, schedule)
+	if err != nil {
+		return "", err
+	}
+
+	err = emitVestingEvent(ctx, "TokensReleased", schedule, releasable)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("vesting schedule %s released %s tokens to beneficiary %s", scheduleID, releasable, schedule.Beneficiary)
+
+	return releasable.String(), nil
+}
+
+// Revoke ends a revocable vesting schedule, the tokens vested so far stay releasable and the unvested tokens are burned
+// Only clients holding the ADMIN role can revoke vesting schedules
+// This function triggers a VestingScheduleRevoked event
+func (s *SmartContract) Revoke(ctx contractapi.TransactionContextInterface, scheduleID string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to revoke vesting schedules")
+	}
+
+	schedule, err := readVestingSchedule(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	if !schedule.Revocable {
+		return fmt.Errorf("vesting schedule %s is not revocable", scheduleID)
+	}
+	if schedule.Revoked {
+		return fmt.Errorf("vesting schedule %s is already revoked", scheduleID)
+	}
+
+	vested, err := vestedAmount(ctx, schedule)
+	if err != nil {
+		return err
+	}
+
+	total, err := parseAmount(schedule.Amount)
+	if err != nil {
+		return err
+	}
+
+	unvested := new(big.Int).Sub(total, vested)
+	if unvested.Sign() > 0 {
+		err = decreaseTotalSupply(ctx, unvested)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The schedule keeps only what has vested, so that the beneficiary can still release it
+	schedule.Amount = vested.String()
+	schedule.Revoked = true
+	err = putVestingSchedule(ctx, schedule)
+	if err != nil {
+		return err
+	}
+
+	err = emitVestingEvent(ctx, "VestingScheduleRevoked", schedule, unvested)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("vesting schedule %s revoked, %s unvested tokens burned", scheduleID, unvested)
+
+	return nil
+}
+
+// VestedAmount returns the amount of the schedule that has vested at the transaction timestamp, including released tokens
+func (s *SmartContract) VestedAmount(ctx contractapi.TransactionContextInterface, scheduleID string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	schedule, err := readVestingSchedule(ctx, scheduleID)
+	if err != nil {
+		return "", err
+	}
+
+	vested, err := vestedAmount(ctx, schedule)
+	if err != nil {
+		return "", err
+	}
+
+	return vested.String(), nil
+}
+
+// GetVestingSchedule returns the vesting schedule with the given ID
+func (s *SmartContract) GetVestingSchedule(ctx contractapi.TransactionContextInterface, scheduleID string) (*VestingSchedule, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return readVestingSchedule(ctx, scheduleID)
+}
+
+// Helper Functions
+
+// vestedAmount computes the vested amount of the schedule at the transaction timestamp
+func vestedAmount(ctx contractapi.TransactionContextInterface, schedule *VestingSchedule) (*big.Int, error) {
+	total, err := parseAmount(schedule.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	// A revoked schedule was cut down to its vested amount
+	if schedule.Revoked {
+		return total, nil
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	elapsed := txTimestamp.Seconds - schedule.Start
+	if elapsed < schedule.Cliff {
+		return big.NewInt(0), nil
+	}
+	if elapsed >= schedule.Duration {
+		return total, nil
+	}
+
+	vested := new(big.Int).Mul(total, big.NewInt(elapsed))
+	return vested.Div(vested, big.NewInt(schedule.Duration)), nil
+}
+
+// readVestingSchedule reads the vesting schedule with the given ID from the world state
+func readVestingSchedule(ctx contractapi.TransactionContextInterface, scheduleID string) (*VestingSchedule, error) {
+	scheduleKey, err := ctx.GetStub().CreateCompositeKey(vestingSchedulePrefix, []string{scheduleID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", vestingSchedulePrefix, err)
+	}
+
+	scheduleBytes, err := ctx.GetStub().GetState(scheduleKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vesting schedule %s from world state: %v", scheduleID, err)
+	}
+	if scheduleBytes == nil {
+		return nil, fmt.Errorf("vesting schedule %s does not exist", scheduleID)
+	}
+
+	schedule := new(VestingSchedule)
+	err = json.Unmarshal(scheduleBytes, schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal vesting schedule %s: %v", scheduleID, err)
+	}
+
+	return schedule, nil
+}
+
+// putVestingSchedule writes the vesting schedule to the world state
+func putVestingSchedule(ctx contractapi.TransactionContextInterface, schedule *VestingSchedule) error {
+	scheduleKey, err := ctx.GetStub().CreateCompositeKey(vestingSchedulePrefix, []string{schedule.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", vestingSchedulePrefix, err)
+	}
+
+	scheduleBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vesting schedule %s: %v", schedule.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(scheduleKey, scheduleBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", scheduleKey, err)
+	}
+
+	return nil
+}
+
+// emitVestingEvent emits a vesting event for the schedule
+func emitVestingEvent(ctx contractapi.TransactionContextInterface, eventName string, schedule *VestingSchedule, value *big.Int) error {
+	vestingEventJSON, err := json.Marshal(vestingEvent{schedule.ID, schedule.Beneficiary, value.String()})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, vestingEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
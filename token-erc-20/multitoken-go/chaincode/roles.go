@@ -0,0 +1,384 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define role names
+// ADMIN is held for the whole contract, MINTER and BURNER are held for the tokens of one symbol
+const adminRole = "ADMIN"
+const minterRole = "MINTER"
+const burnerRole = "BURNER"
+
+// Define objectType names for prefix
+const rolePrefix = "role"
+
+// roleEvent provides an organized struct for emitting role events
+// Symbol is empty for the contract-wide ADMIN role
+type roleEvent struct {
+	Symbol  string `json:"symbol"`
+	Role    string `json:"role"`
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// Initialize records the first ADMIN of the contract
+// This sample assumes Org1 is the central banker with privilege to intitialize contract, the calling client is granted the ADMIN role
+// The contract can only be initialized once, further roles are granted with GrantRole
+// This function triggers a RoleGranted event
+func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to intitialize contract
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to initialize contract")
+	}
+
+	// Check contract is not already initialized
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if initialized {
+		return false, fmt.Errorf("contract is already initialized, use GrantRole to grant further roles")
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = putRole(ctx, "", adminRole, clientID)
+	if err != nil {
+		return false, err
+	}
+
+	// Emit the RoleGranted event
+	err = emitRoleEvent(ctx, "RoleGranted", roleEvent{"", adminRole, clientID, clientID})
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("contract initialized by client %s", clientID)
+
+	return true, nil
+}
+
+// GrantRole grants a role to an account
+// symbol must be empty for the ADMIN role, and name a created token for the MINTER and BURNER roles
+// The account can be a client ID, as returned by ClientAccountID(), or an MSP ID, in which case every client of that organization holds the role
+// Only clients holding the ADMIN role can grant roles
+// This function triggers a RoleGranted event
+func (s *SmartContract) GrantRole(ctx contractapi.TransactionContextInterface, symbol string, role string, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(ctx, symbol, role)
+	if err != nil {
+		return err
+	}
+
+	if account == "" {
+		return fmt.Errorf("account must not be empty")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, "", adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to grant roles")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	granted, err := hasRole(ctx, symbol, role, account)
+	if err != nil {
+		return err
+	}
+	if granted {
+		return fmt.Errorf("account %s already has role %s", account, roleName(symbol, role))
+	}
+
+	err = putRole(ctx, symbol, role, account)
+	if err != nil {
+		return err
+	}
+
+	// Emit the RoleGranted event
+	err = emitRoleEvent(ctx, "RoleGranted", roleEvent{symbol, role, account, sender})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s granted role %s to account %s", sender, roleName(symbol, role), account)
+
+	return nil
+}
+
+// RevokeRole revokes a role from an account
+// Only clients holding the ADMIN role can revoke roles, and the last ADMIN cannot be revoked
+// This function triggers a RoleRevoked event
+func (s *SmartContract) RevokeRole(ctx contractapi.TransactionContextInterface, symbol string, role string, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(ctx, symbol, role)
+	if err != nil {
+		return err
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, "", adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to revoke roles")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	granted, err := hasRole(ctx, symbol, role, account)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return fmt.Errorf("account %s does not have role %s", account, roleName(symbol, role))
+	}
+
+	// Revoking the last admin would leave the role registry without anyone able to manage it
+	if role == adminRole {
+		admins, err := roleMembers(ctx, "", adminRole)
+		if err != nil {
+			return err
+		}
+		if len(admins) <= 1 {
+			return fmt.Errorf("cannot revoke the last account with role %s", adminRole)
+		}
+	}
+
+	roleKey, err := ctx.GetStub().CreateCompositeKey(rolePrefix, []string{symbol, role, account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", rolePrefix, err)
+	}
+
+	err = ctx.GetStub().DelState(roleKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete role %s of account %s: %v", roleName(symbol, role), account, err)
+	}
+
+	// Emit the RoleRevoked event
+	err = emitRoleEvent(ctx, "RoleRevoked", roleEvent{symbol, role, account, sender})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s revoked role %s from account %s", sender, roleName(symbol, role), account)
+
+	return nil
+}
+
+// HasRole returns whether the role has been granted to the account
+// The account is matched literally, so a client holding a role through its MSP ID only has it for the MSP ID
+func (s *SmartContract) HasRole(ctx contractapi.TransactionContextInterface, symbol string, role string, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(ctx, symbol, role)
+	if err != nil {
+		return false, err
+	}
+
+	return hasRole(ctx, symbol, role, account)
+}
+
+// GetRoleMembers returns the client IDs and MSP IDs that have been granted the role
+func (s *SmartContract) GetRoleMembers(ctx contractapi.TransactionContextInterface, symbol string, role string) ([]string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(ctx, symbol, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return roleMembers(ctx, symbol, role)
+}
+
+// Helper Functions
+
+// checkInitialized returns whether an ADMIN has been recorded by Initialize
+func checkInitialized(ctx contractapi.TransactionContextInterface) (bool, error) {
+	admins, err := roleMembers(ctx, "", adminRole)
+	if err != nil {
+		return false, err
+	}
+
+	return len(admins) > 0, nil
+}
+
+// validateRole checks that the role is one of the roles known to this contract, held for the right scope
+func validateRole(ctx contractapi.TransactionContextInterface, symbol string, role string) error {
+	switch role {
+	case adminRole:
+		if symbol != "" {
+			return fmt.Errorf("role %s is held for the whole contract, symbol must be empty", adminRole)
+		}
+		return nil
+	case minterRole, burnerRole:
+		_, err := checkToken(ctx, symbol)
+		return err
+	default:
+		return fmt.Errorf("unknown role %s, expected one of %s, %s or %s", role, adminRole, minterRole, burnerRole)
+	}
+}
+
+// roleName returns the role as written in logs and errors, qualified by the symbol for token roles
+func roleName(symbol string, role string) string {
+	if symbol == "" {
+		return role
+	}
+
+	return symbol + " " + role
+}
+
+// hasRole checks whether the role has been granted to the account
+func hasRole(ctx contractapi.TransactionContextInterface, symbol string, role string, account string) (bool, error) {
+	roleKey, err := ctx.GetStub().CreateCompositeKey(rolePrefix, []string{symbol, role, account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", rolePrefix, err)
+	}
+
+	roleBytes, err := ctx.GetStub().GetState(roleKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read role %s of account %s from world state: %v", roleName(symbol, role), account, err)
+	}
+
+	return roleBytes != nil, nil
+}
+
+// clientHasRole checks whether the role has been granted to the submitting client, either by client ID or by MSP ID
+func clientHasRole(ctx contractapi.TransactionContextInterface, symbol string, role string) (bool, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	granted, err := hasRole(ctx, symbol, role, clientID)
+	if err != nil || granted {
+		return granted, err
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSPID: %v", err)
+	}
+
+	return hasRole(ctx, symbol, role, clientMSPID)
+}
+
+// putRole records the role for the account
+// A composite key of symbol.role.account enables partial composite key query to find all members of a role
+// An empty value would represent a delete, so we simply insert the null character
+func putRole(ctx contractapi.TransactionContextInterface, symbol string, role string, account string) error {
+	roleKey, err := ctx.GetStub().CreateCompositeKey(rolePrefix, []string{symbol, role, account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", rolePrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(roleKey, []byte{'\u0000'})
+	if err != nil {
+		return fmt.Errorf("failed to grant role %s to account %s: %v", roleName(symbol, role), account, err)
+	}
+
+	return nil
+}
+
+// roleMembers returns all accounts holding the role
+func roleMembers(ctx contractapi.TransactionContextInterface, symbol string, role string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(rolePrefix, []string{symbol, role})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members of role %s: %v", roleName(symbol, role), err)
+	}
+	defer iterator.Close()
+
+	members := []string{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be symbol.role.account
+		if len(compositeKeyParts) != 3 {
+			return nil, fmt.Errorf("expected composite key with three parts (symbol:role:account)")
+		}
+
+		members = append(members, compositeKeyParts[2])
+	}
+
+	return members, nil
+}
+
+// emitRoleEvent emits a RoleGranted or RoleRevoked event
+func emitRoleEvent(ctx contractapi.TransactionContextInterface, name string, e roleEvent) error {
+	eventJSON, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent(name, eventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,887 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+// Every key is namespaced by the token symbol, so that any number of tokens can share one chaincode
+const tokenPrefix = "token"
+const balancePrefix = "balance"
+const allowancePrefix = "allowance"
+const totalSupplyPrefix = "totalSupply"
+
+// SmartContract provides functions for creating fungible tokens and transferring them between accounts
+type SmartContract struct {
+	contractapi.Contract
+}
+
+// Token describes a fungible token created with CreateToken()
+// Cap is a base-10 integer string, 0 for an uncapped supply
+// Tokens of a symbol can only be minted and burned by accounts holding the MINTER and BURNER roles for the symbol
+type Token struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+	Cap      string `json:"cap"`
+}
+
+// tokenCreatedEvent provides an organized struct for emitting the TokenCreated event
+// A transaction can only emit one event, so it lists the roles granted with the token
+type tokenCreatedEvent struct {
+	Token
+	Roles []roleEvent `json:"roles"`
+}
+
+// event provides an organized struct for emitting events
+// Value is a base-10 string so that amounts beyond the range of a JSON number are not truncated
+type event struct {
+	Symbol string `json:"symbol"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Value  string `json:"value"`
+}
+
+// CreateToken registers a new fungible token
+// Only clients holding the ADMIN role can create tokens, the MSP of the creating client is granted the MINTER and BURNER roles for the symbol
+// param {String} symbol The symbol of the token, which identifies it in every other function
+// param {String} name The name of the token
+// param {String} decimals The decimals used for the token operations
+// param {String} cap The maximum total supply as a base-10 integer string, 0 for an uncapped supply
+// This function triggers a TokenCreated event
+func (s *SmartContract) CreateToken(ctx contractapi.TransactionContextInterface, symbol string, name string, decimals string, cap string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, "", adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to create tokens")
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSPID: %v", err)
+	}
+
+	if symbol == "" {
+		return fmt.Errorf("symbol must not be empty")
+	}
+
+	// Check the symbol is not already taken, client is not authorized to change a token once created
+	_, exists, err := readToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("token %s already exists", symbol)
+	}
+
+	tokenDecimals, err := strconv.Atoi(decimals)
+	if err != nil {
+		return fmt.Errorf("failed to parse decimals %s: %v", decimals, err)
+	}
+	if tokenDecimals < 0 {
+		return fmt.Errorf("decimals cannot be negative")
+	}
+
+	supplyCap, err := parseAmount(cap)
+	if err != nil {
+		return err
+	}
+	if supplyCap.Sign() < 0 {
+		return fmt.Errorf("cap cannot be negative")
+	}
+
+	token := Token{
+		Symbol:   symbol,
+		Name:     name,
+		Decimals: tokenDecimals,
+		Cap:      supplyCap.String(),
+	}
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{symbol})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(tokenKey, tokenJSON)
+	if err != nil {
+		return fmt.Errorf("failed to create token %s: %v", symbol, err)
+	}
+
+	grants := []roleEvent{
+		{symbol, minterRole, clientMSPID, clientID},
+		{symbol, burnerRole, clientMSPID, clientID},
+	}
+	for _, grant := range grants {
+		err = putRole(ctx, grant.Symbol, grant.Role, grant.Account)
+		if err != nil {
+			return err
+		}
+	}
+
+	createdJSON, err := json.Marshal(tokenCreatedEvent{token, grants})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("TokenCreated", createdJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("token %s created with a cap of %s", symbol, supplyCap)
+
+	return nil
+}
+
+// ListTokens returns all tokens created with CreateToken(), ordered by symbol
+func (s *SmartContract) ListTokens(ctx contractapi.TransactionContextInterface) ([]*Token, error) {
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tokens: %v", err)
+	}
+	defer iterator.Close()
+
+	tokens := []*Token{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		token := new(Token)
+		err = json.Unmarshal(queryResponse.Value, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal token %s: %v", queryResponse.Key, err)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// Mint creates new tokens of the symbol and adds them to minter's account balance
+// This function triggers a Transfer event
+// amount is a base-10 integer string in the token's smallest unit
+func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, symbol string, amount string) error {
+
+	// Check the token exists first
+	token, err := checkToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	// Check minter authorization
+	authorized, err := clientHasRole(ctx, symbol, minterRole)
+	if err != nil {
+		return fmt.Errorf("failed to check minter role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to mint new %s tokens", symbol)
+	}
+
+	// Get ID of submitting client identity
+	minter, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	mintAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if mintAmount.Sign() <= 0 {
+		return fmt.Errorf("mint amount must be a positive integer")
+	}
+
+	// If minter current balance doesn't yet exist, we'll create it with a current balance of 0
+	currentBalance, _, err := readBalance(ctx, symbol, minter)
+	if err != nil {
+		return err
+	}
+
+	updatedBalance, err := add(currentBalance, mintAmount)
+	if err != nil {
+		return err
+	}
+
+	// Update the totalSupply, the mint is rejected if it exceeds the cap
+	totalSupply, err := readTotalSupply(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	totalSupply, err = add(totalSupply, mintAmount)
+	if err != nil {
+		return err
+	}
+
+	supplyCap, err := parseAmount(token.Cap)
+	if err != nil {
+		return err
+	}
+	if supplyCap.Sign() > 0 && totalSupply.Cmp(supplyCap) > 0 {
+		return fmt.Errorf("total supply of %s %s would exceed the cap of %s", totalSupply, symbol, supplyCap)
+	}
+
+	err = putBalance(ctx, symbol, minter, updatedBalance)
+	if err != nil {
+		return err
+	}
+
+	err = putTotalSupply(ctx, symbol, totalSupply)
+	if err != nil {
+		return err
+	}
+
+	// Emit the Transfer event
+	err = emitEvent(ctx, "Transfer", event{symbol, "0x0", minter, mintAmount.String()})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("minter account %s %s balance updated from %s to %s", minter, symbol, currentBalance, updatedBalance)
+
+	return nil
+}
+
+// Burn redeems tokens of the symbol from the minter's account balance
+// This function triggers a Transfer event
+// amount is a base-10 integer string in the token's smallest unit
+func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, symbol string, amount string) error {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	// Check burner authorization
+	authorized, err := clientHasRole(ctx, symbol, burnerRole)
+	if err != nil {
+		return fmt.Errorf("failed to check burner role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to burn %s tokens", symbol)
+	}
+
+	// Get ID of submitting client identity
+	minter, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	burnAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if burnAmount.Sign() <= 0 {
+		return errors.New("burn amount must be a positive integer")
+	}
+
+	currentBalance, exists, err := readBalance(ctx, symbol, minter)
+	if err != nil {
+		return err
+	}
+
+	// Check if minter current balance exists
+	if !exists {
+		return errors.New("The balance does not exist")
+	}
+
+	updatedBalance, err := sub(currentBalance, burnAmount)
+	if err != nil {
+		return err
+	}
+
+	totalSupply, err := readTotalSupply(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	totalSupply, err = sub(totalSupply, burnAmount)
+	if err != nil {
+		return err
+	}
+
+	err = putBalance(ctx, symbol, minter, updatedBalance)
+	if err != nil {
+		return err
+	}
+
+	err = putTotalSupply(ctx, symbol, totalSupply)
+	if err != nil {
+		return err
+	}
+
+	// Emit the Transfer event
+	err = emitEvent(ctx, "Transfer", event{symbol, minter, "0x0", burnAmount.String()})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("minter account %s %s balance updated from %s to %s", minter, symbol, currentBalance, updatedBalance)
+
+	return nil
+}
+
+// Transfer transfers tokens of the symbol from client account to recipient account
+// recipient account must be a valid clientID as returned by the ClientAccountID() function
+// This function triggers a Transfer event
+func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, symbol string, recipient string, amount string) error {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	value, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	err = transferHelper(ctx, symbol, clientID, recipient, value)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	// Emit the Transfer event
+	return emitEvent(ctx, "Transfer", event{symbol, clientID, recipient, value.String()})
+}
+
+// BalanceOf returns the balance of the given account in tokens of the symbol
+func (s *SmartContract) BalanceOf(ctx contractapi.TransactionContextInterface, symbol string, account string) (string, error) {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	balance, exists, err := readBalance(ctx, symbol, account)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("the account %s does not exist", account)
+	}
+
+	return balance.String(), nil
+}
+
+// ClientAccountBalance returns the balance of the requesting client's account in tokens of the symbol
+func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface, symbol string) (string, error) {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	balance, exists, err := readBalance(ctx, symbol, clientID)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("the account %s does not exist", clientID)
+	}
+
+	return balance.String(), nil
+}
+
+// ClientAccountID returns the id of the requesting client's account
+// In this implementation, the client account ID is the clientId itself, the same account holds every token
+// Users can use this function to get their own account id, which they can then give to others as the payment address
+func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Get ID of submitting client identity
+	clientAccountID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	return clientAccountID, nil
+}
+
+// TotalSupply returns the total supply of tokens of the symbol
+func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface, symbol string) (string, error) {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	// If no tokens have been minted, return 0
+	totalSupply, err := readTotalSupply(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("TotalSupply: %s %s tokens", totalSupply, symbol)
+
+	return totalSupply.String(), nil
+}
+
+// Approve allows the spender to withdraw tokens of the symbol from the calling client's token account
+// The spender can withdraw multiple times if necessary, up to the value amount, approving 0 revokes the spender
+// This function triggers an Approval event
+func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, symbol string, spender string, value string) error {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	// Get ID of submitting client identity
+	owner, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	allowance, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+	if allowance.Sign() < 0 {
+		return fmt.Errorf("allowance cannot be negative")
+	}
+
+	err = putAllowance(ctx, symbol, owner, spender, allowance)
+	if err != nil {
+		return err
+	}
+
+	// Emit the Approval event
+	err = emitEvent(ctx, "Approval", event{symbol, owner, spender, allowance.String()})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s approved a withdrawal allowance of %s %s for spender %s", owner, allowance, symbol, spender)
+
+	return nil
+}
+
+// Allowance returns the amount of tokens of the symbol still available for the spender to withdraw from the owner
+func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, symbol string, owner string, spender string) (string, error) {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	allowance, err := readAllowance(ctx, symbol, owner, spender)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("The allowance left for spender %s to withdraw from owner %s: %s %s", spender, owner, allowance, symbol)
+
+	return allowance.String(), nil
+}
+
+// TransferFrom transfers the value amount of tokens of the symbol from the "from" address to the "to" address
+// This function triggers a Transfer event
+func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, symbol string, from string, to string, value string) error {
+
+	// Check the token exists first
+	_, err := checkToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	// Get ID of submitting client identity
+	spender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// Retrieve the allowance of the spender
+	currentAllowance, err := readAllowance(ctx, symbol, from, spender)
+	if err != nil {
+		return err
+	}
+
+	transferValue, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+
+	// Check if transferred value is less than allowance
+	if currentAllowance.Cmp(transferValue) < 0 {
+		return fmt.Errorf("spender does not have enough allowance for transfer")
+	}
+
+	// Initiate the transfer
+	err = transferHelper(ctx, symbol, from, to, transferValue)
+	if err != nil {
+		return fmt.Errorf("failed to transfer: %v", err)
+	}
+
+	// Decrease the allowance
+	updatedAllowance, err := sub(currentAllowance, transferValue)
+	if err != nil {
+		return err
+	}
+
+	err = putAllowance(ctx, symbol, from, spender, updatedAllowance)
+	if err != nil {
+		return err
+	}
+
+	// Emit the Transfer event
+	err = emitEvent(ctx, "Transfer", event{symbol, from, to, transferValue.String()})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("spender %s %s allowance updated from %s to %s", spender, symbol, currentAllowance, updatedAllowance)
+
+	return nil
+}
+
+// Name returns a descriptive name for the token of the symbol
+func (s *SmartContract) Name(ctx contractapi.TransactionContextInterface, symbol string) (string, error) {
+
+	token, err := checkToken(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	return token.Name, nil
+}
+
+// Decimals returns the number of decimals the token of the symbol uses
+func (s *SmartContract) Decimals(ctx contractapi.TransactionContextInterface, symbol string) (int, error) {
+
+	token, err := checkToken(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	return token.Decimals, nil
+}
+
+// Cap returns the maximum total supply of the token of the symbol, 0 if the supply is not capped
+func (s *SmartContract) Cap(ctx contractapi.TransactionContextInterface, symbol string) (string, error) {
+
+	token, err := checkToken(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	return token.Cap, nil
+}
+
+// Helper Functions
+
+// transferHelper is a helper function that transfers tokens of the symbol from the "from" address to the "to" address
+// Dependant functions include Transfer and TransferFrom
+func transferHelper(ctx contractapi.TransactionContextInterface, symbol string, from string, to string, value *big.Int) error {
+
+	if from == to {
+		return fmt.Errorf("cannot transfer to and from same client account")
+	}
+
+	if value.Sign() < 0 { // transfer of 0 is allowed in ERC-20, so just validate against negative amounts
+		return fmt.Errorf("transfer amount cannot be negative")
+	}
+
+	fromCurrentBalance, fromExists, err := readBalance(ctx, symbol, from)
+	if err != nil {
+		return err
+	}
+
+	if !fromExists {
+		return fmt.Errorf("client account %s has no %s balance", from, symbol)
+	}
+
+	if fromCurrentBalance.Cmp(value) < 0 {
+		return fmt.Errorf("client account %s has insufficient funds", from)
+	}
+
+	// If recipient current balance doesn't yet exist, we'll create it with a current balance of 0
+	toCurrentBalance, _, err := readBalance(ctx, symbol, to)
+	if err != nil {
+		return err
+	}
+
+	fromUpdatedBalance, err := sub(fromCurrentBalance, value)
+	if err != nil {
+		return err
+	}
+
+	toUpdatedBalance, err := add(toCurrentBalance, value)
+	if err != nil {
+		return err
+	}
+
+	err = putBalance(ctx, symbol, from, fromUpdatedBalance)
+	if err != nil {
+		return err
+	}
+
+	err = putBalance(ctx, symbol, to, toUpdatedBalance)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s %s balance updated from %s to %s", from, symbol, fromCurrentBalance, fromUpdatedBalance)
+	log.Printf("recipient %s %s balance updated from %s to %s", to, symbol, toCurrentBalance, toUpdatedBalance)
+
+	return nil
+}
+
+// readToken reads the token of the symbol from the world state
+func readToken(ctx contractapi.TransactionContextInterface, symbol string) (*Token, bool, error) {
+	tokenKey, err := ctx.GetStub().CreateCompositeKey(tokenPrefix, []string{symbol})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create the composite key for prefix %s: %v", tokenPrefix, err)
+	}
+
+	tokenBytes, err := ctx.GetStub().GetState(tokenKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read token %s from world state: %v", symbol, err)
+	}
+	if tokenBytes == nil {
+		return nil, false, nil
+	}
+
+	token := new(Token)
+	err = json.Unmarshal(tokenBytes, token)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal token %s: %v", symbol, err)
+	}
+
+	return token, true, nil
+}
+
+// checkToken returns the token of the symbol, or an error if it has not been created
+func checkToken(ctx contractapi.TransactionContextInterface, symbol string) (*Token, error) {
+	token, exists, err := readToken(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("token %s does not exist, call CreateToken() to create it", symbol)
+	}
+
+	return token, nil
+}
+
+// readBalance returns the balance of the account in tokens of the symbol, exists is false if the account never held them
+func readBalance(ctx contractapi.TransactionContextInterface, symbol string, account string) (*big.Int, bool, error) {
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(balancePrefix, []string{symbol, account})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create the composite key for prefix %s: %v", balancePrefix, err)
+	}
+
+	balanceBytes, err := ctx.GetStub().GetState(balanceKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read account %s from world state: %v", account, err)
+	}
+
+	balance, err := amountFromBytes(balanceBytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s balance of account %s: %v", symbol, account, err)
+	}
+
+	return balance, balanceBytes != nil, nil
+}
+
+// putBalance writes the balance of the account in tokens of the symbol
+func putBalance(ctx contractapi.TransactionContextInterface, symbol string, account string, balance *big.Int) error {
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(balancePrefix, []string{symbol, account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", balancePrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(balanceKey, []byte(balance.String()))
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", balanceKey, err)
+	}
+
+	return nil
+}
+
+// readTotalSupply returns the total supply of tokens of the symbol, 0 if none were minted
+func readTotalSupply(ctx contractapi.TransactionContextInterface, symbol string) (*big.Int, error) {
+	totalSupplyKey, err := ctx.GetStub().CreateCompositeKey(totalSupplyPrefix, []string{symbol})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", totalSupplyPrefix, err)
+	}
+
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+
+	totalSupply, err := amountFromBytes(totalSupplyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read total token supply: %v", err)
+	}
+
+	return totalSupply, nil
+}
+
+// putTotalSupply writes the total supply of tokens of the symbol
+func putTotalSupply(ctx contractapi.TransactionContextInterface, symbol string, totalSupply *big.Int) error {
+	totalSupplyKey, err := ctx.GetStub().CreateCompositeKey(totalSupplyPrefix, []string{symbol})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", totalSupplyPrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(totalSupplyKey, []byte(totalSupply.String()))
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", totalSupplyKey, err)
+	}
+
+	return nil
+}
+
+// readAllowance returns the allowance of the spender over the owner's tokens of the symbol, 0 if there is none
+func readAllowance(ctx contractapi.TransactionContextInterface, symbol string, owner string, spender string) (*big.Int, error) {
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{symbol, owner, spender})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+	}
+
+	allowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowance for %s from world state: %v", allowanceKey, err)
+	}
+
+	// If no current allowance, set allowance to 0
+	allowance, err := amountFromBytes(allowanceBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowance for %s: %v", allowanceKey, err)
+	}
+
+	return allowance, nil
+}
+
+// putAllowance writes the allowance of the spender over the owner's tokens of the symbol, a zero allowance deletes the entry
+func putAllowance(ctx contractapi.TransactionContextInterface, symbol string, owner string, spender string, allowance *big.Int) error {
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{symbol, owner, spender})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+	}
+
+	if allowance.Sign() == 0 {
+		err = ctx.GetStub().DelState(allowanceKey)
+	} else {
+		err = ctx.GetStub().PutState(allowanceKey, []byte(allowance.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update state of smart contract for key %s: %v", allowanceKey, err)
+	}
+
+	return nil
+}
+
+// emitEvent emits a Transfer or Approval event
+func emitEvent(ctx contractapi.TransactionContextInterface, eventName string, e event) error {
+	eventJSON, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, eventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// add two numbers, token amounts are unbounded so only negative operands are rejected
+func add(b *big.Int, q *big.Int) (*big.Int, error) {
+	if b.Sign() < 0 || q.Sign() < 0 {
+		return nil, fmt.Errorf("Error: cannot add negative numbers %s and %s", b, q)
+	}
+
+	return new(big.Int).Add(b, q), nil
+}
+
+// sub two number checking for underflow
+func sub(b *big.Int, q *big.Int) (*big.Int, error) {
+
+	// sub two number checking
+	if q.Sign() <= 0 {
+		return nil, fmt.Errorf("Error: the subtraction number is %s, it should be greater than 0", q)
+	}
+	if b.Cmp(q) < 0 {
+		return nil, fmt.Errorf("Error: the number %s is not enough to be subtracted by %s", b, q)
+	}
+
+	return new(big.Int).Sub(b, q), nil
+}
+
+// parseAmount parses a token amount passed as a base-10 integer string
+func parseAmount(amount string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q, it should be a base-10 integer", amount)
+	}
+
+	return value, nil
+}
+
+// amountFromBytes parses a balance, allowance or total supply read from the world state, nil is read as 0
+func amountFromBytes(amountBytes []byte) (*big.Int, error) {
+	if amountBytes == nil {
+		return big.NewInt(0), nil
+	}
+
+	amount, ok := new(big.Int).SetString(string(amountBytes), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q stored in world state", string(amountBytes))
+	}
+
+	return amount, nil
+}
@@ -0,0 +1,23 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/token-erc-20/multitoken-go/chaincode"
+)
+
+func main() {
+	tokenChaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	if err != nil {
+		log.Panicf("Error creating token-erc-20 multi-token chaincode: %v", err)
+	}
+
+	if err := tokenChaincode.Start(); err != nil {
+		log.Panicf("Error starting token-erc-20 multi-token chaincode: %v", err)
+	}
+}
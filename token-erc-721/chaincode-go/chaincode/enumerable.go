@@ -0,0 +1,467 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const balanceCountPrefix = "balanceCount"
+const ownedTokensPrefix = "ownedTokens"
+const ownedTokensIndexPrefix = "ownedTokensIndex"
+const allTokensPrefix = "allTokens"
+const allTokensIndexPrefix = "allTokensIndex"
+
+// Define key names for counters
+const totalSupplyKey = "totalSupply"
+
+// PaginatedTokens holds a page of token IDs and the bookmark to fetch the next page with
+type PaginatedTokens struct {
+	Records             []string `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
+// TokenByIndex enumerates all non-fungible tokens tracked by this contract
+// param {Number} index A counter less than TotalSupply()
+// returns {String} The token ID of the index-th non-fungible token (sort order not specified)
+func (c *TokenERC721Contract) TokenByIndex(ctx contractapi.TransactionContextInterface, index int) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	totalSupply, err := _readTotalSupply(ctx)
+	if err != nil {
+		return "", err
+	}
+	if index < 0 || index >= totalSupply {
+		return "", fmt.Errorf("index %d is out of bounds, the total supply is %d", index, totalSupply)
+	}
+
+	return _readIndexedToken(ctx, allTokensPrefix, []string{strconv.Itoa(index)})
+}
+
+// TokenOfOwnerByIndex enumerates the non-fungible tokens assigned to an owner
+// param {String} owner An owner whose tokens to enumerate
+// param {Number} index A counter less than BalanceOf(owner)
+// returns {String} The token ID of the index-th non-fungible token assigned to the owner (sort order not specified)
+func (c *TokenERC721Contract) TokenOfOwnerByIndex(ctx contractapi.TransactionContextInterface, owner string, index int) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	balance, err := _readBalanceCount(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	if index < 0 || index >= balance {
+		return "", fmt.Errorf("index %d is out of bounds, the balance of %s is %d", index, owner, balance)
+	}
+
+	return _readIndexedToken(ctx, ownedTokensPrefix, []string{owner, strconv.Itoa(index)})
+}
+
+// TokensOfOwner returns a page of the non-fungible tokens assigned to an owner, ordered by token ID
+// Pass an empty bookmark for the first page and the returned bookmark for the next one
+// Paginated queries are only valid for read only transactions.
+func (c *TokenERC721Contract) TokensOfOwner(ctx contractapi.TransactionContextInterface, owner string, pageSize int, bookmark string) (*PaginatedTokens, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// There is a key record for every non-fungible token in the format of balancePrefix.owner.tokenId,
+	// the token ID is the second part of the composite key
+	return _paginateTokens(ctx, balancePrefix, []string{owner}, pageSize, bookmark)
+}
+
+// AllTokens returns a page of all non-fungible tokens tracked by this contract, ordered by token ID
+// Pass an empty bookmark for the first page and the returned bookmark for the next one
+// Paginated queries are only valid for read only transactions.
+func (c *TokenERC721Contract) AllTokens(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*PaginatedTokens, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// There is a key record for every non-fungible token in the format of nftPrefix.tokenId
+	return _paginateTokens(ctx, nftPrefix, []string{}, pageSize, bookmark)
+}
+
+// IndexTokens adds non-fungible tokens minted before the enumeration extension was deployed to the enumerations,
+// the balance counts and the total supply. Tokens that are already indexed are skipped, so a batch can be submitted again.
+// Run it once after the upgrade for every page of AllTokens, until TotalSupply counts every token
+// param {Array} tokenIds The token IDs to index
+// returns {Number} Return the number of tokens that were indexed
+func (c *TokenERC721Contract) IndexTokens(ctx contractapi.TransactionContextInterface, tokenIds []string) (int, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to index tokens
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return 0, fmt.Errorf("client is not authorized to index tokens")
+	}
+
+	// A transaction does not read its own writes, so the counts are kept in memory and written once at the end
+	totalSupply, err := _readTotalSupply(ctx)
+	if err != nil {
+		return 0, err
+	}
+	balanceCounts := make(map[string]int)
+	seen := make(map[string]bool)
+
+	indexed := 0
+	for _, tokenId := range tokenIds {
+		if seen[tokenId] {
+			continue
+		}
+		seen[tokenId] = true
+
+		nft, err := _readNFT(ctx, tokenId)
+		if err != nil {
+			return 0, fmt.Errorf("failed to _readNFT %s: %v", tokenId, err)
+		}
+
+		positionKey, err := ctx.GetStub().CreateCompositeKey(allTokensIndexPrefix, []string{tokenId})
+		if err != nil {
+			return 0, fmt.Errorf("failed to CreateCompositeKey %s: %v", allTokensIndexPrefix, err)
+		}
+		positionBytes, err := ctx.GetStub().GetState(positionKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to GetState %s: %v", positionKey, err)
+		}
+		if len(positionBytes) > 0 {
+			continue
+		}
+
+		err = _putEnumerationIndex(ctx, allTokensPrefix, allTokensIndexPrefix, []string{}, tokenId, totalSupply)
+		if err != nil {
+			return 0, err
+		}
+		totalSupply++
+
+		balanceCount, ok := balanceCounts[nft.Owner]
+		if !ok {
+			balanceCount, err = _readBalanceCount(ctx, nft.Owner)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		err = _putEnumerationIndex(ctx, ownedTokensPrefix, ownedTokensIndexPrefix, []string{nft.Owner}, tokenId, balanceCount)
+		if err != nil {
+			return 0, err
+		}
+		balanceCounts[nft.Owner] = balanceCount + 1
+
+		indexed++
+	}
+
+	err = _putCounter(ctx, totalSupplyKey, totalSupply)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sort the owners, because iterating maps in Go is not deterministic
+	owners := make([]string, 0, len(balanceCounts))
+	for owner := range balanceCounts {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	for _, owner := range owners {
+		balanceCountKey, err := ctx.GetStub().CreateCompositeKey(balanceCountPrefix, []string{owner})
+		if err != nil {
+			return 0, fmt.Errorf("failed to CreateCompositeKey balanceCountKey: %v", err)
+		}
+
+		err = _putCounter(ctx, balanceCountKey, balanceCounts[owner])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return indexed, nil
+}
+
+// Helper Functions
+
+// _paginateTokens returns the token IDs of a page of composite keys whose last part is the token ID
+func _paginateTokens(ctx contractapi.TransactionContextInterface, objectType string, keys []string, pageSize int, bookmark string) (*PaginatedTokens, error) {
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, keys, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state for prefix %s: %v", objectType, err)
+	}
+	defer resultsIterator.Close()
+
+	records := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != len(keys)+1 {
+			return nil, fmt.Errorf("expected composite key with %d parts for prefix %s", len(keys)+1, objectType)
+		}
+
+		records = append(records, compositeKeyParts[len(keys)])
+	}
+
+	return &PaginatedTokens{
+		Records:             records,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// _readIndexedToken reads the token ID stored at an enumeration index
+func _readIndexedToken(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) (string, error) {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to CreateCompositeKey %s: %v", objectType, err)
+	}
+
+	tokenIdBytes, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to GetState %s: %v", indexKey, err)
+	}
+	if len(tokenIdBytes) == 0 {
+		return "", fmt.Errorf("no token is stored at %s %v", objectType, attributes)
+	}
+
+	return string(tokenIdBytes), nil
+}
+
+// _readCounter reads a non-negative count, a missing key counts as zero
+func _readCounter(ctx contractapi.TransactionContextInterface, key string) (int, error) {
+	countBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to GetState %s: %v", key, err)
+	}
+	if len(countBytes) == 0 {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(string(countBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert count %s: %v", string(countBytes), err)
+	}
+
+	return count, nil
+}
+
+// _putCounter stores a count, a count of zero deletes the key
+func _putCounter(ctx contractapi.TransactionContextInterface, key string, count int) error {
+	if count == 0 {
+		err := ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to DelState %s: %v", key, err)
+		}
+		return nil
+	}
+
+	err := ctx.GetStub().PutState(key, []byte(strconv.Itoa(count)))
+	if err != nil {
+		return fmt.Errorf("failed to PutState %s: %v", key, err)
+	}
+
+	return nil
+}
+
+func _readBalanceCount(ctx contractapi.TransactionContextInterface, owner string) (int, error) {
+	balanceCountKey, err := ctx.GetStub().CreateCompositeKey(balanceCountPrefix, []string{owner})
+	if err != nil {
+		return 0, fmt.Errorf("failed to CreateCompositeKey balanceCountKey: %v", err)
+	}
+
+	return _readCounter(ctx, balanceCountKey)
+}
+
+func _readTotalSupply(ctx contractapi.TransactionContextInterface) (int, error) {
+	return _readCounter(ctx, totalSupplyKey)
+}
+
+// _addTokenToEnumeration appends a token to a list kept as indexPrefix.[owner].index -> tokenId,
+// with the position of the token kept as positionPrefix.tokenId -> index and the length in countKey
+func _addTokenToEnumeration(ctx contractapi.TransactionContextInterface, indexPrefix string, positionPrefix string, countKey string, owner []string, tokenId string) error {
+	count, err := _readCounter(ctx, countKey)
+	if err != nil {
+		return err
+	}
+
+	err = _putEnumerationIndex(ctx, indexPrefix, positionPrefix, owner, tokenId, count)
+	if err != nil {
+		return err
+	}
+
+	return _putCounter(ctx, countKey, count+1)
+}
+
+// _putEnumerationIndex stores a token at an index of a list written by _addTokenToEnumeration, without updating its count
+func _putEnumerationIndex(ctx contractapi.TransactionContextInterface, indexPrefix string, positionPrefix string, owner []string, tokenId string, index int) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(indexPrefix, append(owner, strconv.Itoa(index)))
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey %s: %v", indexPrefix, err)
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte(tokenId))
+	if err != nil {
+		return fmt.Errorf("failed to PutState %s: %v", indexKey, err)
+	}
+
+	positionKey, err := ctx.GetStub().CreateCompositeKey(positionPrefix, []string{tokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey %s: %v", positionPrefix, err)
+	}
+	err = ctx.GetStub().PutState(positionKey, []byte(strconv.Itoa(index)))
+	if err != nil {
+		return fmt.Errorf("failed to PutState %s: %v", positionKey, err)
+	}
+
+	return nil
+}
+
+// _removeTokenFromEnumeration removes a token from a list written by _addTokenToEnumeration.
+// To keep the list dense, the last token is moved into the slot of the removed token.
+// It returns false if the token has no position in the list, which is the case for tokens
+// minted before the enumeration extension was deployed.
+func _removeTokenFromEnumeration(ctx contractapi.TransactionContextInterface, indexPrefix string, positionPrefix string, countKey string, owner []string, tokenId string) (bool, error) {
+	positionKey, err := ctx.GetStub().CreateCompositeKey(positionPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey %s: %v", positionPrefix, err)
+	}
+
+	positionBytes, err := ctx.GetStub().GetState(positionKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to GetState %s: %v", positionKey, err)
+	}
+	if len(positionBytes) == 0 {
+		return false, nil
+	}
+
+	position, err := strconv.Atoi(string(positionBytes))
+	if err != nil {
+		return false, fmt.Errorf("failed to convert position %s: %v", string(positionBytes), err)
+	}
+
+	count, err := _readCounter(ctx, countKey)
+	if err != nil {
+		return false, err
+	}
+	if position >= count {
+		return false, fmt.Errorf("token %s is at %s index %d, beyond the count of %d", tokenId, indexPrefix, position, count)
+	}
+	lastPosition := count - 1
+
+	// Move the last token into the slot of the removed token
+	if position != lastPosition {
+		lastTokenId, err := _readIndexedToken(ctx, indexPrefix, append(owner, strconv.Itoa(lastPosition)))
+		if err != nil {
+			return false, err
+		}
+
+		indexKey, err := ctx.GetStub().CreateCompositeKey(indexPrefix, append(owner, strconv.Itoa(position)))
+		if err != nil {
+			return false, fmt.Errorf("failed to CreateCompositeKey %s: %v", indexPrefix, err)
+		}
+		err = ctx.GetStub().PutState(indexKey, []byte(lastTokenId))
+		if err != nil {
+			return false, fmt.Errorf("failed to PutState %s: %v", indexKey, err)
+		}
+
+		lastPositionKey, err := ctx.GetStub().CreateCompositeKey(positionPrefix, []string{lastTokenId})
+		if err != nil {
+			return false, fmt.Errorf("failed to CreateCompositeKey %s: %v", positionPrefix, err)
+		}
+		err = ctx.GetStub().PutState(lastPositionKey, []byte(strconv.Itoa(position)))
+		if err != nil {
+			return false, fmt.Errorf("failed to PutState %s: %v", lastPositionKey, err)
+		}
+	}
+
+	lastIndexKey, err := ctx.GetStub().CreateCompositeKey(indexPrefix, append(owner, strconv.Itoa(lastPosition)))
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey %s: %v", indexPrefix, err)
+	}
+	err = ctx.GetStub().DelState(lastIndexKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState %s: %v", lastIndexKey, err)
+	}
+
+	err = ctx.GetStub().DelState(positionKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState %s: %v", positionKey, err)
+	}
+
+	return true, _putCounter(ctx, countKey, lastPosition)
+}
+
+// _addTokenToOwnerEnumeration records a token in the enumeration of its owner and increases the owner's balance count
+func _addTokenToOwnerEnumeration(ctx contractapi.TransactionContextInterface, owner string, tokenId string) error {
+	balanceCountKey, err := ctx.GetStub().CreateCompositeKey(balanceCountPrefix, []string{owner})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey balanceCountKey: %v", err)
+	}
+
+	return _addTokenToEnumeration(ctx, ownedTokensPrefix, ownedTokensIndexPrefix, balanceCountKey, []string{owner}, tokenId)
+}
+
+// _removeTokenFromOwnerEnumeration removes a token from the enumeration of its owner and decreases the owner's balance count
+func _removeTokenFromOwnerEnumeration(ctx contractapi.TransactionContextInterface, owner string, tokenId string) (bool, error) {
+	balanceCountKey, err := ctx.GetStub().CreateCompositeKey(balanceCountPrefix, []string{owner})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey balanceCountKey: %v", err)
+	}
+
+	return _removeTokenFromEnumeration(ctx, ownedTokensPrefix, ownedTokensIndexPrefix, balanceCountKey, []string{owner}, tokenId)
+}
+
+// _addTokenToAllTokensEnumeration records a token in the enumeration of all tokens and increases the total supply
+func _addTokenToAllTokensEnumeration(ctx contractapi.TransactionContextInterface, tokenId string) error {
+	return _addTokenToEnumeration(ctx, allTokensPrefix, allTokensIndexPrefix, totalSupplyKey, []string{}, tokenId)
+}
+
+// _removeTokenFromAllTokensEnumeration removes a token from the enumeration of all tokens and decreases the total supply
+func _removeTokenFromAllTokensEnumeration(ctx contractapi.TransactionContextInterface, tokenId string) (bool, error) {
+	return _removeTokenFromEnumeration(ctx, allTokensPrefix, allTokensIndexPrefix, totalSupplyKey, []string{}, tokenId)
+}
@@ -70,23 +70,12 @@ func (c *TokenERC721Contract) BalanceOf(ctx contractapi.TransactionContextInterf
 		panic("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
-	// There is a key record for every non-fungible token in the format of balancePrefix.owner.tokenId.
-	// BalanceOf() queries for and counts all records matching balancePrefix.owner.*
+	// The number of tokens of every owner is counted in balanceCountPrefix.owner
+	// when tokens are minted, transferred and burned
 
-	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balancePrefix, []string{owner})
+	balance, err := _readBalanceCount(ctx, owner)
 	if err != nil {
-		panic("Error creating asset chaincode:" + err.Error())
-	}
-
-	// Count the number of returned composite keys
-	balance := 0
-	for iterator.HasNext() {
-		_, err := iterator.Next()
-		if err != nil {
-			return 0
-		}
-		balance++
-
+		panic("failed to read balance count:" + err.Error())
 	}
 	return balance
 }
@@ -155,6 +144,15 @@ func (c *TokenERC721Contract) Approve(ctx contractapi.TransactionContextInterfac
 		return false, fmt.Errorf("the sender is not the current owner nor an authorized operator")
 	}
 
+	// Soulbound tokens cannot be approved, they can never be transferred
+	transferRestriction, err := _readTransferRestriction(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+	if transferRestriction != nil && transferRestriction.Restriction == RestrictionSoulbound {
+		return false, fmt.Errorf("the token %s is soulbound and cannot be approved", tokenId)
+	}
+
 	// Update the approved operator of the non-fungible token
 	nft.Approved = operator
 	nftKey, err := ctx.GetStub().CreateCompositeKey(nftPrefix, []string{tokenId})
@@ -340,6 +338,20 @@ func (c *TokenERC721Contract) TransferFrom(ctx contractapi.TransactionContextInt
 		return false, fmt.Errorf("the from is not the current owner")
 	}
 
+	// Check if the token is soulbound, or needs the approval of its issuer
+	err = _checkTransferRestriction(ctx, tokenId, to)
+	if err != nil {
+		return false, err
+	}
+
+	// Move the private attributes of the token to the collection of the new owner organization
+	if from != to {
+		err = _transferPrivateAttributes(ctx, nft, to)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	// Clear the approved client for this non-fungible token
 	nft.Approved = ""
 
@@ -381,6 +393,34 @@ func (c *TokenERC721Contract) TransferFrom(ctx contractapi.TransactionContextInt
 		return false, fmt.Errorf("failed to PutState balanceKeyTo %s: %v", balanceKeyTo, err)
 	}
 
+	// Move the token from the enumeration of the current owner to the new owner.
+	// A transfer to self leaves the enumeration unchanged.
+	if from != to {
+		// The user of a rented token loses its role when the token changes hands
+		err = _clearUser(ctx, tokenId)
+		if err != nil {
+			return false, err
+		}
+
+		indexed, err := _removeTokenFromOwnerEnumeration(ctx, from, tokenId)
+		if err != nil {
+			return false, fmt.Errorf("failed to remove token %s from the enumeration of %s: %v", tokenId, from, err)
+		}
+
+		// Tokens minted before the enumeration extension are indexed on their first transfer
+		if !indexed {
+			err = _addTokenToAllTokensEnumeration(ctx, tokenId)
+			if err != nil {
+				return false, fmt.Errorf("failed to add token %s to the enumeration of all tokens: %v", tokenId, err)
+			}
+		}
+
+		err = _addTokenToOwnerEnumeration(ctx, to, tokenId)
+		if err != nil {
+			return false, fmt.Errorf("failed to add token %s to the enumeration of %s: %v", tokenId, to, err)
+		}
+	}
+
 	// Emit the Transfer event
 	transferEvent := new(Transfer)
 	transferEvent.From = from
@@ -464,6 +504,19 @@ func (c *TokenERC721Contract) TokenURI(ctx contractapi.TransactionContextInterfa
 	if err != nil {
 		return "", fmt.Errorf("failed to get TokenURI: %v", err)
 	}
+
+	// A token minted without a URI but with on-chain metadata is fully on-chain,
+	// its URI embeds the metadata
+	if nft.TokenURI == "" {
+		tokenMetadata, err := _readTokenMetadata(ctx, tokenId)
+		if err != nil {
+			return "", fmt.Errorf("failed to get TokenURI: %v", err)
+		}
+		if tokenMetadata != nil {
+			return "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(tokenMetadata.Metadata)), nil
+		}
+	}
+
 	return nft.TokenURI, nil
 }
 
@@ -485,23 +538,11 @@ func (c *TokenERC721Contract) TotalSupply(ctx contractapi.TransactionContextInte
 		panic("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
-	// There is a key record for every non-fungible token in the format of nftPrefix.tokenId.
-	// TotalSupply() queries for and counts all records matching nftPrefix.*
+	// The number of tokens is counted in totalSupplyKey when tokens are minted and burned
 
-	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(nftPrefix, []string{})
+	totalSupply, err := _readTotalSupply(ctx)
 	if err != nil {
-		panic("Error creating GetStateByPartialCompositeKey:" + err.Error())
-	}
-	// Count the number of returned composite keys
-
-	totalSupply := 0
-	for iterator.HasNext() {
-		_, err := iterator.Next()
-		if err != nil {
-			return 0
-		}
-		totalSupply++
-
+		panic("failed to read total supply:" + err.Error())
 	}
 	return totalSupply
 
@@ -513,6 +554,12 @@ func (c *TokenERC721Contract) TotalSupply(ctx contractapi.TransactionContextInte
 // param {String} symbol The symbol of the token
 
 func (c *TokenERC721Contract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string) (bool, error) {
+	return _initialize(ctx, name, symbol)
+}
+
+// _initialize sets the name and symbol of the token
+// Dependant functions include Initialize and InitializeWithMetadataSchema
+func _initialize(ctx contractapi.TransactionContextInterface, name string, symbol string) (bool, error) {
 	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to set the name and symbol
 	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
@@ -559,38 +606,61 @@ func (c *TokenERC721Contract) MintWithTokenURI(ctx contractapi.TransactionContex
 		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
+	return _mint(ctx, tokenId, tokenURI)
+}
+
+// _mint creates a non-fungible token owned by the calling minter
+// Dependant functions include MintWithTokenURI
+func _mint(ctx contractapi.TransactionContextInterface, tokenId string, tokenURI string) (*Nft, error) {
+	minter, err := _authorizeMinter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return _mintTo(ctx, minter, tokenId, tokenURI)
+}
+
+// _authorizeMinter checks that the calling client is authorized to mint and returns its decoded client ID
+func _authorizeMinter(ctx contractapi.TransactionContextInterface) (string, error) {
+
 	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to mint a new token
 	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clientMSPID: %v", err)
+		return "", fmt.Errorf("failed to get clientMSPID: %v", err)
 	}
 
 	if clientMSPID != "Org1MSP" {
-		return nil, fmt.Errorf("client is not authorized to set the name and symbol of the token")
+		return "", fmt.Errorf("client is not authorized to set the name and symbol of the token")
 	}
 
 	// Get ID of submitting client identity
 	minter64, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get minter id: %v", err)
+		return "", fmt.Errorf("failed to get minter id: %v", err)
 	}
 
 	minterBytes, err := base64.StdEncoding.DecodeString(minter64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to DecodeString minter64: %v", err)
+		return "", fmt.Errorf("failed to DecodeString minter64: %v", err)
 	}
-	minter := string(minterBytes)
+
+	return string(minterBytes), nil
+}
+
+// _mintTo creates a non-fungible token owned by owner, the caller is responsible for the minter authorization
+// Dependant functions include _mint, _mintWithOptions and RedeemVoucher
+func _mintTo(ctx contractapi.TransactionContextInterface, owner string, tokenId string, tokenURI string) (*Nft, error) {
 
 	// Check if the token to be minted does not exist
 	exists := _nftExists(ctx, tokenId)
 	if exists {
-		return nil, fmt.Errorf("the token %s is already minted.: %v", tokenId, err)
+		return nil, fmt.Errorf("the token %s is already minted", tokenId)
 	}
 
 	// Add a non-fungible token
 	nft := new(Nft)
 	nft.TokenId = tokenId
-	nft.Owner = minter
+	nft.Owner = owner
 	nft.TokenURI = tokenURI
 
 	nftKey, err := ctx.GetStub().CreateCompositeKey(nftPrefix, []string{tokenId})
@@ -609,10 +679,10 @@ func (c *TokenERC721Contract) MintWithTokenURI(ctx contractapi.TransactionContex
 	}
 
 	// A composite key would be balancePrefix.owner.tokenId, which enables partial
-	// composite key query to find and count all records matching balance.owner.*
+	// composite key query to find all records matching balance.owner.*
 	// An empty value would represent a delete, so we simply insert the null character.
 
-	balanceKey, err := ctx.GetStub().CreateCompositeKey(balancePrefix, []string{minter, tokenId})
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(balancePrefix, []string{owner, tokenId})
 	if err != nil {
 		return nil, fmt.Errorf("failed to CreateCompositeKey to balanceKey: %v", err)
 	}
@@ -622,10 +692,21 @@ func (c *TokenERC721Contract) MintWithTokenURI(ctx contractapi.TransactionContex
 		return nil, fmt.Errorf("failed to PutState balanceKey %s: %v", nftBytes, err)
 	}
 
+	// Add the token to the enumerations, which also maintain the counts of BalanceOf() and TotalSupply()
+	err = _addTokenToOwnerEnumeration(ctx, owner, tokenId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add token %s to the enumeration of %s: %v", tokenId, owner, err)
+	}
+
+	err = _addTokenToAllTokensEnumeration(ctx, tokenId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add token %s to the enumeration of all tokens: %v", tokenId, err)
+	}
+
 	// Emit the Transfer event
 	transferEvent := new(Transfer)
 	transferEvent.From = "0x0"
-	transferEvent.To = minter
+	transferEvent.To = owner
 	transferEvent.TokenId = tokenId
 
 	transferEventBytes, err := json.Marshal(transferEvent)
@@ -675,6 +756,19 @@ func (c *TokenERC721Contract) Burn(ctx contractapi.TransactionContextInterface,
 		return false, fmt.Errorf("non-fungible token %s is not owned by %s", tokenId, owner)
 	}
 
+	return _burn(ctx, owner, tokenId)
+}
+
+// _burn deletes a non-fungible token of owner, the caller is responsible for the authorization
+// Dependant functions include Burn and Revoke
+func _burn(ctx contractapi.TransactionContextInterface, owner string, tokenId string) (bool, error) {
+
+	// Delete the private attributes of the token
+	err := _deletePrivateAttributes(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
 	// Delete the token
 	nftKey, err := ctx.GetStub().CreateCompositeKey(nftPrefix, []string{tokenId})
 	if err != nil {
@@ -697,6 +791,66 @@ func (c *TokenERC721Contract) Burn(ctx contractapi.TransactionContextInterface,
 		return false, fmt.Errorf("failed to DelState balanceKey %s: %v", balanceKey, err)
 	}
 
+	// Remove the token from the enumerations, tokens minted before the enumeration extension were never added
+	_, err = _removeTokenFromOwnerEnumeration(ctx, owner, tokenId)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove token %s from the enumeration of %s: %v", tokenId, owner, err)
+	}
+
+	_, err = _removeTokenFromAllTokensEnumeration(ctx, tokenId)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove token %s from the enumeration of all tokens: %v", tokenId, err)
+	}
+
+	// Remove the user of the token
+	err = _clearUser(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	// Remove the royalty of the token
+	royaltyKey, err := ctx.GetStub().CreateCompositeKey(royaltyPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey royaltyKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(royaltyKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState royaltyKey %s: %v", royaltyKey, err)
+	}
+
+	// Remove the on-chain metadata of the token
+	metadataKey, err := ctx.GetStub().CreateCompositeKey(metadataPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey metadataKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(metadataKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState metadataKey %s: %v", metadataKey, err)
+	}
+
+	// Remove the transfer restriction of the token
+	transferRestrictionKey, err := ctx.GetStub().CreateCompositeKey(transferRestrictionPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey transferRestrictionKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(transferRestrictionKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState transferRestrictionKey %s: %v", transferRestrictionKey, err)
+	}
+
+	issuerApprovalKey, err := ctx.GetStub().CreateCompositeKey(issuerApprovalPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey issuerApprovalKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(issuerApprovalKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState issuerApprovalKey %s: %v", issuerApprovalKey, err)
+	}
+
 	// Emit the Transfer event
 	transferEvent := new(Transfer)
 	transferEvent.From = owner
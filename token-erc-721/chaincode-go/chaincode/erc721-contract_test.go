@@ -1,20 +1,34 @@
 package chaincode
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const owner = "x509::CN=minter,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
 const operator = "x509::CN=org,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=AR"
+const artist = "x509::CN=artist,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
 
 type MockStub struct {
 	shim.ChaincodeStubInterface
@@ -26,6 +40,11 @@ func (ms *MockStub) GetStateByPartialCompositeKey(objectType string, keys []stri
 	return args.Get(0).(shim.StateQueryIteratorInterface), args.Error(1)
 }
 
+func (ms *MockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	args := ms.Called(objectType, keys, pageSize, bookmark)
+	return args.Get(0).(shim.StateQueryIteratorInterface), args.Get(1).(*peer.QueryResponseMetadata), args.Error(2)
+}
+
 func (ms *MockStub) GetState(key string) ([]byte, error) {
 	args := ms.Called(key)
 	return args.Get(0).([]byte), args.Error(1)
@@ -40,11 +59,46 @@ func (ms *MockStub) SetEvent(key string, value []byte) error {
 	return args.Error(0)
 }
 
+func (ms *MockStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	callArgs := ms.Called(chaincodeName, args, channel)
+	return callArgs.Get(0).(peer.Response)
+}
+
 func (ms *MockStub) DelState(key string) error {
 	args := ms.Called(key)
 	return args.Error(0)
 }
 
+func (ms *MockStub) GetChannelID() string {
+	args := ms.Called()
+	return args.String(0)
+}
+
+func (ms *MockStub) GetTxID() string {
+	args := ms.Called()
+	return args.String(0)
+}
+
+func (ms *MockStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	args := ms.Called()
+	return args.Get(0).(*timestamppb.Timestamp), args.Error(1)
+}
+
+func (ms *MockStub) GetTransient() (map[string][]byte, error) {
+	args := ms.Called()
+	return args.Get(0).(map[string][]byte), args.Error(1)
+}
+
+func (ms *MockStub) GetPrivateDataHash(collection string, key string) ([]byte, error) {
+	args := ms.Called(collection, key)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (ms *MockStub) PutPrivateData(collection string, key string, value []byte) error {
+	args := ms.Called(collection, key, value)
+	return args.Error(0)
+}
+
 func (ms *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
 	args := ms.Called(objectType, attributes)
 	return args.Get(0).(string), args.Error(1)
@@ -65,6 +119,11 @@ func (mci *MockClientIdentity) GetMSPID() (string, error) {
 	return args.Get(0).(string), args.Error(1)
 }
 
+func (mci *MockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	args := mci.Called()
+	return args.Get(0).(*x509.Certificate), args.Error(1)
+}
+
 func (mc *MockContext) GetStub() shim.ChaincodeStubInterface {
 	args := mc.Called()
 	return args.Get(0).(*MockStub)
@@ -89,14 +148,38 @@ func (it *MockIterator) HasNext() bool {
 	return false
 }
 
+func (it *MockIterator) Close() error {
+	return nil
+}
+
 func setupStub() (*MockContext, *MockStub) {
 	balancePrefix := "balance"
 	approvalPrefix := "approval"
 	nftPrefix := "nft"
+	balanceCountPrefix := "balanceCount"
+	ownedTokensPrefix := "ownedTokens"
+	ownedTokensIndexPrefix := "ownedTokensIndex"
+	allTokensPrefix := "allTokens"
+	allTokensIndexPrefix := "allTokensIndex"
+	receiverContractPrefix := "receiverContract"
+	royaltyPrefix := "royalty"
+	metadataPrefix := "metadata"
+	certificatePrefix := "certificate"
+	redeemedVoucherPrefix := "redeemedVoucher"
+	userInfoPrefix := "userInfo"
+	userTokensPrefix := "userTokens"
+	transferRestrictionPrefix := "transferRestriction"
+	issuerApprovalPrefix := "issuerApproval"
+	privateAttributesPrefix := "privateAttributes"
+	privateAttributesStr := "{\"parcel\":\"A-12\",\"appraisal\":350000}"
+	privateAttributesSalt := "0123456789abcdef0123456789abcdef"
+	privateAttributesHash := sha256.Sum256([]byte(privateAttributesSalt + privateAttributesStr))
+	schemaStr := "{\"type\":\"object\",\"required\":[\"name\"],\"properties\":{\"name\":{\"type\":\"string\",\"minLength\":1}}}"
 	mockTokenId := "101"
 	anyString := mock.AnythingOfType("string")
 	anyUint8Slice := mock.AnythingOfType("[]uint8")
 	nftStr := "{\"tokenId\":\"101\",\"owner\":\"" + owner + "\",\"tokenURI\":\"https://example.com/nft101.json\",\"approved\":\"" + operator + "\"}"
+	userInfoStr := "{\"tokenId\":\"101\",\"user\":\"" + operator + "\",\"expires\":1700000100}"
 	approvalStr := "{\"owner\":\"" + owner + "\",\"operator\":\"" + owner + "\",\"approved\":true}"
 
 	ms := new(MockStub)
@@ -104,20 +187,64 @@ func setupStub() (*MockContext, *MockStub) {
 
 	ms.On("GetStateByPartialCompositeKey", balancePrefix, []string{owner}).Return(iterator, nil)
 	ms.On("GetStateByPartialCompositeKey", nftPrefix, []string{}).Return(iterator, nil)
+	ms.On("GetStateByPartialCompositeKey", userTokensPrefix, []string{operator}).Return(iterator, nil)
+	ms.On("GetStateByPartialCompositeKeyWithPagination", balancePrefix, []string{owner}, int32(10), "").Return(iterator, &peer.QueryResponseMetadata{}, nil)
+	ms.On("GetStateByPartialCompositeKeyWithPagination", nftPrefix, []string{}, int32(10), "").Return(iterator, &peer.QueryResponseMetadata{}, nil)
 
 	ms.On("CreateCompositeKey", nftPrefix, []string{mockTokenId}).Return("nft101", nil)
 	ms.On("CreateCompositeKey", nftPrefix, []string{"102"}).Return("nft102", nil)
+	ms.On("CreateCompositeKey", nftPrefix, []string{"103"}).Return("nft103", nil)
 	ms.On("CreateCompositeKey", approvalPrefix, []string{owner, owner}).Return(approvalPrefix+owner+owner, nil)
 	ms.On("CreateCompositeKey", approvalPrefix, []string{owner, operator}).Return(approvalPrefix+owner+operator, nil)
 	ms.On("CreateCompositeKey", balancePrefix, []string{owner, mockTokenId}).Return(balancePrefix+owner+mockTokenId, nil)
 	ms.On("CreateCompositeKey", balancePrefix, []string{operator, mockTokenId}).Return(balancePrefix+operator+mockTokenId, nil)
 	ms.On("CreateCompositeKey", balancePrefix, []string{owner, "102"}).Return(balancePrefix+owner+mockTokenId, nil)
+	ms.On("CreateCompositeKey", balancePrefix, []string{operator, "102"}).Return(balancePrefix+operator+"102", nil)
+	ms.On("CreateCompositeKey", balanceCountPrefix, []string{owner}).Return(balanceCountPrefix+owner, nil)
+	ms.On("CreateCompositeKey", balanceCountPrefix, []string{operator}).Return(balanceCountPrefix+operator, nil)
+	ms.On("CreateCompositeKey", ownedTokensPrefix, []string{owner, "0"}).Return(ownedTokensPrefix+owner+"0", nil)
+	ms.On("CreateCompositeKey", ownedTokensPrefix, []string{operator, "0"}).Return(ownedTokensPrefix+operator+"0", nil)
+	ms.On("CreateCompositeKey", ownedTokensIndexPrefix, []string{mockTokenId}).Return(ownedTokensIndexPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", ownedTokensIndexPrefix, []string{"102"}).Return(ownedTokensIndexPrefix+"102", nil)
+	ms.On("CreateCompositeKey", allTokensPrefix, []string{"0"}).Return(allTokensPrefix+"0", nil)
+	ms.On("CreateCompositeKey", allTokensIndexPrefix, []string{mockTokenId}).Return(allTokensIndexPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", allTokensIndexPrefix, []string{"102"}).Return(allTokensIndexPrefix+"102", nil)
+	ms.On("CreateCompositeKey", receiverContractPrefix, []string{operator}).Return(receiverContractPrefix+operator, nil)
+	ms.On("CreateCompositeKey", royaltyPrefix, []string{mockTokenId}).Return(royaltyPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", royaltyPrefix, []string{"102"}).Return(royaltyPrefix+"102", nil)
+	ms.On("CreateCompositeKey", metadataPrefix, []string{mockTokenId}).Return(metadataPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", metadataPrefix, []string{"102"}).Return(metadataPrefix+"102", nil)
+	ms.On("CreateCompositeKey", certificatePrefix, []string{artist}).Return(certificatePrefix+artist, nil)
+	ms.On("CreateCompositeKey", redeemedVoucherPrefix, mock.Anything).Return(redeemedVoucherPrefix, nil)
+	ms.On("CreateCompositeKey", userInfoPrefix, []string{mockTokenId}).Return(userInfoPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", userInfoPrefix, []string{"102"}).Return(userInfoPrefix+"102", nil)
+	ms.On("CreateCompositeKey", transferRestrictionPrefix, []string{mockTokenId}).Return(transferRestrictionPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", transferRestrictionPrefix, []string{"102"}).Return(transferRestrictionPrefix+"102", nil)
+	ms.On("CreateCompositeKey", issuerApprovalPrefix, []string{mockTokenId}).Return(issuerApprovalPrefix+mockTokenId, nil)
+	ms.On("CreateCompositeKey", privateAttributesPrefix, []string{"102"}).Return(privateAttributesPrefix+"102", nil)
+	ms.On("CreateCompositeKey", privateAttributesPrefix, []string{"103"}).Return(privateAttributesPrefix+"103", nil)
+	ms.On("CreateCompositeKey", userTokensPrefix, []string{operator, mockTokenId}).Return(userTokensPrefix+operator+mockTokenId, nil)
 
 	ms.On("GetState", "nft101").Return([]byte(nftStr), nil)
 	ms.On("GetState", "nft102").Return([]uint8{}, nil)
+	ms.On("GetState", "nft103").Return([]byte("{\"tokenId\":\"103\",\"owner\":\""+owner+"\",\"tokenURI\":\"\",\"approved\":\"\",\"privateAttributesHash\":\""+hex.EncodeToString(privateAttributesHash[:])+"\",\"privateAttributesOrg\":\"Org1MSP\"}"), nil)
 	ms.On("GetState", approvalPrefix+owner+owner).Return([]byte(approvalStr), nil)
 	ms.On("GetState", "name").Return([]byte("lala"), nil)
 	ms.On("GetState", "symbol").Return([]byte("lelo"), nil)
+	ms.On("GetState", "totalSupply").Return([]byte(nil), nil)
+	ms.On("GetState", balanceCountPrefix+owner).Return([]byte(nil), nil)
+	ms.On("GetState", balanceCountPrefix+operator).Return([]byte(nil), nil)
+	ms.On("GetState", ownedTokensIndexPrefix+mockTokenId).Return([]byte(nil), nil)
+	ms.On("GetState", allTokensIndexPrefix+mockTokenId).Return([]byte(nil), nil)
+	ms.On("GetState", receiverContractPrefix+operator).Return([]byte("receiver"), nil)
+	ms.On("GetState", royaltyPrefix+mockTokenId).Return([]byte(nil), nil)
+	ms.On("GetState", "defaultRoyalty").Return([]byte("{\"receiver\":\""+artist+"\",\"feeNumerator\":250}"), nil)
+	ms.On("GetState", "paymentChaincode").Return([]byte("erc20"), nil)
+	ms.On("GetState", "metadataSchema").Return([]byte(schemaStr), nil)
+	ms.On("GetState", metadataPrefix+mockTokenId).Return([]byte("{\"tokenId\":\"101\",\"minter\":\""+owner+"\",\"metadata\":\"{}\",\"frozen\":false}"), nil)
+	ms.On("GetState", redeemedVoucherPrefix).Return([]byte(nil), nil)
+	ms.On("GetState", transferRestrictionPrefix+mockTokenId).Return([]byte(nil), nil)
+	ms.On("GetState", userInfoPrefix+mockTokenId).Return([]byte(userInfoStr), nil)
 
 	ms.On("PutState", "name", []byte("someName")).Return(nil)
 	ms.On("PutState", "symbol", []byte("someSymbol")).Return(nil)
@@ -129,9 +256,22 @@ func setupStub() (*MockContext, *MockStub) {
 
 	ms.On("SetEvent", "ApprovalForAll", anyUint8Slice).Return(nil)
 	ms.On("SetEvent", "Transfer", anyUint8Slice).Return(nil)
+	ms.On("SetEvent", "UpdateUser", anyUint8Slice).Return(nil)
+	ms.On("SetEvent", "MetadataUpdate", anyUint8Slice).Return(nil)
 
 	ms.On("DelState", anyString).Return(nil)
 
+	ms.On("InvokeChaincode", "receiver", mock.Anything, "").Return(shim.Success([]byte("0x150b7a02")))
+	ms.On("InvokeChaincode", "erc20", mock.Anything, "").Return(shim.Success(nil))
+
+	ms.On("GetTransient").Return(map[string][]byte{"private_attributes": []byte(privateAttributesStr), "private_attributes_salt": []byte(privateAttributesSalt)}, nil)
+	ms.On("GetPrivateDataHash", "_implicit_org_Org1MSP", privateAttributesPrefix+"103").Return(privateAttributesHash[:], nil)
+	ms.On("PutPrivateData", anyString, anyString, anyUint8Slice).Return(nil)
+
+	ms.On("GetChannelID").Return("mychannel")
+	ms.On("GetTxID").Return("tx1")
+	ms.On("GetTxTimestamp").Return(&timestamppb.Timestamp{Seconds: 1700000000}, nil)
+
 	mci := new(MockClientIdentity)
 	owner64 := base64.StdEncoding.EncodeToString([]byte(owner))
 	operator64 := base64.StdEncoding.EncodeToString([]byte(owner))
@@ -162,6 +302,50 @@ func TestTotalSupply(t *testing.T) {
 
 }
 
+func TestTokenByIndex(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	_, err := c.TokenByIndex(ctx, 0)
+	assert.EqualError(t, err, "index 0 is out of bounds, the total supply is 0")
+}
+
+func TestTokenOfOwnerByIndex(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	_, err := c.TokenOfOwnerByIndex(ctx, owner, 0)
+	assert.EqualError(t, err, "index 0 is out of bounds, the balance of "+owner+" is 0")
+}
+
+func TestTokensOfOwner(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	tokens, _ := c.TokensOfOwner(ctx, owner, 10, "")
+	assert.Equal(t, []string{}, tokens.Records)
+}
+
+func TestAllTokens(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	tokens, _ := c.AllTokens(ctx, 10, "")
+	assert.Equal(t, []string{}, tokens.Records)
+}
+
+func TestIndexTokens(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	indexed, _ := c.IndexTokens(ctx, []string{"101", "101"})
+	assert.Equal(t, 1, indexed)
+	ms.AssertCalled(t, "PutState", "allTokens0", []byte("101"))
+	ms.AssertCalled(t, "PutState", "ownedTokens"+owner+"0", []byte("101"))
+	ms.AssertCalled(t, "PutState", "totalSupply", []byte("1"))
+	ms.AssertCalled(t, "PutState", "balanceCount"+owner, []byte("1"))
+}
+
 func TestOwnerOf(t *testing.T) {
 	ctx, _ := setupStub()
 	c := new(TokenERC721Contract)
@@ -215,6 +399,288 @@ func TestTransferFrom(t *testing.T) {
 	assert.Equal(t, true, transfer)
 }
 
+func TestSafeTransferFrom(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	transfer, _ := c.SafeTransferFrom(ctx, owner, operator, "101", "data")
+
+	assert.Equal(t, true, transfer)
+	ms.AssertCalled(t, "InvokeChaincode", "receiver", [][]byte{[]byte("OnERC721Received"), []byte(owner), []byte(owner), []byte("101"), []byte("data")}, "")
+}
+
+func TestRegisterReceiverContract(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	registered, _ := c.RegisterReceiverContract(ctx, operator, "receiver")
+	assert.Equal(t, true, registered)
+}
+
+func TestGetReceiverContract(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	receiver, _ := c.GetReceiverContract(ctx, operator)
+	assert.Equal(t, "receiver", receiver)
+}
+
+func TestMintWithRoyalty(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	mint, _ := c.MintWithRoyalty(ctx, "102", "https://example.com/nft102.json", artist, 500)
+
+	nft := new(Nft)
+	nft.Owner = owner
+	nft.TokenId = "102"
+	nft.TokenURI = "https://example.com/nft102.json"
+
+	assert.Equal(t, nft, mint)
+
+	_, err := c.MintWithRoyalty(ctx, "102", "https://example.com/nft102.json", artist, 10001)
+	assert.EqualError(t, err, "royalty fee 10001 must be between 0 and 10000 basis points")
+}
+
+func TestSetDefaultRoyalty(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	set, _ := c.SetDefaultRoyalty(ctx, artist, 250)
+	assert.Equal(t, true, set)
+}
+
+func TestRoyaltyInfo(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	royalty, _ := c.RoyaltyInfo(ctx, "101", "1000")
+	assert.Equal(t, &RoyaltyPayment{Receiver: artist, RoyaltyAmount: "25"}, royalty)
+}
+
+func TestTransferWithPayment(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	transfer, _ := c.TransferWithPayment(ctx, owner, operator, "101", "1000")
+	assert.Equal(t, true, transfer)
+
+	operator64 := base64.StdEncoding.EncodeToString([]byte(operator))
+	recipients := "[\"" + base64.StdEncoding.EncodeToString([]byte(artist)) + "\",\"" + base64.StdEncoding.EncodeToString([]byte(owner)) + "\"]"
+	ms.AssertCalled(t, "InvokeChaincode", "erc20", [][]byte{[]byte("BatchTransferFrom"), []byte(operator64), []byte(recipients), []byte("[\"25\",\"975\"]")}, "")
+}
+
+func TestMintWithMetadata(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	mint, _ := c.MintWithMetadata(ctx, "102", "", "{\"name\": \"Token 102\"}")
+
+	nft := new(Nft)
+	nft.Owner = owner
+	nft.TokenId = "102"
+
+	assert.Equal(t, nft, mint)
+
+	_, err := c.MintWithMetadata(ctx, "102", "", "{\"name\": \"\"}")
+	assert.EqualError(t, err, "metadata is not valid against the metadata schema: #/name must be at least 1 characters long")
+}
+
+func TestGetMetadataSchema(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	schema, _ := c.GetMetadataSchema(ctx)
+	assert.Equal(t, "{\"type\":\"object\",\"required\":[\"name\"],\"properties\":{\"name\":{\"type\":\"string\",\"minLength\":1}}}", schema)
+}
+
+func TestSetMetadataSchema(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	_, err := c.SetMetadataSchema(ctx, "{\"type\":\"object\"}")
+	assert.EqualError(t, err, "the metadata schema is already set")
+}
+
+func TestFreezeMetadata(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	frozen, _ := c.FreezeMetadata(ctx, "101")
+	assert.Equal(t, true, frozen)
+	ms.AssertCalled(t, "PutState", "metadata101", []byte("{\"tokenId\":\"101\",\"minter\":\""+owner+"\",\"metadata\":\"{}\",\"frozen\":true}"))
+	ms.AssertCalled(t, "SetEvent", "MetadataUpdate", []byte("{\"tokenId\":\"101\"}"))
+}
+
+func TestRedeemVoucher(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	artistKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "artist"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	certDER, _ := x509.CreateCertificate(rand.Reader, template, template, &artistKey.PublicKey, artistKey)
+	ms.On("GetState", "certificate"+artist).Return(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), nil)
+
+	voucher := "{\"channel\":\"mychannel\",\"minter\":\"" + artist + "\",\"tokenId\":\"102\",\"tokenURI\":\"https://example.com/nft102.json\",\"minPrice\":\"500\",\"expiry\":1700000100}"
+	digest := sha256.Sum256([]byte(voucher))
+	signatureBytes, _ := ecdsa.SignASN1(rand.Reader, artistKey, digest[:])
+	signature := base64.StdEncoding.EncodeToString(signatureBytes)
+
+	mint, _ := c.RedeemVoucher(ctx, voucher, signature)
+
+	nft := new(Nft)
+	nft.Owner = owner
+	nft.TokenId = "102"
+	nft.TokenURI = "https://example.com/nft102.json"
+
+	assert.Equal(t, nft, mint)
+
+	artist64 := base64.StdEncoding.EncodeToString([]byte(artist))
+	ms.AssertCalled(t, "InvokeChaincode", "erc20", [][]byte{[]byte("Transfer"), []byte(artist64), []byte("500")}, "")
+
+	_, err := c.RedeemVoucher(ctx, strings.Replace(voucher, "500", "5", 1), signature)
+	assert.EqualError(t, err, "invalid signature of minter "+artist)
+}
+
+func TestVoucherRedeemed(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	redeemed, _ := c.VoucherRedeemed(ctx, "{\"tokenId\":\"102\"}")
+	assert.Equal(t, false, redeemed)
+}
+
+func TestSetUser(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	set, _ := c.SetUser(ctx, "101", operator, 1700000200)
+	assert.Equal(t, true, set)
+
+	ms.AssertCalled(t, "DelState", "userTokens"+operator+"101")
+	ms.AssertCalled(t, "PutState", "userInfo101", []byte("{\"tokenId\":\"101\",\"user\":\""+operator+"\",\"expires\":1700000200}"))
+}
+
+func TestUserOf(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	user, _ := c.UserOf(ctx, "101")
+	assert.Equal(t, operator, user)
+}
+
+func TestUserExpires(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	expires, _ := c.UserExpires(ctx, "101")
+	assert.Equal(t, int64(1700000100), expires)
+}
+
+func TestTokensOfUser(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	tokenIds, _ := c.TokensOfUser(ctx, operator)
+	assert.Equal(t, []string{}, tokenIds)
+}
+
+func TestMintWithTransferRestriction(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	mint, _ := c.MintWithTransferRestriction(ctx, operator, "102", "https://example.com/nft102.json", "soulbound")
+
+	nft := new(Nft)
+	nft.Owner = operator
+	nft.TokenId = "102"
+	nft.TokenURI = "https://example.com/nft102.json"
+
+	assert.Equal(t, nft, mint)
+	ms.AssertCalled(t, "PutState", "transferRestriction102", []byte("{\"tokenId\":\"102\",\"issuer\":\""+owner+"\",\"restriction\":\"soulbound\"}"))
+
+	_, err := c.MintWithTransferRestriction(ctx, operator, "102", "https://example.com/nft102.json", "locked")
+	assert.EqualError(t, err, "invalid transfer restriction \"locked\", it should be soulbound or issuerApproved")
+}
+
+func TestMintWithOptions(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	options := "{\"transferRestriction\":\"soulbound\",\"royalty\":{\"receiver\":\"" + artist + "\",\"feeNumerator\":500},\"metadata\":{\"name\":\"Token 102\"}}"
+	mint, _ := c.MintWithOptions(ctx, operator, "102", "", options)
+
+	nft := new(Nft)
+	nft.Owner = operator
+	nft.TokenId = "102"
+
+	assert.Equal(t, nft, mint)
+	ms.AssertCalled(t, "PutState", "transferRestriction102", []byte("{\"tokenId\":\"102\",\"issuer\":\""+owner+"\",\"restriction\":\"soulbound\"}"))
+	ms.AssertCalled(t, "PutState", "royalty102", []byte("{\"receiver\":\""+artist+"\",\"feeNumerator\":500}"))
+	ms.AssertCalled(t, "PutState", "metadata102", []byte("{\"tokenId\":\"102\",\"minter\":\""+owner+"\",\"metadata\":\"{\\\"name\\\":\\\"Token 102\\\"}\",\"frozen\":false}"))
+
+	_, err := c.MintWithOptions(ctx, operator, "102", "", "{\"soulbound\":true}")
+	assert.EqualError(t, err, "failed to unmarshal mint options: json: unknown field \"soulbound\"")
+
+	t.Setenv("CORE_PEER_LOCALMSPID", "Org1MSP")
+	_, err = c.MintWithOptions(ctx, operator, "102", "", "{\"privateAttributes\":true}")
+	assert.EqualError(t, err, "a token with private attributes must be minted to the minter")
+}
+
+func TestTransferFromSoulbound(t *testing.T) {
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	// The holder of a soulbound token cannot pass it on
+	for _, call := range ms.ExpectedCalls {
+		if call.Method == "GetState" && call.Arguments[0] == "transferRestriction101" {
+			call.Return([]byte("{\"tokenId\":\"101\",\"issuer\":\""+artist+"\",\"restriction\":\"soulbound\"}"), nil)
+		}
+	}
+
+	_, err := c.TransferFrom(ctx, owner, operator, "101")
+	assert.EqualError(t, err, "the token 101 is soulbound and cannot be transferred")
+}
+
+func TestLocked(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	locked, _ := c.Locked(ctx, "101")
+	assert.Equal(t, false, locked)
+}
+
+func TestMintWithPrivateAttributes(t *testing.T) {
+	t.Setenv("CORE_PEER_LOCALMSPID", "Org1MSP")
+	ctx, ms := setupStub()
+	c := new(TokenERC721Contract)
+
+	mint, _ := c.MintWithPrivateAttributes(ctx, "102", "https://example.com/nft102.json")
+
+	nft := new(Nft)
+	nft.Owner = owner
+	nft.TokenId = "102"
+	nft.TokenURI = "https://example.com/nft102.json"
+	privateAttributesHash := sha256.Sum256([]byte("0123456789abcdef0123456789abcdef{\"parcel\":\"A-12\",\"appraisal\":350000}"))
+	nft.PrivateAttributesHash = hex.EncodeToString(privateAttributesHash[:])
+	nft.PrivateAttributesOrg = "Org1MSP"
+
+	assert.Equal(t, nft, mint)
+	ms.AssertCalled(t, "PutPrivateData", "_implicit_org_Org1MSP", "privateAttributes102", []byte("0123456789abcdef0123456789abcdef{\"parcel\":\"A-12\",\"appraisal\":350000}"))
+
+	t.Setenv("CORE_PEER_LOCALMSPID", "Org2MSP")
+	_, err := c.MintWithPrivateAttributes(ctx, "102", "https://example.com/nft102.json")
+	assert.EqualError(t, err, "client from org Org1MSP is not authorized to read or write private data from an org Org2MSP peer")
+}
+
+func TestVerifyPrivateAttributes(t *testing.T) {
+	ctx, _ := setupStub()
+	c := new(TokenERC721Contract)
+
+	verified, _ := c.VerifyPrivateAttributes(ctx, "103")
+	assert.Equal(t, true, verified)
+}
+
 func TestName(t *testing.T) {
 	ctx, _ := setupStub()
 	c := new(TokenERC721Contract)
@@ -10,6 +10,10 @@ type Nft struct {
 	Owner    string `json:"owner"`
 	TokenURI string `json:"tokenURI"`
 	Approved string `json:"approved"`
+
+	// The SHA-256 hash of the private attributes, and the organization whose implicit collection holds them
+	PrivateAttributesHash string `json:"privateAttributesHash,omitempty"`
+	PrivateAttributesOrg  string `json:"privateAttributesOrg,omitempty"`
 }
 
 type Approval struct {
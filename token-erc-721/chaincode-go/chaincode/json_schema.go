@@ -0,0 +1,375 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The metadata schema supports this subset of JSON Schema keywords, a schema using any other keyword is rejected
+// so that it is never silently ignored. Annotation keywords are allowed and have no effect.
+var schemaKeywords = map[string]bool{
+	"type":                 true,
+	"properties":           true,
+	"required":             true,
+	"additionalProperties": true,
+	"items":                true,
+	"minItems":             true,
+	"maxItems":             true,
+	"enum":                 true,
+	"minLength":            true,
+	"maxLength":            true,
+	"pattern":              true,
+	"minimum":              true,
+	"maximum":              true,
+}
+
+var schemaAnnotations = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+}
+
+var schemaTypes = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+// _decodeJSON decodes a JSON document keeping numbers as json.Number, so integers are not rounded
+func _decodeJSON(document string) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(document)))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+
+	return value, nil
+}
+
+// _parseSchema decodes a JSON schema and checks that it only uses supported keywords
+func _parseSchema(document string) (map[string]interface{}, error) {
+	value, err := _decodeJSON(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata schema: %v", err)
+	}
+
+	schema, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata schema must be a JSON object")
+	}
+
+	err = _checkSchema(schema, "#")
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func _checkSchema(schema map[string]interface{}, path string) error {
+	for _, keyword := range _sortedKeys(schema) {
+		value := schema[keyword]
+		if schemaAnnotations[keyword] {
+			continue
+		}
+		if !schemaKeywords[keyword] {
+			return fmt.Errorf("schema %s uses the unsupported keyword %q", path, keyword)
+		}
+
+		switch keyword {
+		case "type":
+			types, err := _schemaTypes(value)
+			if err != nil {
+				return fmt.Errorf("schema %s: %v", path, err)
+			}
+			for _, t := range types {
+				if !schemaTypes[t] {
+					return fmt.Errorf("schema %s has the unknown type %q", path, t)
+				}
+			}
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("schema %s: properties must be an object", path)
+			}
+			for _, name := range _sortedKeys(properties) {
+				property, ok := properties[name].(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("schema %s/properties/%s must be an object", path, name)
+				}
+				err := _checkSchema(property, path+"/properties/"+name)
+				if err != nil {
+					return err
+				}
+			}
+		case "required":
+			required, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("schema %s: required must be an array of strings", path)
+			}
+			for _, name := range required {
+				if _, ok := name.(string); !ok {
+					return fmt.Errorf("schema %s: required must be an array of strings", path)
+				}
+			}
+		case "additionalProperties":
+			if _, ok := value.(bool); ok {
+				continue
+			}
+			additional, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("schema %s: additionalProperties must be a boolean or an object", path)
+			}
+			err := _checkSchema(additional, path+"/additionalProperties")
+			if err != nil {
+				return err
+			}
+		case "items":
+			items, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("schema %s: items must be an object", path)
+			}
+			err := _checkSchema(items, path+"/items")
+			if err != nil {
+				return err
+			}
+		case "minItems", "maxItems", "minLength", "maxLength":
+			number, ok := value.(json.Number)
+			if !ok {
+				return fmt.Errorf("schema %s: %s must be a non-negative integer", path, keyword)
+			}
+			limit, err := strconv.Atoi(number.String())
+			if err != nil || limit < 0 {
+				return fmt.Errorf("schema %s: %s must be a non-negative integer", path, keyword)
+			}
+		case "minimum", "maximum":
+			number, ok := value.(json.Number)
+			if !ok {
+				return fmt.Errorf("schema %s: %s must be a number", path, keyword)
+			}
+			_, err := number.Float64()
+			if err != nil {
+				return fmt.Errorf("schema %s: %s must be a number", path, keyword)
+			}
+		case "enum":
+			if _, ok := value.([]interface{}); !ok {
+				return fmt.Errorf("schema %s: enum must be an array", path)
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("schema %s: pattern must be a string", path)
+			}
+			_, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("schema %s: invalid pattern %q: %v", path, pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// _validateJSON validates a decoded JSON value against a schema checked by _parseSchema
+func _validateJSON(value interface{}, schema map[string]interface{}, path string) error {
+	if typeValue, ok := schema["type"]; ok {
+		types, _ := _schemaTypes(typeValue)
+		matched := false
+		for _, t := range types {
+			if _jsonHasType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s must be of type %v", path, types)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		matched := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(value, allowed) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s must be one of the enum values", path)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					return fmt.Errorf("%s is missing the required property %q", path, name)
+				}
+			}
+		}
+
+		for _, name := range _sortedKeys(v) {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				err := _validateJSON(v[name], property, path+"/"+name)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s has the property %q that is not allowed", path, name)
+				}
+			case map[string]interface{}:
+				err := _validateJSON(v[name], additional, path+"/"+name)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+	case []interface{}:
+		if limit, ok := _schemaLimit(schema, "minItems"); ok && len(v) < limit {
+			return fmt.Errorf("%s must have at least %d items", path, limit)
+		}
+		if limit, ok := _schemaLimit(schema, "maxItems"); ok && len(v) > limit {
+			return fmt.Errorf("%s must have at most %d items", path, limit)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				err := _validateJSON(item, items, path+"/"+strconv.Itoa(i))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+	case string:
+		length := utf8.RuneCountInString(v)
+		if limit, ok := _schemaLimit(schema, "minLength"); ok && length < limit {
+			return fmt.Errorf("%s must be at least %d characters long", path, limit)
+		}
+		if limit, ok := _schemaLimit(schema, "maxLength"); ok && length > limit {
+			return fmt.Errorf("%s must be at most %d characters long", path, limit)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if !regexp.MustCompile(pattern).MatchString(v) {
+				return fmt.Errorf("%s must match the pattern %q", path, pattern)
+			}
+		}
+
+	case json.Number:
+		number, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%s is not a valid number", path)
+		}
+		if minimum, ok := schema["minimum"].(json.Number); ok {
+			limit, _ := minimum.Float64()
+			if number < limit {
+				return fmt.Errorf("%s must be at least %s", path, minimum)
+			}
+		}
+		if maximum, ok := schema["maximum"].(json.Number); ok {
+			limit, _ := maximum.Float64()
+			if number > limit {
+				return fmt.Errorf("%s must be at most %s", path, maximum)
+			}
+		}
+	}
+
+	return nil
+}
+
+// _schemaTypes returns the types allowed by the type keyword, which is a string or an array of strings
+func _schemaTypes(value interface{}) ([]string, error) {
+	switch t := value.(type) {
+	case string:
+		return []string{t}, nil
+	case []interface{}:
+		types := []string{}
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("type must be a string or an array of strings")
+			}
+			types = append(types, name)
+		}
+		return types, nil
+	}
+
+	return nil, fmt.Errorf("type must be a string or an array of strings")
+}
+
+func _schemaLimit(schema map[string]interface{}, keyword string) (int, bool) {
+	number, ok := schema[keyword].(json.Number)
+	if !ok {
+		return 0, false
+	}
+
+	limit, err := strconv.Atoi(number.String())
+	if err != nil {
+		return 0, false
+	}
+
+	return limit, true
+}
+
+func _jsonHasType(value interface{}, jsonType string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return jsonType == "object"
+	case []interface{}:
+		return jsonType == "array"
+	case string:
+		return jsonType == "string"
+	case bool:
+		return jsonType == "boolean"
+	case nil:
+		return jsonType == "null"
+	case json.Number:
+		if jsonType == "number" {
+			return true
+		}
+		if jsonType == "integer" {
+			_, err := strconv.ParseInt(v.String(), 10, 64)
+			return err == nil
+		}
+	}
+
+	return false
+}
+
+// _sortedKeys returns the keys of a JSON object in order. This is necessary because iterating maps in Go is not deterministic
+func _sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,388 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const metadataPrefix = "metadata"
+
+// Define key names for options
+const metadataSchemaKey = "metadataSchema"
+
+// TokenMetadata is the on-chain metadata of a non-fungible token
+// Metadata is a JSON object that is valid against the metadata schema of the collection
+type TokenMetadata struct {
+	TokenId  string `json:"tokenId"`
+	Minter   string `json:"minter"`
+	Metadata string `json:"metadata"`
+	Frozen   bool   `json:"frozen"`
+}
+
+// MetadataUpdate is emitted when the on-chain metadata of a token changes (EIP-4906)
+type MetadataUpdate struct {
+	TokenId string `json:"tokenId"`
+}
+
+// InitializeWithMetadataSchema sets the name and symbol of the token like Initialize,
+// along with the JSON schema that the on-chain metadata of every token must be valid against
+// param {String} name The name of the token
+// param {String} symbol The symbol of the token
+// param {String} schema The JSON schema of the metadata, see json_schema.go for the supported keywords
+func (c *TokenERC721Contract) InitializeWithMetadataSchema(ctx contractapi.TransactionContextInterface, name string, symbol string, schema string) (bool, error) {
+
+	// Check the schema before anything is written
+	_, err := _parseSchema(schema)
+	if err != nil {
+		return false, err
+	}
+
+	initialized, err := _initialize(ctx, name, symbol)
+	if err != nil {
+		return false, err
+	}
+
+	err = _putMetadataSchema(ctx, schema)
+	if err != nil {
+		return false, err
+	}
+
+	return initialized, nil
+}
+
+// SetMetadataSchema sets the JSON schema of the on-chain metadata of a contract that was initialized without one
+// The schema can be set only once, it applies to metadata minted or updated afterwards
+// param {String} schema The JSON schema of the metadata, see json_schema.go for the supported keywords
+// returns {Boolean} Return whether the schema was set or not
+func (c *TokenERC721Contract) SetMetadataSchema(ctx contractapi.TransactionContextInterface, schema string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to set the metadata schema
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to set the metadata schema")
+	}
+
+	schemaBytes, err := ctx.GetStub().GetState(metadataSchemaKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to GetState metadataSchemaKey: %v", err)
+	}
+	if len(schemaBytes) > 0 {
+		return false, fmt.Errorf("the metadata schema is already set")
+	}
+
+	_, err = _parseSchema(schema)
+	if err != nil {
+		return false, err
+	}
+
+	err = _putMetadataSchema(ctx, schema)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetMetadataSchema returns the JSON schema of the on-chain metadata
+// returns {String} Return the schema, or an empty string if the contract was initialized without a schema
+func (c *TokenERC721Contract) GetMetadataSchema(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	schemaBytes, err := ctx.GetStub().GetState(metadataSchemaKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to GetState metadataSchemaKey: %v", err)
+	}
+
+	return string(schemaBytes), nil
+}
+
+// MintWithMetadata mints a new non-fungible token like MintWithTokenURI, with on-chain metadata.
+// A token minted with an empty tokenURI is fully on-chain, TokenURI returns its metadata as a data URI.
+// param {String} tokenId Unique ID of the non-fungible token to be minted
+// param {String} tokenURI URI of off-chain metadata, or an empty string
+// param {String} metadata JSON object that is valid against the metadata schema
+// returns {Object} Return the non-fungible token object
+func (c *TokenERC721Contract) MintWithMetadata(ctx contractapi.TransactionContextInterface, tokenId string, tokenURI string, metadata string) (*Nft, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return _mintWithOptions(ctx, "", tokenId, tokenURI, &MintOptions{Metadata: json.RawMessage(metadata)})
+}
+
+// UpdateMetadata replaces the on-chain metadata of a token, only the minter of the token can update it until it is frozen
+// This function triggers a MetadataUpdate event
+// param {String} tokenId The non-fungible token to update
+// param {String} metadata JSON object that is valid against the metadata schema
+// returns {Boolean} Return whether the update was successful or not
+func (c *TokenERC721Contract) UpdateMetadata(ctx contractapi.TransactionContextInterface, tokenId string, metadata string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	tokenMetadata, err := _readMutableTokenMetadata(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	compactMetadata, err := _validateMetadata(ctx, metadata)
+	if err != nil {
+		return false, err
+	}
+
+	tokenMetadata.Metadata = compactMetadata
+	err = _putTokenMetadata(ctx, tokenMetadata)
+	if err != nil {
+		return false, err
+	}
+
+	err = _emitMetadataUpdate(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// FreezeMetadata makes the on-chain metadata of a token permanent, only the minter of the token can freeze it
+// The frozen flag is part of the metadata returned by GetMetadata, so freezing is announced like an update
+// This function triggers a MetadataUpdate event
+// param {String} tokenId The non-fungible token to freeze
+// returns {Boolean} Return whether the freeze was successful or not
+func (c *TokenERC721Contract) FreezeMetadata(ctx contractapi.TransactionContextInterface, tokenId string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	tokenMetadata, err := _readMutableTokenMetadata(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	tokenMetadata.Frozen = true
+	err = _putTokenMetadata(ctx, tokenMetadata)
+	if err != nil {
+		return false, err
+	}
+
+	err = _emitMetadataUpdate(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetMetadata returns the on-chain metadata of a token
+// param {String} tokenId The non-fungible token to query
+// returns {Object} Return the metadata, its minter and whether it is frozen
+func (c *TokenERC721Contract) GetMetadata(ctx contractapi.TransactionContextInterface, tokenId string) (*TokenMetadata, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	tokenMetadata, err := _readTokenMetadata(ctx, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	if tokenMetadata == nil {
+		return nil, fmt.Errorf("the token %s has no on-chain metadata", tokenId)
+	}
+
+	return tokenMetadata, nil
+}
+
+// Helper Functions
+
+// _putMetadataSchema stores the metadata schema in compact form
+func _putMetadataSchema(ctx contractapi.TransactionContextInterface, schema string) error {
+	compactSchema := new(bytes.Buffer)
+	err := json.Compact(compactSchema, []byte(schema))
+	if err != nil {
+		return fmt.Errorf("failed to compact metadata schema: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(metadataSchemaKey, compactSchema.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to PutState metadataSchemaKey: %v", err)
+	}
+
+	return nil
+}
+
+// _validateMetadata checks metadata against the metadata schema and returns it in compact form
+// Metadata must be a JSON object, also when the contract was initialized without a schema
+func _validateMetadata(ctx contractapi.TransactionContextInterface, metadata string) (string, error) {
+	value, err := _decodeJSON(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode metadata: %v", err)
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return "", fmt.Errorf("metadata must be a JSON object")
+	}
+
+	schemaBytes, err := ctx.GetStub().GetState(metadataSchemaKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to GetState metadataSchemaKey: %v", err)
+	}
+	if len(schemaBytes) > 0 {
+		schema, err := _parseSchema(string(schemaBytes))
+		if err != nil {
+			return "", err
+		}
+
+		err = _validateJSON(value, schema, "#")
+		if err != nil {
+			return "", fmt.Errorf("metadata is not valid against the metadata schema: %v", err)
+		}
+	}
+
+	compactMetadata := new(bytes.Buffer)
+	err = json.Compact(compactMetadata, []byte(metadata))
+	if err != nil {
+		return "", fmt.Errorf("failed to compact metadata: %v", err)
+	}
+
+	return compactMetadata.String(), nil
+}
+
+// _readTokenMetadata returns the on-chain metadata of a token, nil if it has none
+func _readTokenMetadata(ctx contractapi.TransactionContextInterface, tokenId string) (*TokenMetadata, error) {
+	metadataKey, err := ctx.GetStub().CreateCompositeKey(metadataPrefix, []string{tokenId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to CreateCompositeKey metadataKey: %v", err)
+	}
+
+	metadataBytes, err := ctx.GetStub().GetState(metadataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetState metadataKey %s: %v", metadataKey, err)
+	}
+	if len(metadataBytes) == 0 {
+		return nil, nil
+	}
+
+	tokenMetadata := new(TokenMetadata)
+	err = json.Unmarshal(metadataBytes, tokenMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal metadataBytes: %v", err)
+	}
+
+	return tokenMetadata, nil
+}
+
+// _readMutableTokenMetadata returns the on-chain metadata of a token after checking
+// that the calling client is its minter and that it is not frozen
+func _readMutableTokenMetadata(ctx contractapi.TransactionContextInterface, tokenId string) (*TokenMetadata, error) {
+	sender64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	senderBytes, err := base64.StdEncoding.DecodeString(sender64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to DecodeString sender: %v", err)
+	}
+	sender := string(senderBytes)
+
+	tokenMetadata, err := _readTokenMetadata(ctx, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	if tokenMetadata == nil {
+		return nil, fmt.Errorf("the token %s has no on-chain metadata", tokenId)
+	}
+	if tokenMetadata.Minter != sender {
+		return nil, fmt.Errorf("only the minter of token %s can change its metadata", tokenId)
+	}
+	if tokenMetadata.Frozen {
+		return nil, fmt.Errorf("the metadata of token %s is frozen", tokenId)
+	}
+
+	return tokenMetadata, nil
+}
+
+func _putTokenMetadata(ctx contractapi.TransactionContextInterface, tokenMetadata *TokenMetadata) error {
+	metadataKey, err := ctx.GetStub().CreateCompositeKey(metadataPrefix, []string{tokenMetadata.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey metadataKey: %v", err)
+	}
+
+	metadataBytes, err := json.Marshal(tokenMetadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadataBytes: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(metadataKey, metadataBytes)
+	if err != nil {
+		return fmt.Errorf("failed to PutState metadataKey %s: %v", metadataKey, err)
+	}
+
+	return nil
+}
+
+// _emitMetadataUpdate emits the MetadataUpdate event of a token
+func _emitMetadataUpdate(ctx contractapi.TransactionContextInterface, tokenId string) error {
+	metadataUpdateEvent := new(MetadataUpdate)
+	metadataUpdateEvent.TokenId = tokenId
+
+	metadataUpdateEventBytes, err := json.Marshal(metadataUpdateEvent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadataUpdateEventBytes: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("MetadataUpdate", metadataUpdateEventBytes)
+	if err != nil {
+		return fmt.Errorf("failed to SetEvent metadataUpdateEventBytes %s: %v", metadataUpdateEventBytes, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MintOptions are the optional features of a non-fungible token that are set when it is minted.
+// Any combination of options can be set, a token minted without options is the same as one minted with MintWithTokenURI.
+type MintOptions struct {
+	// TransferRestriction is either "soulbound" or "issuerApproved", see MintWithTransferRestriction
+	TransferRestriction string `json:"transferRestriction,omitempty"`
+	// Royalty takes precedence over the default royalty of the collection, see MintWithRoyalty
+	Royalty *Royalty `json:"royalty,omitempty"`
+	// Metadata is a JSON object that is valid against the metadata schema, see MintWithMetadata
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// PrivateAttributes reads private attributes from the transient map, see MintWithPrivateAttributes
+	PrivateAttributes bool `json:"privateAttributes,omitempty"`
+}
+
+// MintWithOptions mints a new non-fungible token with any combination of a transfer restriction, a royalty,
+// on-chain metadata and private attributes
+// param {String} to The owner of the token, or an empty string to mint it to the calling minter
+// param {String} tokenId Unique ID of the non-fungible token to be minted
+// param {String} tokenURI URI containing metadata of the minted non-fungible token
+// param {String} options JSON object of the mint options, or an empty string for none
+// returns {Object} Return the non-fungible token object
+func (c *TokenERC721Contract) MintWithOptions(ctx contractapi.TransactionContextInterface, to string, tokenId string, tokenURI string, options string) (*Nft, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	mintOptions := new(MintOptions)
+	if options != "" {
+		decoder := json.NewDecoder(strings.NewReader(options))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(mintOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mint options: %v", err)
+		}
+	}
+
+	return _mintWithOptions(ctx, to, tokenId, tokenURI, mintOptions)
+}
+
+// _mintWithOptions checks every option before it mints the token to owner, and then stores the options of the token
+// Dependant functions include MintWithOptions, MintWithTransferRestriction, MintWithRoyalty, MintWithMetadata and
+// MintWithPrivateAttributes
+func _mintWithOptions(ctx contractapi.TransactionContextInterface, owner string, tokenId string, tokenURI string, options *MintOptions) (*Nft, error) {
+	if options.TransferRestriction != "" {
+		err := _validateTransferRestriction(options.TransferRestriction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Royalty != nil {
+		err := _checkRoyalty(options.Royalty.Receiver, options.Royalty.FeeNumerator)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var compactMetadata string
+	if options.Metadata != nil {
+		var err error
+		compactMetadata, err = _validateMetadata(ctx, string(options.Metadata))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var privateAttributes []byte
+	var clientOrgID string
+	if options.PrivateAttributes {
+		var err error
+		privateAttributes, err = _readTransientPrivateAttributes(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		clientOrgID, err = _verifyClientOrgMatchesPeerOrg(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	minter, err := _authorizeMinter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner == "" {
+		owner = minter
+	}
+
+	// The private attributes are stored in the collection of the minter organization, the minter passes them on
+	// to a recipient that agreed to receive them with TransferFrom
+	if options.PrivateAttributes && owner != minter {
+		return nil, fmt.Errorf("a token with private attributes must be minted to the minter")
+	}
+
+	nft, err := _mintTo(ctx, owner, tokenId, tokenURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.PrivateAttributes {
+		// Record the hash of the salted private attributes on the public non-fungible token
+		privateAttributesHash := sha256.Sum256(privateAttributes)
+		nft.PrivateAttributesHash = hex.EncodeToString(privateAttributesHash[:])
+		nft.PrivateAttributesOrg = clientOrgID
+
+		err = _putPrivateAttributes(ctx, nft, privateAttributes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.TransferRestriction != "" {
+		err = _putTransferRestriction(ctx, &TransferRestriction{
+			TokenId:     tokenId,
+			Issuer:      minter,
+			Restriction: options.TransferRestriction,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Royalty != nil {
+		royaltyKey, err := ctx.GetStub().CreateCompositeKey(royaltyPrefix, []string{tokenId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to CreateCompositeKey royaltyKey: %v", err)
+		}
+
+		err = _putRoyalty(ctx, royaltyKey, options.Royalty)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Metadata != nil {
+		err = _putTokenMetadata(ctx, &TokenMetadata{
+			TokenId:  tokenId,
+			Minter:   minter,
+			Metadata: compactMetadata,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nft, nil
+}
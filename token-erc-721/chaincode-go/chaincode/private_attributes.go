@@ -0,0 +1,401 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const privateAttributesPrefix = "privateAttributes"
+const privateAttributesRecipientPrefix = "privateAttributesRecipient"
+
+// Private attributes are passed in the transient map under this key, so they are not recorded on the channel
+const privateAttributesTransientKey = "private_attributes"
+
+// The salt of the private attributes is passed in the transient map under this key. It is prepended to the
+// attributes before they are hashed and stored, so that low entropy attributes can not be guessed from the hash
+const privateAttributesSaltTransientKey = "private_attributes_salt"
+
+// Length in bytes of the salt of the private attributes
+const privateAttributesSaltLength = 32
+
+// MintWithPrivateAttributes mints a new non-fungible token like MintWithTokenURI, with confidential attributes
+// that are passed in the transient map and stored in the implicit private data collection of the minter organization.
+// The attributes are salted with a random salt that is also passed in the transient map, and only the hash of the
+// salt and the attributes is recorded on the public non-fungible token.
+// The transaction must be endorsed by a peer of the minter organization.
+// param {String} tokenId Unique ID of the non-fungible token to be minted
+// param {String} tokenURI URI containing metadata of the minted non-fungible token
+// returns {Object} Return the non-fungible token object
+func (c *TokenERC721Contract) MintWithPrivateAttributes(ctx contractapi.TransactionContextInterface, tokenId string, tokenURI string) (*Nft, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return _mintWithOptions(ctx, "", tokenId, tokenURI, &MintOptions{PrivateAttributes: true})
+}
+
+// AgreeToReceivePrivateAttributes lets the calling client agree to receive a token with private attributes.
+// The client passes the salt and the attributes in the transient map, and they are stored in the implicit private data collection
+// of the client organization if they match the hash on the token. The owner can then transfer the token to the client
+// with TransferFrom, which checks that the buyer and seller collections hold the same attributes.
+// The transaction must be endorsed by a peer of the client organization.
+// param {String} tokenId The non-fungible token to receive
+// returns {Boolean} Return whether the agreement was successful or not
+func (c *TokenERC721Contract) AgreeToReceivePrivateAttributes(ctx contractapi.TransactionContextInterface, tokenId string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	privateAttributes, err := _readTransientPrivateAttributes(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	clientOrgID, err := _verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	recipient64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	recipientBytes, err := base64.StdEncoding.DecodeString(recipient64)
+	if err != nil {
+		return false, fmt.Errorf("failed to DecodeString recipient64: %v", err)
+	}
+	recipient := string(recipientBytes)
+
+	nft, err := _readNFT(ctx, tokenId)
+	if err != nil {
+		return false, fmt.Errorf("failed to _readNFT: %v", err)
+	}
+	if nft.PrivateAttributesHash == "" {
+		return false, fmt.Errorf("the token %s has no private attributes", tokenId)
+	}
+
+	privateAttributesHash := sha256.Sum256(privateAttributes)
+	if hex.EncodeToString(privateAttributesHash[:]) != nft.PrivateAttributesHash {
+		return false, fmt.Errorf("hash %x of the passed private attributes does not match the hash %s of token %s", privateAttributesHash, nft.PrivateAttributesHash, tokenId)
+	}
+
+	privateAttributesKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey privateAttributesKey: %v", err)
+	}
+
+	err = ctx.GetStub().PutPrivateData(_implicitCollectionName(clientOrgID), privateAttributesKey, privateAttributes)
+	if err != nil {
+		return false, fmt.Errorf("failed to put private attributes of token %s: %v", tokenId, err)
+	}
+
+	// Record the organization of the recipient, so that the transfer knows where the attributes move to
+	recipientKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesRecipientPrefix, []string{tokenId, recipient})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey recipientKey: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(recipientKey, []byte(clientOrgID))
+	if err != nil {
+		return false, fmt.Errorf("failed to PutState recipientKey %s: %v", recipientKey, err)
+	}
+
+	return true, nil
+}
+
+// VerifyPrivateAttributes allows a client to check attributes they were given against a non-fungible token.
+// The salt and the attributes are passed in the transient map, and compared with the hash on the token and the hash
+// in the implicit private data collection of the owner organization.
+// param {String} tokenId The non-fungible token to verify
+// returns {Boolean} Return true if the attributes are the private attributes of the token, false otherwise
+func (c *TokenERC721Contract) VerifyPrivateAttributes(ctx contractapi.TransactionContextInterface, tokenId string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	privateAttributes, err := _readTransientPrivateAttributes(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	nft, err := _readNFT(ctx, tokenId)
+	if err != nil {
+		return false, fmt.Errorf("failed to _readNFT: %v", err)
+	}
+	if nft.PrivateAttributesHash == "" {
+		return false, fmt.Errorf("the token %s has no private attributes", tokenId)
+	}
+
+	privateAttributesKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey privateAttributesKey: %v", err)
+	}
+
+	onChainHash, err := ctx.GetStub().GetPrivateDataHash(_implicitCollectionName(nft.PrivateAttributesOrg), privateAttributesKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read private attributes hash from the collection of %s: %v", nft.PrivateAttributesOrg, err)
+	}
+	if onChainHash == nil {
+		return false, fmt.Errorf("private attributes of token %s do not exist in the collection of %s", tokenId, nft.PrivateAttributesOrg)
+	}
+
+	privateAttributesHash := sha256.Sum256(privateAttributes)
+	calculatedHash := hex.EncodeToString(privateAttributesHash[:])
+
+	return calculatedHash == nft.PrivateAttributesHash && calculatedHash == hex.EncodeToString(onChainHash), nil
+}
+
+// GetPrivateAttributes returns the private attributes of a non-fungible token to its owner
+// The query must be sent to a peer of the owner organization, which holds the attributes
+// param {String} tokenId The non-fungible token to query
+// returns {String} Return the private attributes
+func (c *TokenERC721Contract) GetPrivateAttributes(ctx contractapi.TransactionContextInterface, tokenId string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	clientOrgID, err := _verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sender64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	senderBytes, err := base64.StdEncoding.DecodeString(sender64)
+	if err != nil {
+		return "", fmt.Errorf("failed to DecodeString sender: %v", err)
+	}
+	sender := string(senderBytes)
+
+	nft, err := _readNFT(ctx, tokenId)
+	if err != nil {
+		return "", fmt.Errorf("failed to _readNFT: %v", err)
+	}
+	if nft.Owner != sender {
+		return "", fmt.Errorf("only the owner of token %s can read its private attributes", tokenId)
+	}
+	if nft.PrivateAttributesHash == "" {
+		return "", fmt.Errorf("the token %s has no private attributes", tokenId)
+	}
+	if nft.PrivateAttributesOrg != clientOrgID {
+		return "", fmt.Errorf("the private attributes of token %s are held by %s", tokenId, nft.PrivateAttributesOrg)
+	}
+
+	privateAttributesKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesPrefix, []string{tokenId})
+	if err != nil {
+		return "", fmt.Errorf("failed to CreateCompositeKey privateAttributesKey: %v", err)
+	}
+
+	privateAttributes, err := ctx.GetStub().GetPrivateData(_implicitCollectionName(clientOrgID), privateAttributesKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read private attributes of token %s: %v", tokenId, err)
+	}
+	if privateAttributes == nil {
+		return "", fmt.Errorf("private attributes of token %s do not exist in the collection of %s", tokenId, clientOrgID)
+	}
+
+	// The stored private attributes are prefixed with their salt
+	if len(privateAttributes) < privateAttributesSaltLength {
+		return "", fmt.Errorf("private attributes of token %s are not salted", tokenId)
+	}
+
+	return string(privateAttributes[privateAttributesSaltLength:]), nil
+}
+
+// Helper Functions
+
+// _transferPrivateAttributes moves the private attributes of a token to the organization of the recipient,
+// which must have agreed to receive them with the same attributes as the seller. The caller stores the updated token.
+// Dependant functions include TransferFrom
+func _transferPrivateAttributes(ctx contractapi.TransactionContextInterface, nft *Nft, to string) error {
+	if nft.PrivateAttributesHash == "" {
+		return nil
+	}
+
+	recipientKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesRecipientPrefix, []string{nft.TokenId, to})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey recipientKey: %v", err)
+	}
+
+	buyerOrgIDBytes, err := ctx.GetStub().GetState(recipientKey)
+	if err != nil {
+		return fmt.Errorf("failed to GetState recipientKey %s: %v", recipientKey, err)
+	}
+	if len(buyerOrgIDBytes) == 0 {
+		return fmt.Errorf("the recipient has not agreed to receive the private attributes of token %s, call AgreeToReceivePrivateAttributes() first", nft.TokenId)
+	}
+	buyerOrgID := string(buyerOrgIDBytes)
+
+	privateAttributesKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesPrefix, []string{nft.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey privateAttributesKey: %v", err)
+	}
+
+	// Verify that the buyer collection holds the same attributes as the token
+	buyerHash, err := ctx.GetStub().GetPrivateDataHash(_implicitCollectionName(buyerOrgID), privateAttributesKey)
+	if err != nil {
+		return fmt.Errorf("failed to read private attributes hash from the collection of %s: %v", buyerOrgID, err)
+	}
+	if hex.EncodeToString(buyerHash) != nft.PrivateAttributesHash {
+		return fmt.Errorf("on-chain hash %x of the buyer does not match the hash %s of token %s", buyerHash, nft.PrivateAttributesHash, nft.TokenId)
+	}
+
+	// Delete the private attributes from the seller collection, unless the buyer is in the same organization
+	if buyerOrgID != nft.PrivateAttributesOrg {
+		err = ctx.GetStub().DelPrivateData(_implicitCollectionName(nft.PrivateAttributesOrg), privateAttributesKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete private attributes of token %s from the collection of %s: %v", nft.TokenId, nft.PrivateAttributesOrg, err)
+		}
+	}
+
+	err = ctx.GetStub().DelState(recipientKey)
+	if err != nil {
+		return fmt.Errorf("failed to DelState recipientKey %s: %v", recipientKey, err)
+	}
+
+	nft.PrivateAttributesOrg = buyerOrgID
+
+	return nil
+}
+
+// _deletePrivateAttributes deletes the private attributes of a token from the collection of its owner organization
+// Dependant functions include _burn
+func _deletePrivateAttributes(ctx contractapi.TransactionContextInterface, tokenId string) error {
+	nft, err := _readNFT(ctx, tokenId)
+	if err != nil {
+		return fmt.Errorf("failed to _readNFT: %v", err)
+	}
+	if nft.PrivateAttributesHash == "" {
+		return nil
+	}
+
+	privateAttributesKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesPrefix, []string{tokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey privateAttributesKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelPrivateData(_implicitCollectionName(nft.PrivateAttributesOrg), privateAttributesKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete private attributes of token %s: %v", tokenId, err)
+	}
+
+	return nil
+}
+
+// _putPrivateAttributes stores the non-fungible token with the hash of its private attributes, and the salted
+// private attributes in the implicit private data collection of the organization that holds them
+func _putPrivateAttributes(ctx contractapi.TransactionContextInterface, nft *Nft, privateAttributes []byte) error {
+	nftKey, err := ctx.GetStub().CreateCompositeKey(nftPrefix, []string{nft.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey to nftKey: %v", err)
+	}
+
+	nftBytes, err := json.Marshal(nft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal nft: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(nftKey, nftBytes)
+	if err != nil {
+		return fmt.Errorf("failed to PutState nftBytes %s: %v", nftBytes, err)
+	}
+
+	privateAttributesKey, err := ctx.GetStub().CreateCompositeKey(privateAttributesPrefix, []string{nft.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey privateAttributesKey: %v", err)
+	}
+
+	err = ctx.GetStub().PutPrivateData(_implicitCollectionName(nft.PrivateAttributesOrg), privateAttributesKey, privateAttributes)
+	if err != nil {
+		return fmt.Errorf("failed to put private attributes of token %s: %v", nft.TokenId, err)
+	}
+
+	return nil
+}
+
+// _readTransientPrivateAttributes returns the private attributes passed in the transient map, prefixed with their salt
+func _readTransientPrivateAttributes(ctx contractapi.TransactionContextInterface) ([]byte, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+
+	// Private attributes must be retrieved from the transient field as they are private
+	privateAttributes, ok := transientMap[privateAttributesTransientKey]
+	if !ok || len(privateAttributes) == 0 {
+		return nil, fmt.Errorf("%s key not found in the transient map", privateAttributesTransientKey)
+	}
+
+	salt, ok := transientMap[privateAttributesSaltTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("%s key not found in the transient map", privateAttributesSaltTransientKey)
+	}
+	if len(salt) != privateAttributesSaltLength {
+		return nil, fmt.Errorf("%s must be %d random bytes, got %d bytes", privateAttributesSaltTransientKey, privateAttributesSaltLength, len(salt))
+	}
+
+	saltedPrivateAttributes := make([]byte, 0, len(salt)+len(privateAttributes))
+	saltedPrivateAttributes = append(saltedPrivateAttributes, salt...)
+	saltedPrivateAttributes = append(saltedPrivateAttributes, privateAttributes...)
+
+	return saltedPrivateAttributes, nil
+}
+
+// _verifyClientOrgMatchesPeerOrg checks that the client is from the same org as the peer and returns the org
+func _verifyClientOrgMatchesPeerOrg(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed getting client's orgID: %v", err)
+	}
+
+	peerOrgID, err := shim.GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed getting peer's orgID: %v", err)
+	}
+
+	if clientOrgID != peerOrgID {
+		return "", fmt.Errorf("client from org %s is not authorized to read or write private data from an org %s peer", clientOrgID, peerOrgID)
+	}
+
+	return clientOrgID, nil
+}
+
+// _implicitCollectionName returns the implicit collection name for an org
+func _implicitCollectionName(orgID string) string {
+	return fmt.Sprintf("_implicit_org_%s", orgID)
+}
@@ -0,0 +1,330 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const userInfoPrefix = "userInfo"
+const userTokensPrefix = "userTokens"
+
+// UserInfo is the user of a rented non-fungible token (EIP-4907)
+// Expires is a unix time in seconds, the user loses its role once the transaction timestamp is past it
+type UserInfo struct {
+	TokenId string `json:"tokenId"`
+	User    string `json:"user"`
+	Expires int64  `json:"expires"`
+}
+
+// UpdateUser is emitted when the user of a token is set (EIP-4907)
+type UpdateUser struct {
+	TokenId string `json:"tokenId"`
+	User    string `json:"user"`
+	Expires int64  `json:"expires"`
+}
+
+// SetUser sets the user of a non-fungible token until it expires. The user can use the token,
+// but cannot transfer or approve it. A transfer of the token clears its user.
+// This function triggers an UpdateUser event
+// param {String} tokenId The non-fungible token to rent out
+// param {String} user The new user, or an empty string to clear the user
+// param {Number} expires The unix time in seconds until which the user can use the token
+// returns {Boolean} Return whether the update was successful or not
+func (c *TokenERC721Contract) SetUser(ctx contractapi.TransactionContextInterface, tokenId string, user string, expires int64) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	sender64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	senderBytes, err := base64.StdEncoding.DecodeString(sender64)
+	if err != nil {
+		return false, fmt.Errorf("failed to DecodeString sender: %v", err)
+	}
+	sender := string(senderBytes)
+
+	nft, err := _readNFT(ctx, tokenId)
+	if err != nil {
+		return false, fmt.Errorf("failed to _readNFT: %v", err)
+	}
+
+	// Check if the sender is the current owner of the non-fungible token,
+	// its approved client or an authorized operator of the current owner
+	owner := nft.Owner
+	operatorApproval, err := c.IsApprovedForAll(ctx, owner, sender)
+	if err != nil {
+		return false, fmt.Errorf("failed to get IsApprovedForAll: %v", err)
+	}
+	if owner != sender && nft.Approved != sender && !operatorApproval {
+		return false, fmt.Errorf("the sender is not the current owner nor an authorized operator")
+	}
+
+	if user != "" && expires <= 0 {
+		return false, fmt.Errorf("expires must be a positive unix time")
+	}
+
+	err = _clearUser(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	if user != "" {
+		err = _putUser(ctx, &UserInfo{TokenId: tokenId, User: user, Expires: expires})
+		if err != nil {
+			return false, err
+		}
+	} else {
+		expires = 0
+	}
+
+	// Emit the UpdateUser event
+	updateUserEvent := new(UpdateUser)
+	updateUserEvent.TokenId = tokenId
+	updateUserEvent.User = user
+	updateUserEvent.Expires = expires
+
+	updateUserEventBytes, err := json.Marshal(updateUserEvent)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal updateUserEventBytes: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("UpdateUser", updateUserEventBytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to SetEvent updateUserEventBytes %s: %v", updateUserEventBytes, err)
+	}
+
+	return true, nil
+}
+
+// UserOf returns the current user of a non-fungible token
+// param {String} tokenId The non-fungible token to query
+// returns {String} Return the user, or an empty string if the token has no user or its rental expired
+func (c *TokenERC721Contract) UserOf(ctx contractapi.TransactionContextInterface, tokenId string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if !_nftExists(ctx, tokenId) {
+		return "", fmt.Errorf("the token %s does not exist", tokenId)
+	}
+
+	userInfo, err := _readUser(ctx, tokenId)
+	if err != nil {
+		return "", err
+	}
+	if userInfo == nil {
+		return "", nil
+	}
+
+	active, err := _isRentalActive(ctx, userInfo.Expires)
+	if err != nil {
+		return "", err
+	}
+	if !active {
+		return "", nil
+	}
+
+	return userInfo.User, nil
+}
+
+// UserExpires returns the time until which the user of a non-fungible token can use it
+// param {String} tokenId The non-fungible token to query
+// returns {Number} Return the unix time in seconds, or 0 if the token has no user
+func (c *TokenERC721Contract) UserExpires(ctx contractapi.TransactionContextInterface, tokenId string) (int64, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if !_nftExists(ctx, tokenId) {
+		return 0, fmt.Errorf("the token %s does not exist", tokenId)
+	}
+
+	userInfo, err := _readUser(ctx, tokenId)
+	if err != nil {
+		return 0, err
+	}
+	if userInfo == nil {
+		return 0, nil
+	}
+
+	return userInfo.Expires, nil
+}
+
+// TokensOfUser returns the non-fungible tokens that are currently rented by a user, ordered by token ID
+// param {String} user The user whose rented tokens to list
+// returns {Array} Return the token IDs, rentals that expired are left out
+func (c *TokenERC721Contract) TokensOfUser(ctx contractapi.TransactionContextInterface, user string) ([]string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// There is a key record for every rented token in the format of userTokensPrefix.user.tokenId
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(userTokensPrefix, []string{user})
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetStateByPartialCompositeKey: %v", err)
+	}
+	defer iterator.Close()
+
+	tokenIds := []string{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) != 2 {
+			return nil, fmt.Errorf("expected composite key with 2 parts for prefix %s", userTokensPrefix)
+		}
+
+		userInfo := new(UserInfo)
+		err = json.Unmarshal(queryResponse.Value, userInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to Unmarshal userInfo: %v", err)
+		}
+
+		active, err := _isRentalActive(ctx, userInfo.Expires)
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			tokenIds = append(tokenIds, compositeKeyParts[1])
+		}
+	}
+
+	return tokenIds, nil
+}
+
+// Helper Functions
+
+// _readUser returns the user of a token, nil if it has none. The rental may have expired
+func _readUser(ctx contractapi.TransactionContextInterface, tokenId string) (*UserInfo, error) {
+	userInfoKey, err := ctx.GetStub().CreateCompositeKey(userInfoPrefix, []string{tokenId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to CreateCompositeKey userInfoKey: %v", err)
+	}
+
+	userInfoBytes, err := ctx.GetStub().GetState(userInfoKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetState userInfoKey %s: %v", userInfoKey, err)
+	}
+	if len(userInfoBytes) == 0 {
+		return nil, nil
+	}
+
+	userInfo := new(UserInfo)
+	err = json.Unmarshal(userInfoBytes, userInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal userInfoBytes: %v", err)
+	}
+
+	return userInfo, nil
+}
+
+// _putUser saves the user of a token along with a key record to find the tokens of the user
+func _putUser(ctx contractapi.TransactionContextInterface, userInfo *UserInfo) error {
+	userInfoKey, err := ctx.GetStub().CreateCompositeKey(userInfoPrefix, []string{userInfo.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey userInfoKey: %v", err)
+	}
+
+	userInfoBytes, err := json.Marshal(userInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal userInfoBytes: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(userInfoKey, userInfoBytes)
+	if err != nil {
+		return fmt.Errorf("failed to PutState userInfoKey %s: %v", userInfoKey, err)
+	}
+
+	userTokenKey, err := ctx.GetStub().CreateCompositeKey(userTokensPrefix, []string{userInfo.User, userInfo.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey userTokenKey: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(userTokenKey, userInfoBytes)
+	if err != nil {
+		return fmt.Errorf("failed to PutState userTokenKey %s: %v", userTokenKey, err)
+	}
+
+	return nil
+}
+
+// _clearUser removes the user of a token, if it has one
+// Dependant functions include SetUser, TransferFrom and Burn
+func _clearUser(ctx contractapi.TransactionContextInterface, tokenId string) error {
+	userInfo, err := _readUser(ctx, tokenId)
+	if err != nil {
+		return err
+	}
+	if userInfo == nil {
+		return nil
+	}
+
+	userInfoKey, err := ctx.GetStub().CreateCompositeKey(userInfoPrefix, []string{tokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey userInfoKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(userInfoKey)
+	if err != nil {
+		return fmt.Errorf("failed to DelState userInfoKey %s: %v", userInfoKey, err)
+	}
+
+	userTokenKey, err := ctx.GetStub().CreateCompositeKey(userTokensPrefix, []string{userInfo.User, tokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey userTokenKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(userTokenKey)
+	if err != nil {
+		return fmt.Errorf("failed to DelState userTokenKey %s: %v", userTokenKey, err)
+	}
+
+	return nil
+}
+
+// _isRentalActive checks an expiry against the transaction timestamp, which is the same on every endorsing peer
+func _isRentalActive(ctx contractapi.TransactionContextInterface, expires int64) (bool, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return false, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return txTimestamp.Seconds <= expires, nil
+}
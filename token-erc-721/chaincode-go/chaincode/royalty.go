@@ -0,0 +1,348 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const royaltyPrefix = "royalty"
+
+// Define key names for options
+const defaultRoyaltyKey = "defaultRoyalty"
+const paymentChaincodeKey = "paymentChaincode"
+
+// Royalty fractions are expressed in basis points, a fraction of feeDenominator is 100% of the sale price
+const feeDenominator = 10000
+
+// Royalty is the royalty configuration of the collection or of a single token
+type Royalty struct {
+	Receiver     string `json:"receiver"`
+	FeeNumerator int    `json:"feeNumerator"`
+}
+
+// RoyaltyPayment is the royalty owed for a sale, as returned by RoyaltyInfo
+type RoyaltyPayment struct {
+	Receiver      string `json:"receiver"`
+	RoyaltyAmount string `json:"royaltyAmount"`
+}
+
+// MintWithRoyalty mints a new non-fungible token like MintWithTokenURI, with a royalty for this token
+// that takes precedence over the default royalty of the collection
+// param {String} tokenId Unique ID of the non-fungible token to be minted
+// param {String} tokenURI URI containing metadata of the minted non-fungible token
+// param {String} receiver The client that receives the royalty of every sale
+// param {Number} feeNumerator The royalty in basis points of the sale price, at most 10000
+// returns {Object} Return the non-fungible token object
+func (c *TokenERC721Contract) MintWithRoyalty(ctx contractapi.TransactionContextInterface, tokenId string, tokenURI string, receiver string, feeNumerator int) (*Nft, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return _mintWithOptions(ctx, "", tokenId, tokenURI, &MintOptions{Royalty: &Royalty{Receiver: receiver, FeeNumerator: feeNumerator}})
+}
+
+// SetDefaultRoyalty sets the royalty of every token minted without a royalty of its own.
+// A fee of zero removes the default royalty.
+// param {String} receiver The client that receives the royalty of every sale
+// param {Number} feeNumerator The royalty in basis points of the sale price, at most 10000
+// returns {Boolean} Return whether the royalty was set or not
+func (c *TokenERC721Contract) SetDefaultRoyalty(ctx contractapi.TransactionContextInterface, receiver string, feeNumerator int) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to set royalties
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to set the default royalty")
+	}
+
+	if feeNumerator == 0 {
+		err = ctx.GetStub().DelState(defaultRoyaltyKey)
+		if err != nil {
+			return false, fmt.Errorf("failed to DelState defaultRoyaltyKey: %v", err)
+		}
+		return true, nil
+	}
+
+	err = _checkRoyalty(receiver, feeNumerator)
+	if err != nil {
+		return false, err
+	}
+
+	err = _putRoyalty(ctx, defaultRoyaltyKey, &Royalty{Receiver: receiver, FeeNumerator: feeNumerator})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RoyaltyInfo returns the receiver and the amount of the royalty owed for a sale (EIP-2981)
+// param {String} tokenId The non-fungible token being sold
+// param {String} salePrice The sale price as a base-10 integer string, in the unit of the payment token
+// returns {Object} Return the royalty receiver and amount, an empty receiver and zero if no royalty applies
+func (c *TokenERC721Contract) RoyaltyInfo(ctx contractapi.TransactionContextInterface, tokenId string, salePrice string) (*RoyaltyPayment, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if !_nftExists(ctx, tokenId) {
+		return nil, fmt.Errorf("the token %s does not exist", tokenId)
+	}
+
+	price, ok := new(big.Int).SetString(salePrice, 10)
+	if !ok || price.Sign() < 0 {
+		return nil, fmt.Errorf("invalid sale price %q, it should be a non-negative base-10 integer", salePrice)
+	}
+
+	return _royaltyPayment(ctx, tokenId, price)
+}
+
+// SetPaymentChaincode sets the token-erc-20 chaincode on the same channel that TransferWithPayment pays with
+// param {String} chaincodeName The name of the payment chaincode
+// returns {Boolean} Return whether the payment chaincode was set or not
+func (c *TokenERC721Contract) SetPaymentChaincode(ctx contractapi.TransactionContextInterface, chaincodeName string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to set the payment chaincode
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to set the payment chaincode")
+	}
+
+	if chaincodeName == "" {
+		return false, fmt.Errorf("payment chaincode name must not be empty")
+	}
+
+	err = ctx.GetStub().PutState(paymentChaincodeKey, []byte(chaincodeName))
+	if err != nil {
+		return false, fmt.Errorf("failed to PutState paymentChaincodeKey: %v", err)
+	}
+
+	return true, nil
+}
+
+// TransferWithPayment transfers a non-fungible token like TransferFrom and pays for it in the same transaction.
+// The sale price is paid from the new owner's account of the payment chaincode, the royalty to the
+// royalty receiver and the rest to the current owner.
+// Unless the new owner submits the transaction, the payment spends an allowance of the payment chaincode,
+// so the new owner must first approve an allowance of at least the sale price to the submitting client.
+// param {String} from The current owner of the non-fungible token
+// param {String} to The new owner, who pays the sale price
+// param {String} tokenId the non-fungible token to transfer
+// param {String} salePrice The sale price as a base-10 integer string
+// returns {Boolean} Return whether the transfer was successful or not
+func (c *TokenERC721Contract) TransferWithPayment(ctx contractapi.TransactionContextInterface, from string, to string, tokenId string, salePrice string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if from == to {
+		return false, fmt.Errorf("cannot sell a token to its current owner")
+	}
+
+	price, ok := new(big.Int).SetString(salePrice, 10)
+	if !ok || price.Sign() <= 0 {
+		return false, fmt.Errorf("invalid sale price %q, it should be a positive base-10 integer", salePrice)
+	}
+
+	paymentChaincode, err := ctx.GetStub().GetState(paymentChaincodeKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to GetState paymentChaincodeKey: %v", err)
+	}
+	if len(paymentChaincode) == 0 {
+		return false, fmt.Errorf("payment chaincode is not set, call SetPaymentChaincode() first")
+	}
+
+	// The royalty is calculated before the transfer, which does not change it
+	royalty, err := _royaltyPayment(ctx, tokenId, price)
+	if err != nil {
+		return false, err
+	}
+
+	// TransferFrom checks that the submitting client may transfer the token and that from is its owner
+	transferred, err := c.TransferFrom(ctx, from, to, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	// Split the payment, token-erc-20 rejects zero amounts and repeated recipients
+	royaltyAmount, _ := new(big.Int).SetString(royalty.RoyaltyAmount, 10)
+	sellerAmount := new(big.Int).Sub(price, royaltyAmount)
+
+	recipients := []string{}
+	amounts := []string{}
+	if royaltyAmount.Sign() > 0 {
+		switch royalty.Receiver {
+		case from:
+			// The seller receives the royalty as part of the price
+			sellerAmount = price
+		case to:
+			// The buyer owes the royalty to itself
+		default:
+			recipients = append(recipients, _paymentAccount(royalty.Receiver))
+			amounts = append(amounts, royaltyAmount.String())
+		}
+	}
+	if sellerAmount.Sign() > 0 {
+		recipients = append(recipients, _paymentAccount(from))
+		amounts = append(amounts, sellerAmount.String())
+	}
+	if len(recipients) == 0 {
+		return transferred, nil
+	}
+
+	recipientsJSON, err := json.Marshal(recipients)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal recipients: %v", err)
+	}
+	amountsJSON, err := json.Marshal(amounts)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal amounts: %v", err)
+	}
+
+	// Get ID of submitting client identity
+	sender64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	// The payment chaincode sees the submitting client, a buyer who submits pays from its own account,
+	// anyone else spends the buyer's allowance. The writes commit or fail together with this transaction.
+	args := [][]byte{[]byte("BatchTransferFrom"), []byte(_paymentAccount(to)), recipientsJSON, amountsJSON}
+	if sender64 == _paymentAccount(to) {
+		args = [][]byte{[]byte("BatchTransfer"), recipientsJSON, amountsJSON}
+	}
+
+	response := ctx.GetStub().InvokeChaincode(string(paymentChaincode), args, "")
+	if response.Status != shim.OK {
+		return false, fmt.Errorf("failed to pay %s for token %s: %s", price, tokenId, response.Message)
+	}
+
+	return transferred, nil
+}
+
+// Helper Functions
+
+// _checkRoyalty checks that a royalty has a receiver and is at most 100% of the sale price
+func _checkRoyalty(receiver string, feeNumerator int) error {
+	if feeNumerator < 0 || feeNumerator > feeDenominator {
+		return fmt.Errorf("royalty fee %d must be between 0 and %d basis points", feeNumerator, feeDenominator)
+	}
+	if feeNumerator > 0 && receiver == "" {
+		return fmt.Errorf("royalty receiver must not be empty")
+	}
+
+	return nil
+}
+
+func _putRoyalty(ctx contractapi.TransactionContextInterface, key string, royalty *Royalty) error {
+	royaltyBytes, err := json.Marshal(royalty)
+	if err != nil {
+		return fmt.Errorf("failed to marshal royalty: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, royaltyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to PutState royalty %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// _readRoyalty returns the royalty stored under key, nil if there is none
+func _readRoyalty(ctx contractapi.TransactionContextInterface, key string) (*Royalty, error) {
+	royaltyBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetState royalty %s: %v", key, err)
+	}
+	if len(royaltyBytes) == 0 {
+		return nil, nil
+	}
+
+	royalty := new(Royalty)
+	err = json.Unmarshal(royaltyBytes, royalty)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal royaltyBytes: %v", err)
+	}
+
+	return royalty, nil
+}
+
+// _royaltyPayment calculates the royalty of a sale from the royalty of the token, or else the default royalty
+func _royaltyPayment(ctx contractapi.TransactionContextInterface, tokenId string, salePrice *big.Int) (*RoyaltyPayment, error) {
+	royaltyKey, err := ctx.GetStub().CreateCompositeKey(royaltyPrefix, []string{tokenId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to CreateCompositeKey royaltyKey: %v", err)
+	}
+
+	royalty, err := _readRoyalty(ctx, royaltyKey)
+	if err != nil {
+		return nil, err
+	}
+	if royalty == nil {
+		royalty, err = _readRoyalty(ctx, defaultRoyaltyKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if royalty == nil {
+		return &RoyaltyPayment{Receiver: "", RoyaltyAmount: "0"}, nil
+	}
+
+	// The royalty is rounded down to a whole unit of the payment token
+	royaltyAmount := new(big.Int).Mul(salePrice, big.NewInt(int64(royalty.FeeNumerator)))
+	royaltyAmount.Quo(royaltyAmount, big.NewInt(feeDenominator))
+
+	return &RoyaltyPayment{Receiver: royalty.Receiver, RoyaltyAmount: royaltyAmount.String()}, nil
+}
+
+// _paymentAccount converts an account of this contract, the decoded client ID, to an account of token-erc-20
+func _paymentAccount(account string) string {
+	return base64.StdEncoding.EncodeToString([]byte(account))
+}
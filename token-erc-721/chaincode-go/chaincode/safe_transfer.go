@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const receiverContractPrefix = "receiverContract"
+
+// erc721ReceivedMagicValue must be returned by OnERC721Received to accept a token,
+// it is the ERC-721 selector of onERC721Received(address,address,uint256,bytes)
+const erc721ReceivedMagicValue = "0x150b7a02"
+
+// SafeTransferFrom transfers the ownership of a non-fungible token like TransferFrom.
+// When the new owner is registered as a receiver contract, OnERC721Received(operator, from, tokenId, data)
+// of that chaincode is invoked and the transfer is reverted unless it returns the ERC-721 magic value.
+// param {String} from The current owner of the non-fungible token
+// param {String} to The new owner
+// param {String} tokenId the non-fungible token to transfer
+// param {String} data Additional data with no specified format, passed to the receiver contract
+// returns {Boolean} Return whether the transfer was successful or not
+func (c *TokenERC721Contract) SafeTransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, tokenId string, data string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	transferred, err := c.TransferFrom(ctx, from, to, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	// Get ID of submitting client identity
+	sender64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	senderBytes, err := base64.StdEncoding.DecodeString(sender64)
+	if err != nil {
+		return false, fmt.Errorf("failed to DecodeString sender: %v", err)
+	}
+	sender := string(senderBytes)
+
+	err = _checkOnERC721Received(ctx, sender, from, to, tokenId, data)
+	if err != nil {
+		return false, err
+	}
+
+	return transferred, nil
+}
+
+// RegisterReceiverContract registers an account as owned by a chaincode on the same channel.
+// SafeTransferFrom to the account invokes OnERC721Received of that chaincode.
+// param {String} account The account that is owned by the chaincode
+// param {String} chaincodeName The name of the chaincode implementing OnERC721Received
+// returns {Boolean} Return whether the registration was successful or not
+func (c *TokenERC721Contract) RegisterReceiverContract(ctx contractapi.TransactionContextInterface, account string, chaincodeName string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization - this sample assumes Org1 is the issuer with privilege to register receiver contracts
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to register receiver contracts")
+	}
+
+	if account == "" || chaincodeName == "" {
+		return false, fmt.Errorf("account and chaincode name must not be empty")
+	}
+
+	receiverKey, err := ctx.GetStub().CreateCompositeKey(receiverContractPrefix, []string{account})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey receiverKey: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(receiverKey, []byte(chaincodeName))
+	if err != nil {
+		return false, fmt.Errorf("failed to PutState receiverKey %s: %v", receiverKey, err)
+	}
+
+	return true, nil
+}
+
+// UnregisterReceiverContract removes the receiver contract registration of an account
+// param {String} account The account that was registered as owned by a chaincode
+// returns {Boolean} Return whether the removal was successful or not
+func (c *TokenERC721Contract) UnregisterReceiverContract(ctx contractapi.TransactionContextInterface, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check admin authorization - this sample assumes Org1 is the issuer with privilege to register receiver contracts
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to register receiver contracts")
+	}
+
+	receiverKey, err := ctx.GetStub().CreateCompositeKey(receiverContractPrefix, []string{account})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey receiverKey: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(receiverKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to DelState receiverKey %s: %v", receiverKey, err)
+	}
+
+	return true, nil
+}
+
+// GetReceiverContract returns the name of the chaincode registered for an account
+// param {String} account The account to look up
+// returns {String} Return the chaincode name, or an empty string if the account is not a receiver contract
+func (c *TokenERC721Contract) GetReceiverContract(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return "", fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return _readReceiverContract(ctx, account)
+}
+
+// Helper Functions
+
+func _readReceiverContract(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+	receiverKey, err := ctx.GetStub().CreateCompositeKey(receiverContractPrefix, []string{account})
+	if err != nil {
+		return "", fmt.Errorf("failed to CreateCompositeKey receiverKey: %v", err)
+	}
+
+	chaincodeNameBytes, err := ctx.GetStub().GetState(receiverKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to GetState receiverKey %s: %v", receiverKey, err)
+	}
+
+	return string(chaincodeNameBytes), nil
+}
+
+// _checkOnERC721Received invokes OnERC721Received when the recipient is a receiver contract.
+// The invoked chaincode runs in this transaction, so an error here reverts the transfer.
+func _checkOnERC721Received(ctx contractapi.TransactionContextInterface, operator string, from string, to string, tokenId string, data string) error {
+	chaincodeName, err := _readReceiverContract(ctx, to)
+	if err != nil {
+		return err
+	}
+	if chaincodeName == "" {
+		return nil
+	}
+
+	args := [][]byte{[]byte("OnERC721Received"), []byte(operator), []byte(from), []byte(tokenId), []byte(data)}
+
+	response := ctx.GetStub().InvokeChaincode(chaincodeName, args, "")
+	if response.Status != shim.OK {
+		return fmt.Errorf("receiver contract %s rejected token %s: %s", chaincodeName, tokenId, response.Message)
+	}
+	if string(response.Payload) != erc721ReceivedMagicValue {
+		return fmt.Errorf("receiver contract %s returned %q instead of %s for token %s", chaincodeName, string(response.Payload), erc721ReceivedMagicValue, tokenId)
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const transferRestrictionPrefix = "transferRestriction"
+const issuerApprovalPrefix = "issuerApproval"
+
+// Transfer restrictions of a non-fungible token, a token without a restriction transfers freely
+const (
+	// RestrictionSoulbound tokens can never be transferred or approved
+	RestrictionSoulbound = "soulbound"
+	// RestrictionIssuerApproved tokens can only be transferred to a recipient that the issuer approved
+	RestrictionIssuerApproved = "issuerApproved"
+)
+
+// TransferRestriction restricts the transfers of a non-fungible token, such as a credential.
+// Issuer is the client that minted the token, it approves transfers and can revoke the token.
+type TransferRestriction struct {
+	TokenId     string `json:"tokenId"`
+	Issuer      string `json:"issuer"`
+	Restriction string `json:"restriction"`
+}
+
+// MintWithTransferRestriction mints a new non-fungible token with a transfer restriction to its holder
+// The calling minter becomes the issuer of the token, a restricted token cannot be passed on after minting so it is minted
+// directly to the holder
+// param {String} to The holder of the token, a client ID as returned by ClientAccountID
+// param {String} tokenId Unique ID of the non-fungible token to be minted
+// param {String} tokenURI URI containing metadata of the minted non-fungible token
+// param {String} restriction Either "soulbound" or "issuerApproved"
+// returns {Object} Return the non-fungible token object
+func (c *TokenERC721Contract) MintWithTransferRestriction(ctx contractapi.TransactionContextInterface, to string, tokenId string, tokenURI string, restriction string) (*Nft, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if to == "" {
+		return nil, fmt.Errorf("the holder of a restricted token must not be empty")
+	}
+
+	return _mintWithOptions(ctx, to, tokenId, tokenURI, &MintOptions{TransferRestriction: restriction})
+}
+
+// ApproveRestrictedTransfer lets the issuer of an issuer approved token co-approve its next transfer.
+// The owner, or a client approved by the owner, still has to call TransferFrom to the approved recipient.
+// The approval is used up by the transfer, and replaced by a later approval.
+// param {String} tokenId The non-fungible token to approve the transfer of
+// param {String} to The recipient of the approved transfer, or an empty string to withdraw the approval
+// returns {Boolean} Return whether the approval was successful or not
+func (c *TokenERC721Contract) ApproveRestrictedTransfer(ctx contractapi.TransactionContextInterface, tokenId string, to string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	transferRestriction, err := _readIssuedTransferRestriction(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+	if transferRestriction.Restriction != RestrictionIssuerApproved {
+		return false, fmt.Errorf("the token %s is %s, its transfers cannot be approved", tokenId, transferRestriction.Restriction)
+	}
+
+	issuerApprovalKey, err := ctx.GetStub().CreateCompositeKey(issuerApprovalPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey issuerApprovalKey: %v", err)
+	}
+
+	if to == "" {
+		err = ctx.GetStub().DelState(issuerApprovalKey)
+		if err != nil {
+			return false, fmt.Errorf("failed to DelState issuerApprovalKey %s: %v", issuerApprovalKey, err)
+		}
+
+		return true, nil
+	}
+
+	err = ctx.GetStub().PutState(issuerApprovalKey, []byte(to))
+	if err != nil {
+		return false, fmt.Errorf("failed to PutState issuerApprovalKey %s: %v", issuerApprovalKey, err)
+	}
+
+	return true, nil
+}
+
+// Revoke burns a transfer restricted token on behalf of its issuer, whoever owns it
+// This function triggers a Transfer event
+// param {String} tokenId The non-fungible token to revoke
+// returns {Boolean} Return whether the revocation was successful or not
+func (c *TokenERC721Contract) Revoke(ctx contractapi.TransactionContextInterface, tokenId string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	_, err = _readIssuedTransferRestriction(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	nft, err := _readNFT(ctx, tokenId)
+	if err != nil {
+		return false, fmt.Errorf("failed to _readNFT nft : %v", err)
+	}
+
+	return _burn(ctx, nft.Owner, tokenId)
+}
+
+// Locked returns whether a non-fungible token is locked to its owner (EIP-5192)
+// Soulbound tokens are always locked, issuer approved tokens are locked unless the issuer approves a transfer
+// param {String} tokenId The non-fungible token to query
+// returns {Boolean} Return true if the token cannot be transferred, false otherwise
+func (c *TokenERC721Contract) Locked(ctx contractapi.TransactionContextInterface, tokenId string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if !_nftExists(ctx, tokenId) {
+		return false, fmt.Errorf("the token %s does not exist", tokenId)
+	}
+
+	transferRestriction, err := _readTransferRestriction(ctx, tokenId)
+	if err != nil {
+		return false, err
+	}
+	if transferRestriction == nil {
+		return false, nil
+	}
+	if transferRestriction.Restriction == RestrictionSoulbound {
+		return true, nil
+	}
+
+	issuerApprovalKey, err := ctx.GetStub().CreateCompositeKey(issuerApprovalPrefix, []string{tokenId})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey issuerApprovalKey: %v", err)
+	}
+
+	approvedRecipient, err := ctx.GetStub().GetState(issuerApprovalKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to GetState issuerApprovalKey %s: %v", issuerApprovalKey, err)
+	}
+
+	return len(approvedRecipient) == 0, nil
+}
+
+// GetTransferRestriction returns the transfer restriction and the issuer of a non-fungible token
+// param {String} tokenId The non-fungible token to query
+// returns {Object} Return the transfer restriction, or null if the token transfers freely
+func (c *TokenERC721Contract) GetTransferRestriction(ctx contractapi.TransactionContextInterface, tokenId string) (*TransferRestriction, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	if !_nftExists(ctx, tokenId) {
+		return nil, fmt.Errorf("the token %s does not exist", tokenId)
+	}
+
+	return _readTransferRestriction(ctx, tokenId)
+}
+
+// Helper Functions
+
+// _validateTransferRestriction checks that restriction is one of the supported transfer restrictions
+func _validateTransferRestriction(restriction string) error {
+	if restriction != RestrictionSoulbound && restriction != RestrictionIssuerApproved {
+		return fmt.Errorf("invalid transfer restriction %q, it should be %s or %s", restriction, RestrictionSoulbound, RestrictionIssuerApproved)
+	}
+
+	return nil
+}
+
+func _putTransferRestriction(ctx contractapi.TransactionContextInterface, transferRestriction *TransferRestriction) error {
+	transferRestrictionKey, err := ctx.GetStub().CreateCompositeKey(transferRestrictionPrefix, []string{transferRestriction.TokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey transferRestrictionKey: %v", err)
+	}
+
+	transferRestrictionBytes, err := json.Marshal(transferRestriction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transferRestrictionBytes: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(transferRestrictionKey, transferRestrictionBytes)
+	if err != nil {
+		return fmt.Errorf("failed to PutState transferRestrictionKey %s: %v", transferRestrictionKey, err)
+	}
+
+	return nil
+}
+
+// _readTransferRestriction returns the transfer restriction of a token, nil if it has none
+func _readTransferRestriction(ctx contractapi.TransactionContextInterface, tokenId string) (*TransferRestriction, error) {
+	transferRestrictionKey, err := ctx.GetStub().CreateCompositeKey(transferRestrictionPrefix, []string{tokenId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to CreateCompositeKey transferRestrictionKey: %v", err)
+	}
+
+	transferRestrictionBytes, err := ctx.GetStub().GetState(transferRestrictionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetState transferRestrictionKey %s: %v", transferRestrictionKey, err)
+	}
+	if len(transferRestrictionBytes) == 0 {
+		return nil, nil
+	}
+
+	transferRestriction := new(TransferRestriction)
+	err = json.Unmarshal(transferRestrictionBytes, transferRestriction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal transferRestrictionBytes: %v", err)
+	}
+
+	return transferRestriction, nil
+}
+
+// _readIssuedTransferRestriction returns the transfer restriction of a token after checking
+// that the calling client is its issuer
+func _readIssuedTransferRestriction(ctx contractapi.TransactionContextInterface, tokenId string) (*TransferRestriction, error) {
+	sender64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	senderBytes, err := base64.StdEncoding.DecodeString(sender64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to DecodeString sender: %v", err)
+	}
+	sender := string(senderBytes)
+
+	transferRestriction, err := _readTransferRestriction(ctx, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	if transferRestriction == nil {
+		return nil, fmt.Errorf("the token %s has no transfer restriction", tokenId)
+	}
+	if transferRestriction.Issuer != sender {
+		return nil, fmt.Errorf("only the issuer of token %s can approve its transfers or revoke it", tokenId)
+	}
+
+	return transferRestriction, nil
+}
+
+// _checkTransferRestriction rejects the transfer of a soulbound token, and the transfer of an issuer approved token
+// unless the issuer approved the recipient. The approval of the issuer is used up by the transfer.
+// Dependant functions include TransferFrom
+func _checkTransferRestriction(ctx contractapi.TransactionContextInterface, tokenId string, to string) error {
+	transferRestriction, err := _readTransferRestriction(ctx, tokenId)
+	if err != nil {
+		return err
+	}
+	if transferRestriction == nil {
+		return nil
+	}
+	if transferRestriction.Restriction == RestrictionSoulbound {
+		return fmt.Errorf("the token %s is soulbound and cannot be transferred", tokenId)
+	}
+
+	issuerApprovalKey, err := ctx.GetStub().CreateCompositeKey(issuerApprovalPrefix, []string{tokenId})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey issuerApprovalKey: %v", err)
+	}
+
+	approvedRecipient, err := ctx.GetStub().GetState(issuerApprovalKey)
+	if err != nil {
+		return fmt.Errorf("failed to GetState issuerApprovalKey %s: %v", issuerApprovalKey, err)
+	}
+	if len(approvedRecipient) == 0 || string(approvedRecipient) != to {
+		return fmt.Errorf("the issuer of token %s has not approved its transfer to %s", tokenId, to)
+	}
+
+	err = ctx.GetStub().DelState(issuerApprovalKey)
+	if err != nil {
+		return fmt.Errorf("failed to DelState issuerApprovalKey %s: %v", issuerApprovalKey, err)
+	}
+
+	return nil
+}
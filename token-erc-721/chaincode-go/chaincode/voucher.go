@@ -0,0 +1,272 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const certificatePrefix = "certificate"
+const redeemedVoucherPrefix = "redeemedVoucher"
+
+// MintVoucher authorizes anyone to mint a token for at least a minimum price until it expires.
+// The minter signs the SHA-256 hash of the JSON encoded voucher off-chain, and the redeemer submits
+// the voucher exactly as it was signed.
+// Minter is the client ID of the signing minter as returned by ClientAccountID(), MinPrice is a base-10 integer
+// string in the unit of the payment chaincode and Expiry is a unix time in seconds.
+// Channel keeps a voucher from being redeemed on another channel.
+type MintVoucher struct {
+	Channel  string `json:"channel"`
+	Minter   string `json:"minter"`
+	TokenId  string `json:"tokenId"`
+	TokenURI string `json:"tokenURI"`
+	MinPrice string `json:"minPrice"`
+	Expiry   int64  `json:"expiry"`
+}
+
+// RegisterMinterCertificate records the X.509 certificate of the calling minter, which RedeemVoucher uses to verify its vouchers
+// The certificate is taken from the submitting identity, so it has been validated against the channel MSP by the peer
+// returns {Boolean} Return whether the registration was successful or not
+func (c *TokenERC721Contract) RegisterMinterCertificate(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization - this sample assumes Org1 is the issuer with privilege to mint a new token
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get clientMSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to sign mint vouchers")
+	}
+
+	minter64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get minter id: %v", err)
+	}
+
+	minterBytes, err := base64.StdEncoding.DecodeString(minter64)
+	if err != nil {
+		return false, fmt.Errorf("failed to DecodeString minter64: %v", err)
+	}
+	minter := string(minterBytes)
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert == nil {
+		return false, fmt.Errorf("client identity has no X.509 certificate")
+	}
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); !ok {
+		return false, fmt.Errorf("client certificate does not hold an ECDSA public key")
+	}
+
+	certificateKey, err := ctx.GetStub().CreateCompositeKey(certificatePrefix, []string{minter})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey certificateKey: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	err = ctx.GetStub().PutState(certificateKey, certPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to PutState certificateKey %s: %v", certificateKey, err)
+	}
+
+	return true, nil
+}
+
+// RedeemVoucher mints the token of a voucher signed by a minter to the calling client.
+// The calling client pays the minimum price of the voucher to the minter with the payment chaincode
+// set by SetPaymentChaincode(), in the same transaction. A voucher can be redeemed only once.
+// This function triggers a Transfer event
+// param {String} voucher The JSON encoded MintVoucher, exactly as it was signed
+// param {String} signature The base64 encoded ASN.1 ECDSA signature of the minter
+// returns {Object} Return the non-fungible token object
+func (c *TokenERC721Contract) RedeemVoucher(ctx contractapi.TransactionContextInterface, voucher string, signature string) (*Nft, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	mintVoucher := new(MintVoucher)
+	err = json.Unmarshal([]byte(voucher), mintVoucher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal voucher: %v", err)
+	}
+
+	if mintVoucher.Channel != ctx.GetStub().GetChannelID() {
+		return nil, fmt.Errorf("the voucher is for channel %s", mintVoucher.Channel)
+	}
+	if mintVoucher.TokenId == "" {
+		return nil, fmt.Errorf("the voucher has no token ID")
+	}
+
+	minPrice, ok := new(big.Int).SetString(mintVoucher.MinPrice, 10)
+	if !ok || minPrice.Sign() < 0 {
+		return nil, fmt.Errorf("invalid minimum price %q, it should be a non-negative base-10 integer", mintVoucher.MinPrice)
+	}
+
+	// Check the expiry against the transaction timestamp, which is the same on every endorsing peer
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	if txTimestamp.Seconds > mintVoucher.Expiry {
+		return nil, fmt.Errorf("the voucher for token %s expired at %d", mintVoucher.TokenId, mintVoucher.Expiry)
+	}
+
+	digest := sha256.Sum256([]byte(voucher))
+	err = _verifyMinterSignature(ctx, mintVoucher.Minter, digest[:], signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// A voucher can be redeemed only once, also after its token was burned
+	redeemedKey, err := ctx.GetStub().CreateCompositeKey(redeemedVoucherPrefix, []string{hex.EncodeToString(digest[:])})
+	if err != nil {
+		return nil, fmt.Errorf("failed to CreateCompositeKey redeemedKey: %v", err)
+	}
+
+	redeemedBytes, err := ctx.GetStub().GetState(redeemedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetState redeemedKey %s: %v", redeemedKey, err)
+	}
+	if len(redeemedBytes) > 0 {
+		return nil, fmt.Errorf("the voucher for token %s was already redeemed in transaction %s", mintVoucher.TokenId, string(redeemedBytes))
+	}
+
+	err = ctx.GetStub().PutState(redeemedKey, []byte(ctx.GetStub().GetTxID()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to PutState redeemedKey %s: %v", redeemedKey, err)
+	}
+
+	// Get ID of submitting client identity
+	redeemer64, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetClientIdentity: %v", err)
+	}
+
+	redeemerBytes, err := base64.StdEncoding.DecodeString(redeemer64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to DecodeString redeemer64: %v", err)
+	}
+	redeemer := string(redeemerBytes)
+
+	nft, err := _mintTo(ctx, redeemer, mintVoucher.TokenId, mintVoucher.TokenURI)
+	if err != nil {
+		return nil, err
+	}
+
+	// The redeemer pays the minter from its own account, a minter redeeming its own voucher pays nothing
+	if minPrice.Sign() > 0 && redeemer != mintVoucher.Minter {
+		paymentChaincode, err := ctx.GetStub().GetState(paymentChaincodeKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to GetState paymentChaincodeKey: %v", err)
+		}
+		if len(paymentChaincode) == 0 {
+			return nil, fmt.Errorf("payment chaincode is not set, call SetPaymentChaincode() first")
+		}
+
+		args := [][]byte{[]byte("Transfer"), []byte(_paymentAccount(mintVoucher.Minter)), []byte(minPrice.String())}
+
+		response := ctx.GetStub().InvokeChaincode(string(paymentChaincode), args, "")
+		if response.Status != shim.OK {
+			return nil, fmt.Errorf("failed to pay %s for token %s: %s", minPrice, mintVoucher.TokenId, response.Message)
+		}
+	}
+
+	return nft, nil
+}
+
+// VoucherRedeemed returns whether a voucher was already redeemed
+// param {String} voucher The JSON encoded MintVoucher, exactly as it was signed
+// returns {Boolean} Return true if the voucher was redeemed, false otherwise
+func (c *TokenERC721Contract) VoucherRedeemed(ctx contractapi.TransactionContextInterface, voucher string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	digest := sha256.Sum256([]byte(voucher))
+	redeemedKey, err := ctx.GetStub().CreateCompositeKey(redeemedVoucherPrefix, []string{hex.EncodeToString(digest[:])})
+	if err != nil {
+		return false, fmt.Errorf("failed to CreateCompositeKey redeemedKey: %v", err)
+	}
+
+	redeemedBytes, err := ctx.GetStub().GetState(redeemedKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to GetState redeemedKey %s: %v", redeemedKey, err)
+	}
+
+	return len(redeemedBytes) > 0, nil
+}
+
+// Helper Functions
+
+// _verifyMinterSignature checks the base64 encoded ECDSA signature of a digest against the minter's registered certificate
+func _verifyMinterSignature(ctx contractapi.TransactionContextInterface, minter string, digest []byte, signature string) error {
+	certificateKey, err := ctx.GetStub().CreateCompositeKey(certificatePrefix, []string{minter})
+	if err != nil {
+		return fmt.Errorf("failed to CreateCompositeKey certificateKey: %v", err)
+	}
+
+	certPEM, err := ctx.GetStub().GetState(certificateKey)
+	if err != nil {
+		return fmt.Errorf("failed to GetState certificateKey %s: %v", certificateKey, err)
+	}
+	if len(certPEM) == 0 {
+		return fmt.Errorf("minter %s has not registered a certificate", minter)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("failed to decode certificate of %s", minter)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate of %s: %v", minter, err)
+	}
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certificate of %s does not hold an ECDSA public key", minter)
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	if !ecdsa.VerifyASN1(publicKey, digest, signatureBytes) {
+		return fmt.Errorf("invalid signature of minter %s", minter)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package chaincode
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SupplyAudit is the result of AuditSupply
+// TotalSupply is the recorded total supply and Balances the sum of all unspent transaction outputs
+// Consistent is true when Balances equals TotalSupply and no anomalies were found, a burn beyond the recorded total supply is an anomaly
+type SupplyAudit struct {
+	TotalSupply int      `json:"totalSupply"`
+	Balances    int      `json:"balances"`
+	UTXOCount   int      `json:"utxoCount"`
+	Consistent  bool     `json:"consistent"`
+	Anomalies   []string `json:"anomalies"`
+}
+
+// AuditSupply checks the supply invariant by iterating every UTXO in the world state
+// The sum of all unspent transaction outputs must equal the total supply
+// This is an evaluate transaction, it reads the whole token state and should not be submitted for ordering
+func (s *SmartContract) AuditSupply(ctx contractapi.TransactionContextInterface) (*SupplyAudit, error) {
+
+	// check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	anomalies := []string{}
+
+	totalSupply, err := readTotalSupply(ctx)
+	if err != nil {
+		anomalies = append(anomalies, err.Error())
+	}
+
+	// utxos have a composite key of owner:utxoKey, an empty partial key matches all of them
+	utxoResultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("utxo", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer utxoResultsIterator.Close()
+
+	var balances, utxoCount int
+	for utxoResultsIterator.HasNext() {
+		utxoRecord, err := utxoResultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		utxoCount++
+
+		amount, err := strconv.Atoi(string(utxoRecord.Value))
+		if err != nil {
+			anomalies = append(anomalies, fmt.Sprintf("utxo %q has an invalid amount %q", utxoRecord.Key, string(utxoRecord.Value)))
+			continue
+		}
+		if amount <= 0 {
+			anomalies = append(anomalies, fmt.Sprintf("utxo %q has a non-positive amount %d", utxoRecord.Key, amount))
+			continue
+		}
+
+		balances, err = add(balances, amount)
+		if err != nil {
+			anomalies = append(anomalies, err.Error())
+		}
+	}
+
+	// Burns beyond the recorded total supply were clamped, the supply history is not consistent
+	supplyShortfall, err := readSupplyShortfall(ctx)
+	if err != nil {
+		anomalies = append(anomalies, err.Error())
+	}
+	if supplyShortfall > 0 {
+		anomalies = append(anomalies, fmt.Sprintf("burns exceeded the recorded total supply by %d", supplyShortfall))
+	}
+
+	if balances != totalSupply {
+		anomalies = append(anomalies, fmt.Sprintf("utxos add up to %d, total supply is %d", balances, totalSupply))
+	}
+
+	audit := &SupplyAudit{
+		TotalSupply: totalSupply,
+		Balances:    balances,
+		UTXOCount:   utxoCount,
+		Consistent:  len(anomalies) == 0,
+		Anomalies:   anomalies,
+	}
+
+	log.Printf("supply audit: %+v", audit)
+
+	return audit, nil
+}
@@ -0,0 +1,389 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define role names
+const adminRole = "ADMIN"
+const minterRole = "MINTER"
+const burnerRole = "BURNER"
+const pauserRole = "PAUSER"
+
+// Define objectType names for prefix
+const rolePrefix = "role"
+
+// roleEvent provides an organized struct for emitting role events
+type roleEvent struct {
+	Role    string `json:"role"`
+	Account string `json:"account"`
+	Sender  string `json:"sender"`
+}
+
+// GrantRole grants a role to an account
+// The account can be a client ID, as returned by ClientID(), or an MSP ID, in which case every client of that organization holds the role
+// Only clients holding the ADMIN role can grant roles
+// This function triggers a RoleGranted event
+func (s *SmartContract) GrantRole(ctx contractapi.TransactionContextInterface, role string, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(role)
+	if err != nil {
+		return err
+	}
+
+	if account == "" {
+		return fmt.Errorf("account must not be empty")
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to grant roles")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	granted, err := hasRole(ctx, role, account)
+	if err != nil {
+		return err
+	}
+	if granted {
+		return fmt.Errorf("account %s already has role %s", account, role)
+	}
+
+	err = putRole(ctx, role, account)
+	if err != nil {
+		return err
+	}
+
+	// Emit the RoleGranted event
+	err = emitRoleEvent(ctx, "RoleGranted", roleEvent{role, account, sender})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s granted role %s to account %s", sender, role, account)
+
+	return nil
+}
+
+// RevokeRole revokes a role from an account
+// Only clients holding the ADMIN role can revoke roles, and the last ADMIN cannot be revoked
+// This function triggers a RoleRevoked event
+func (s *SmartContract) RevokeRole(ctx contractapi.TransactionContextInterface, role string, account string) error {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(role)
+	if err != nil {
+		return err
+	}
+
+	// Check admin authorization
+	authorized, err := clientHasRole(ctx, adminRole)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %v", err)
+	}
+	if !authorized {
+		return fmt.Errorf("client is not authorized to revoke roles")
+	}
+
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	granted, err := hasRole(ctx, role, account)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return fmt.Errorf("account %s does not have role %s", account, role)
+	}
+
+	// Revoking the last admin would leave the role registry without anyone able to manage it
+	if role == adminRole {
+		admins, err := roleMembers(ctx, adminRole)
+		if err != nil {
+			return err
+		}
+		if len(admins) <= 1 {
+			return fmt.Errorf("cannot revoke the last account with role %s", adminRole)
+		}
+	}
+
+	roleKey, err := ctx.GetStub().CreateCompositeKey(rolePrefix, []string{role, account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", rolePrefix, err)
+	}
+
+	err = ctx.GetStub().DelState(roleKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete role %s of account %s: %v", role, account, err)
+	}
+
+	// Emit the RoleRevoked event
+	err = emitRoleEvent(ctx, "RoleRevoked", roleEvent{role, account, sender})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("client %s revoked role %s from account %s", sender, role, account)
+
+	return nil
+}
+
+// HasRole returns whether the role has been granted to the account
+// The account is matched literally, so a client holding a role through its MSP ID only has it for the MSP ID
+func (s *SmartContract) HasRole(ctx contractapi.TransactionContextInterface, role string, account string) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(role)
+	if err != nil {
+		return false, err
+	}
+
+	return hasRole(ctx, role, account)
+}
+
+// GetRoleMembers returns the client IDs and MSP IDs that have been granted the role
+func (s *SmartContract) GetRoleMembers(ctx contractapi.TransactionContextInterface, role string) ([]string, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	err = validateRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	return roleMembers(ctx, role)
+}
+
+// MigrateRoles seeds the role registry of a contract that was initialized before roles were recorded on the ledger
+// Without it no client holds a role after the upgrade, and Mint, Burn and GrantRole fail for everyone
+// The calling client is granted the ADMIN role, and its MSP the MINTER and BURNER roles, as in Initialize
+// It can only be called by the central banker organization, and only while no ADMIN has been recorded
+// This function triggers a RolesGranted event
+func (s *SmartContract) MigrateRoles(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	// Check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return false, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to intitialize contract
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	if clientMSPID != "Org1MSP" {
+		return false, fmt.Errorf("client is not authorized to migrate roles")
+	}
+
+	admins, err := roleMembers(ctx, adminRole)
+	if err != nil {
+		return false, err
+	}
+	if len(admins) > 0 {
+		return false, fmt.Errorf("roles are already recorded, use GrantRole to grant further roles")
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = seedRoles(ctx, clientID, clientMSPID)
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("client %s migrated the role registry", clientID)
+
+	return true, nil
+}
+
+// Helper Functions
+
+// seedRoles grants the ADMIN role to the client and the MINTER and BURNER roles to its MSP
+// A transaction can only emit one event, so a single RolesGranted event lists every granted role
+// Dependant functions include Initialize and MigrateRoles
+func seedRoles(ctx contractapi.TransactionContextInterface, clientID string, clientMSPID string) error {
+	grants := []roleEvent{
+		{adminRole, clientID, clientID},
+		{minterRole, clientMSPID, clientID},
+		{burnerRole, clientMSPID, clientID},
+	}
+
+	for _, grant := range grants {
+		err := putRole(ctx, grant.Role, grant.Account)
+		if err != nil {
+			return err
+		}
+	}
+
+	grantsJSON, err := json.Marshal(grants)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("RolesGranted", grantsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// validateRole checks that the role is one of the roles known to this contract
+func validateRole(role string) error {
+	switch role {
+	case adminRole, minterRole, burnerRole, pauserRole:
+		return nil
+	default:
+		return fmt.Errorf("unknown role %s, expected one of %s, %s, %s or %s", role, adminRole, minterRole, burnerRole, pauserRole)
+	}
+}
+
+// hasRole checks whether the role has been granted to the account
+func hasRole(ctx contractapi.TransactionContextInterface, role string, account string) (bool, error) {
+	roleKey, err := ctx.GetStub().CreateCompositeKey(rolePrefix, []string{role, account})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the composite key for prefix %s: %v", rolePrefix, err)
+	}
+
+	roleBytes, err := ctx.GetStub().GetState(roleKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read role %s of account %s from world state: %v", role, account, err)
+	}
+
+	return roleBytes != nil, nil
+}
+
+// clientHasRole checks whether the role has been granted to the submitting client, either by client ID or by MSP ID
+func clientHasRole(ctx contractapi.TransactionContextInterface, role string) (bool, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	granted, err := hasRole(ctx, role, clientID)
+	if err != nil || granted {
+		return granted, err
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSPID: %v", err)
+	}
+
+	return hasRole(ctx, role, clientMSPID)
+}
+
+// putRole records the role for the account
+// A composite key of role.account enables partial composite key query to find all members of a role
+// An empty value would represent a delete, so we simply insert the null character
+func putRole(ctx contractapi.TransactionContextInterface, role string, account string) error {
+	roleKey, err := ctx.GetStub().CreateCompositeKey(rolePrefix, []string{role, account})
+	if err != nil {
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", rolePrefix, err)
+	}
+
+	err = ctx.GetStub().PutState(roleKey, []byte{'\u0000'})
+	if err != nil {
+		return fmt.Errorf("failed to grant role %s to account %s: %v", role, account, err)
+	}
+
+	return nil
+}
+
+// roleMembers returns all accounts holding the role
+func roleMembers(ctx contractapi.TransactionContextInterface, role string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(rolePrefix, []string{role})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members of role %s: %v", role, err)
+	}
+	defer iterator.Close()
+
+	members := []string{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		// composite key is expected to be role.account
+		if len(compositeKeyParts) != 2 {
+			return nil, fmt.Errorf("expected composite key with two parts (role:account)")
+		}
+
+		members = append(members, compositeKeyParts[1])
+	}
+
+	return members, nil
+}
+
+// emitRoleEvent emits a RoleGranted or RoleRevoked event
+func emitRoleEvent(ctx contractapi.TransactionContextInterface, name string, e roleEvent) error {
+	eventJSON, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent(name, eventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
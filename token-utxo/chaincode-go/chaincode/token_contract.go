@@ -24,6 +24,7 @@ type UTXO struct {
 const nameKey = "name"
 const symbolKey = "symbol"
 const totalSupplyKey = "totalSupply"
+const supplyShortfallKey = "supplyShortfall"
 
 // Mint creates a new unspent transaction output (UTXO) owned by the minter
 func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount int) (*UTXO, error) {
@@ -37,12 +38,12 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 		return nil, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
 	}
 
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	// Check minter authorization - the MINTER role is granted to the initializing organization and can be changed with GrantRole() and RevokeRole()
+	authorized, err := clientHasRole(ctx, minterRole)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get MSPID: %v", err)
+		return nil, fmt.Errorf("failed to check minter role: %v", err)
 	}
-	if clientMSPID != "Org1MSP" {
+	if !authorized {
 		return nil, fmt.Errorf("client is not authorized to mint new tokens")
 	}
 
@@ -69,6 +70,11 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 		return nil, err
 	}
 
+	err = increaseTotalSupply(ctx, amount)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Printf("utxo minted: %+v", utxo)
 
 	return &utxo, nil
@@ -178,6 +184,100 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, ut
 	return utxoOutputs, nil
 }
 
+// Burn spends UTXOs owned by the client without creating outputs, destroying the tokens they contain
+// Only clients holding the BURNER role can burn tokens
+// returns {Number} Returns the amount of tokens burned
+func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, utxoInputKeys []string) (int, error) {
+
+	// check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	// Check burner authorization - the BURNER role is granted to the initializing organization and can be changed with GrantRole() and RevokeRole()
+	authorized, err := clientHasRole(ctx, burnerRole)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check burner role: %v", err)
+	}
+	if !authorized {
+		return 0, fmt.Errorf("client is not authorized to burn tokens")
+	}
+
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	if len(utxoInputKeys) == 0 {
+		return 0, fmt.Errorf("no utxo inputs to burn")
+	}
+
+	// Validate the utxo inputs and delete them from the owner's state
+	spent := make(map[string]bool)
+	var totalInputAmount int
+	for _, utxoInputKey := range utxoInputKeys {
+		if spent[utxoInputKey] {
+			return 0, fmt.Errorf("the same utxo input can not be spend twice")
+		}
+		spent[utxoInputKey] = true
+
+		utxoInputCompositeKey, err := ctx.GetStub().CreateCompositeKey("utxo", []string{clientID, utxoInputKey})
+		if err != nil {
+			return 0, fmt.Errorf("failed to create composite key: %v", err)
+		}
+
+		// validate that client has a utxo matching the input key
+		valueBytes, err := ctx.GetStub().GetState(utxoInputCompositeKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read utxoInputCompositeKey %s from world state: %v", utxoInputCompositeKey, err)
+		}
+
+		if valueBytes == nil {
+			return 0, fmt.Errorf("utxoInput %s not found for client %s", utxoInputKey, clientID)
+		}
+
+		amount, _ := strconv.Atoi(string(valueBytes)) // Error handling not needed since Itoa() was used when setting the utxo amount, guaranteeing it was an integer.
+
+		totalInputAmount, err = add(totalInputAmount, amount)
+		if err != nil {
+			return 0, err
+		}
+
+		err = ctx.GetStub().DelState(utxoInputCompositeKey)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("utxoInput burned: %+v", UTXO{Key: utxoInputKey, Owner: clientID, Amount: amount})
+	}
+
+	err = decreaseTotalSupply(ctx, totalInputAmount)
+	if err != nil {
+		return 0, err
+	}
+
+	return totalInputAmount, nil
+}
+
+// TotalSupply returns the total token supply, the sum of all unspent transaction outputs
+func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	// check if contract has been intilized first
+	initialized, err := checkInitialized(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return 0, fmt.Errorf("Contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return readTotalSupply(ctx)
+}
+
 // ClientUTXOs returns all UTXOs owned by the calling client
 func (s *SmartContract) ClientUTXOs(ctx contractapi.TransactionContextInterface) ([]*UTXO, error) {
 
@@ -306,6 +406,8 @@ func (s *SmartContract) Symbol(ctx contractapi.TransactionContextInterface) (str
 }
 
 // Set information for a token and intialize contract.
+// The calling client is granted the ADMIN role, and its MSP the MINTER and BURNER roles
+// This function triggers a RolesGranted event
 // param {String} name The name of the token
 // param {String} symbol The symbol of the token
 func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string) (bool, error) {
@@ -338,11 +440,127 @@ func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface,
 		return false, fmt.Errorf("failed to set symbol: %v", err)
 	}
 
+	// The initializing client becomes the first admin, and its organization keeps the central banker privilege to mint and burn
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	err = seedRoles(ctx, clientID, clientMSPID)
+	if err != nil {
+		return false, err
+	}
+
 	log.Printf("name: %v, symbol: %v", name, symbol)
 
 	return true, nil
 }
 
+// readTotalSupply reads the total supply from the world state, if no tokens have been minted it is 0
+func readTotalSupply(ctx contractapi.TransactionContextInterface) (int, error) {
+	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+	if totalSupplyBytes == nil {
+		return 0, nil
+	}
+
+	totalSupply, err := strconv.Atoi(string(totalSupplyBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read total token supply: %v", err)
+	}
+
+	return totalSupply, nil
+}
+
+// increaseTotalSupply adds minted tokens to the total supply
+func increaseTotalSupply(ctx contractapi.TransactionContextInterface, amount int) error {
+	totalSupply, err := readTotalSupply(ctx)
+	if err != nil {
+		return err
+	}
+
+	updatedTotalSupply, err := add(totalSupply, amount)
+	if err != nil {
+		return err
+	}
+
+	return putTotalSupply(ctx, totalSupply, updatedTotalSupply)
+}
+
+// decreaseTotalSupply removes burned tokens from the total supply
+// UTXOs minted before the total supply was tracked can burn more than the recorded total supply.
+// The total supply is clamped at 0 so that they can still be burned, and the shortfall is recorded for AuditSupply to report
+func decreaseTotalSupply(ctx contractapi.TransactionContextInterface, amount int) error {
+	totalSupply, err := readTotalSupply(ctx)
+	if err != nil {
+		return err
+	}
+
+	if totalSupply < amount {
+		err = increaseSupplyShortfall(ctx, amount-totalSupply)
+		if err != nil {
+			return err
+		}
+
+		amount = totalSupply
+	}
+
+	return putTotalSupply(ctx, totalSupply, totalSupply-amount)
+}
+
+// readSupplyShortfall reads the amount burned beyond the recorded total supply, 0 if the supply never fell short
+func readSupplyShortfall(ctx contractapi.TransactionContextInterface) (int, error) {
+	supplyShortfallBytes, err := ctx.GetStub().GetState(supplyShortfallKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve supply shortfall: %v", err)
+	}
+	if supplyShortfallBytes == nil {
+		return 0, nil
+	}
+
+	supplyShortfall, err := strconv.Atoi(string(supplyShortfallBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read supply shortfall: %v", err)
+	}
+
+	return supplyShortfall, nil
+}
+
+// increaseSupplyShortfall adds to the amount burned beyond the recorded total supply
+func increaseSupplyShortfall(ctx contractapi.TransactionContextInterface, amount int) error {
+	supplyShortfall, err := readSupplyShortfall(ctx)
+	if err != nil {
+		return err
+	}
+
+	supplyShortfall, err = add(supplyShortfall, amount)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(supplyShortfallKey, []byte(strconv.Itoa(supplyShortfall)))
+	if err != nil {
+		return fmt.Errorf("failed to update supply shortfall: %v", err)
+	}
+
+	log.Printf("burn exceeded the recorded total supply by %d", amount)
+
+	return nil
+}
+
+func putTotalSupply(ctx contractapi.TransactionContextInterface, totalSupply int, updatedTotalSupply int) error {
+	err := ctx.GetStub().PutState(totalSupplyKey, []byte(strconv.Itoa(updatedTotalSupply)))
+	if err != nil {
+		return fmt.Errorf("failed to update total supply: %v", err)
+	}
+
+	log.Printf("total supply updated from %d to %d", totalSupply, updatedTotalSupply)
+
+	return nil
+}
+
 // Checks that contract options have been already initialized
 func checkInitialized(ctx contractapi.TransactionContextInterface) (bool, error) {
 	tokenName, err := ctx.GetStub().GetState(nameKey)
@@ -0,0 +1,231 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const burner = "x509::CN=burner,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org1.example.com,O=org1.example.com,L=Durham,ST=North Carolina,C=US"
+const holder = "x509::CN=holder,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.org2.example.com,O=org2.example.com,L=Hursley,ST=Hampshire,C=UK"
+
+var burner64 = base64.StdEncoding.EncodeToString([]byte(burner))
+var holder64 = base64.StdEncoding.EncodeToString([]byte(holder))
+
+type MockStub struct {
+	shim.ChaincodeStubInterface
+	mock.Mock
+}
+
+func (ms *MockStub) GetState(key string) ([]byte, error) {
+	args := ms.Called(key)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (ms *MockStub) PutState(key string, value []byte) error {
+	args := ms.Called(key, value)
+	return args.Error(0)
+}
+
+func (ms *MockStub) DelState(key string) error {
+	args := ms.Called(key)
+	return args.Error(0)
+}
+
+func (ms *MockStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	args := ms.Called(objectType, keys)
+	return &MockIterator{records: args.Get(0).([]*queryresult.KV)}, args.Error(1)
+}
+
+// CreateCompositeKey uses the key format of the peer, so that the tests can build the keys the contract reads
+func (ms *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+type MockClientIdentity struct {
+	cid.ClientIdentity
+	mock.Mock
+}
+
+func (mci *MockClientIdentity) GetID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetMSPID() (string, error) {
+	args := mci.Called()
+	return args.Get(0).(string), args.Error(1)
+}
+
+type MockContext struct {
+	contractapi.TransactionContextInterface
+	mock.Mock
+}
+
+func (mc *MockContext) GetStub() shim.ChaincodeStubInterface {
+	args := mc.Called()
+	return args.Get(0).(*MockStub)
+}
+
+func (mc *MockContext) GetClientIdentity() cid.ClientIdentity {
+	args := mc.Called()
+	return args.Get(0).(*MockClientIdentity)
+}
+
+type MockIterator struct {
+	shim.StateQueryIteratorInterface
+	records []*queryresult.KV
+}
+
+func (it *MockIterator) HasNext() bool {
+	return len(it.records) > 0
+}
+
+func (it *MockIterator) Next() (*queryresult.KV, error) {
+	record := it.records[0]
+	it.records = it.records[1:]
+	return record, nil
+}
+
+func (it *MockIterator) Close() error {
+	return nil
+}
+
+// setupStub returns a context for the client with the given ID of an initialized contract
+// The BURNER role is granted to the burner, the state read by a test is mocked by the test with mockState
+func setupStub(clientID string, clientMSPID string) (*MockContext, *MockStub) {
+	anyString := mock.AnythingOfType("string")
+	anyUint8Slice := mock.AnythingOfType("[]uint8")
+
+	ms := new(MockStub)
+
+	ms.On("GetState", nameKey).Return([]byte("Token"), nil)
+	mockState(ms, compositeKey(rolePrefix, burnerRole, burner64), "\x00")
+	mockState(ms, compositeKey(rolePrefix, burnerRole, holder64), "")
+	mockState(ms, compositeKey(rolePrefix, burnerRole, "Org2MSP"), "")
+
+	ms.On("PutState", anyString, anyUint8Slice).Return(nil)
+	ms.On("DelState", anyString).Return(nil)
+
+	mci := new(MockClientIdentity)
+	mci.On("GetID").Return(clientID, nil)
+	mci.On("GetMSPID").Return(clientMSPID, nil)
+
+	mc := new(MockContext)
+	mc.On("GetStub").Return(ms)
+	mc.On("GetClientIdentity").Return(mci)
+	return mc, ms
+}
+
+// mockState mocks the value of a key, an empty value mocks a key that is not in the world state
+func mockState(ms *MockStub, key string, value string) {
+	if value == "" {
+		ms.On("GetState", key).Return([]byte(nil), nil)
+		return
+	}
+	ms.On("GetState", key).Return([]byte(value), nil)
+}
+
+func compositeKey(objectType string, attributes ...string) string {
+	key, _ := shim.CreateCompositeKey(objectType, attributes)
+	return key
+}
+
+func TestBurn(t *testing.T) {
+	transactionContext, chaincodeStub := setupStub(burner64, "Org1MSP")
+	c := new(SmartContract)
+
+	mockState(chaincodeStub, compositeKey("utxo", burner64, "tx1.0"), "30")
+	mockState(chaincodeStub, compositeKey("utxo", burner64, "tx1.1"), "70")
+	mockState(chaincodeStub, compositeKey("utxo", burner64, "tx2.0"), "")
+	mockState(chaincodeStub, totalSupplyKey, "100")
+	mockState(chaincodeStub, supplyShortfallKey, "")
+
+	_, err := c.Burn(transactionContext, []string{})
+	assert.EqualError(t, err, "no utxo inputs to burn")
+
+	_, err = c.Burn(transactionContext, []string{"tx1.0", "tx1.0"})
+	assert.EqualError(t, err, "the same utxo input can not be spend twice")
+
+	_, err = c.Burn(transactionContext, []string{"tx2.0"})
+	assert.EqualError(t, err, "utxoInput tx2.0 not found for client "+burner64)
+
+	chaincodeStub.Calls = nil
+	amount, err := c.Burn(transactionContext, []string{"tx1.0", "tx1.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, amount)
+	chaincodeStub.AssertCalled(t, "DelState", compositeKey("utxo", burner64, "tx1.0"))
+	chaincodeStub.AssertCalled(t, "DelState", compositeKey("utxo", burner64, "tx1.1"))
+	chaincodeStub.AssertCalled(t, "PutState", totalSupplyKey, []byte("0"))
+	chaincodeStub.AssertNotCalled(t, "PutState", supplyShortfallKey, mock.Anything)
+
+	// The BURNER role is checked before the utxo inputs of the client
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockState(chaincodeStub, compositeKey("utxo", holder64, "tx1.0"), "30")
+
+	_, err = c.Burn(transactionContext, []string{"tx1.0"})
+	assert.EqualError(t, err, "client is not authorized to burn tokens")
+	chaincodeStub.AssertNotCalled(t, "DelState", mock.Anything)
+}
+
+func TestBurnBeyondTotalSupply(t *testing.T) {
+	transactionContext, chaincodeStub := setupStub(burner64, "Org1MSP")
+	c := new(SmartContract)
+
+	mockState(chaincodeStub, compositeKey("utxo", burner64, "tx1.1"), "70")
+	mockState(chaincodeStub, totalSupplyKey, "20")
+	mockState(chaincodeStub, supplyShortfallKey, "5")
+
+	// The total supply is clamped at zero and the burn beyond it is added to the supply shortfall
+	amount, err := c.Burn(transactionContext, []string{"tx1.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 70, amount)
+	chaincodeStub.AssertCalled(t, "DelState", compositeKey("utxo", burner64, "tx1.1"))
+	chaincodeStub.AssertCalled(t, "PutState", supplyShortfallKey, []byte("55"))
+	chaincodeStub.AssertCalled(t, "PutState", totalSupplyKey, []byte("0"))
+}
+
+func TestAuditSupply(t *testing.T) {
+	c := new(SmartContract)
+
+	utxos := func() []*queryresult.KV {
+		return []*queryresult.KV{
+			{Key: compositeKey("utxo", burner64, "tx1.0"), Value: []byte("30")},
+			{Key: compositeKey("utxo", holder64, "tx2.0"), Value: []byte("70")},
+		}
+	}
+
+	transactionContext, chaincodeStub := setupStub(holder64, "Org2MSP")
+	mockState(chaincodeStub, totalSupplyKey, "100")
+	mockState(chaincodeStub, supplyShortfallKey, "")
+	chaincodeStub.On("GetStateByPartialCompositeKey", "utxo", []string{}).Return(utxos(), nil)
+
+	audit, err := c.AuditSupply(transactionContext)
+	assert.NoError(t, err)
+	assert.Equal(t, &SupplyAudit{TotalSupply: 100, Balances: 100, UTXOCount: 2, Consistent: true, Anomalies: []string{}}, audit)
+
+	// A burn beyond the recorded total supply leaves the supply history inconsistent
+	transactionContext, chaincodeStub = setupStub(holder64, "Org2MSP")
+	mockState(chaincodeStub, totalSupplyKey, "80")
+	mockState(chaincodeStub, supplyShortfallKey, "50")
+	records := append(utxos(), &queryresult.KV{Key: compositeKey("utxo", holder64, "tx3.0"), Value: []byte("ten")})
+	chaincodeStub.On("GetStateByPartialCompositeKey", "utxo", []string{}).Return(records, nil)
+
+	audit, err = c.AuditSupply(transactionContext)
+	assert.NoError(t, err)
+	assert.False(t, audit.Consistent)
+	assert.Equal(t, 3, audit.UTXOCount)
+	assert.Equal(t, []string{
+		fmt.Sprintf("utxo %q has an invalid amount \"ten\"", compositeKey("utxo", holder64, "tx3.0")),
+		"burns exceeded the recorded total supply by 50",
+		"utxos add up to 100, total supply is 80",
+	}, audit.Anomalies)
+}